@@ -12,6 +12,8 @@ import (
 	"io/fs"
 	"os"
 	"reflect"
+	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 )
@@ -21,17 +23,41 @@ type configField struct {
 	Key                 string
 	Kind                string
 	DefaultValueLiteral string
+	AllowedValues       []string
+	Min                 *uint64
+	Max                 *uint64
+	Pattern             string
+	Required            bool
 	AllowGlobalOverride bool
+	Spellings           []string
+
+	// ElementType, Fields and Validate describe the object of an object
+	// field, or the objects of an object array field.
+	ElementType string
+	Fields      []configField
+	Validate    string
 }
 
 type dprintTag struct {
 	DefaultValueLiteral string
+	AllowedValues       []string
+	Min                 *uint64
+	Max                 *uint64
+	Pattern             string
+	Required            bool
 	AllowGlobalOverride bool
+	Spellings           []string
+	Validate            string
 }
 
 const (
-	kindUint32 = "uint32"
-	kindBool   = "bool"
+	kindUint32      = "uint32"
+	kindBool        = "bool"
+	kindString      = "string"
+	kindStringSlice = "[]string"
+	kindStringMap   = "map[string]string"
+	kindObjectSlice = "[]struct"
+	kindObject      = "struct"
 )
 
 func main() {
@@ -42,6 +68,8 @@ func main() {
 		specName       = flag.String("spec", "generatedConfigurationResolverSpec", "generated spec variable name")
 		extraKnownKeys = flag.String("extra-known-keys", "", "additional known keys (comma-separated)")
 		dprintTagKey   = flag.String("dprint-tag-key", "dprint", "struct tag key for resolver options")
+		validate       = flag.String("validate", "", "function checking the resolved configuration as a whole")
+		aliases        = flag.String("aliases", "", "variable holding the deprecated key aliases")
 	)
 	flag.Parse()
 
@@ -54,6 +82,12 @@ func main() {
 	if *specName == "" {
 		exitWithError(fmt.Errorf("spec variable name must not be empty"))
 	}
+	if *validate != "" && !token.IsIdentifier(*validate) {
+		exitWithError(fmt.Errorf("validate must be a function name, got %q", *validate))
+	}
+	if *aliases != "" && !token.IsIdentifier(*aliases) {
+		exitWithError(fmt.Errorf("aliases must be a variable name, got %q", *aliases))
+	}
 
 	pkgName, fields, err := parseStructFields(*dir, *typeName, *dprintTagKey)
 	if err != nil {
@@ -61,7 +95,7 @@ func main() {
 	}
 
 	knownKeys := mergeKnownKeys(fields, parseExtraKnownKeys(*extraKnownKeys))
-	source, err := renderSource(pkgName, *typeName, *specName, fields, knownKeys)
+	source, err := renderSource(pkgName, *typeName, *specName, fields, knownKeys, *validate, *aliases)
 	if err != nil {
 		exitWithError(err)
 	}
@@ -85,15 +119,16 @@ func parseStructFields(dir string, typeName string, dprintTagKey string) (string
 	}
 
 	for pkgName, pkg := range pkgs {
-		for _, file := range pkg.Files {
-			fields, ok, err := parseStructTypeFromFile(file, typeName, dprintTagKey)
-			if err != nil {
-				return "", nil, err
-			}
-			if ok {
-				return pkgName, fields, nil
-			}
+		structs := collectStructTypes(pkg.Files)
+		if _, ok := structs[typeName]; !ok {
+			continue
 		}
+
+		fields, err := parseConfigFields(structs, typeName, dprintTagKey, nil)
+		if err != nil {
+			return "", nil, err
+		}
+		return pkgName, fields, nil
 	}
 
 	return "", nil, fmt.Errorf("type %q not found", typeName)
@@ -104,92 +139,159 @@ func includeSourceFile(info fs.FileInfo) bool {
 	return strings.HasSuffix(name, ".go") && !strings.HasSuffix(name, "_test.go")
 }
 
-func parseStructTypeFromFile(file *ast.File, typeName string, dprintTagKey string) ([]configField, bool, error) {
-	for _, decl := range file.Decls {
-		genDecl, ok := decl.(*ast.GenDecl)
-		if !ok || genDecl.Tok != token.TYPE {
-			continue
-		}
-
-		for _, spec := range genDecl.Specs {
-			typeSpec, ok := spec.(*ast.TypeSpec)
-			if !ok || typeSpec.Name.Name != typeName {
+// collectStructTypes indexes the struct types declared in files by name, so
+// that the element types of object array fields can be found. Other types map
+// to nil.
+func collectStructTypes(files map[string]*ast.File) map[string]*ast.StructType {
+	structs := make(map[string]*ast.StructType)
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			genDecl, ok := decl.(*ast.GenDecl)
+			if !ok || genDecl.Tok != token.TYPE {
 				continue
 			}
 
-			structType, ok := typeSpec.Type.(*ast.StructType)
-			if !ok {
-				return nil, false, fmt.Errorf("type %q is not a struct", typeName)
+			for _, spec := range genDecl.Specs {
+				typeSpec, ok := spec.(*ast.TypeSpec)
+				if !ok {
+					continue
+				}
+				structType, _ := typeSpec.Type.(*ast.StructType)
+				structs[typeSpec.Name.Name] = structType
 			}
-
-			fields, err := parseConfigFields(structType, dprintTagKey)
-			if err != nil {
-				return nil, false, fmt.Errorf("failed to parse %q: %w", typeName, err)
-			}
-			return fields, true, nil
 		}
 	}
-
-	return nil, false, nil
+	return structs
 }
 
-func parseConfigFields(structType *ast.StructType, dprintTagKey string) ([]configField, error) {
+// parseConfigFields parses the fields of the struct typeName. parents lists
+// the types whose fields are being parsed, to reject recursive types.
+func parseConfigFields(
+	structs map[string]*ast.StructType,
+	typeName string,
+	dprintTagKey string,
+	parents []string,
+) ([]configField, error) {
+	structType := structs[typeName]
+	if structType == nil {
+		return nil, fmt.Errorf("type %q is not a struct", typeName)
+	}
+	if slices.Contains(parents, typeName) {
+		return nil, fmt.Errorf("type %q contains itself", typeName)
+	}
+	parents = append(parents, typeName)
+
 	fields := make([]configField, 0, len(structType.Fields.List))
 
 	for _, field := range structType.Fields.List {
 		if len(field.Names) != 1 {
-			return nil, fmt.Errorf("each configuration field must declare exactly one name")
+			return nil, fmt.Errorf("failed to parse %q: each configuration field must declare exactly one name", typeName)
 		}
 
-		fieldName := field.Names[0].Name
-		kind, err := parseSupportedKind(field.Type, fieldName)
+		parsed, err := parseConfigField(structs, field, dprintTagKey, parents)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("failed to parse %q: %w", typeName, err)
 		}
+		fields = append(fields, parsed)
+	}
 
-		if field.Tag == nil {
-			return nil, fmt.Errorf("field %q must define struct tags", fieldName)
-		}
-		tagText, err := strconv.Unquote(field.Tag.Value)
-		if err != nil {
-			return nil, fmt.Errorf("field %q has invalid struct tags: %w", fieldName, err)
-		}
-		tags := reflect.StructTag(tagText)
+	return fields, nil
+}
 
-		key, err := parseJSONKey(tags, fieldName)
-		if err != nil {
-			return nil, err
-		}
+func parseConfigField(
+	structs map[string]*ast.StructType,
+	field *ast.Field,
+	dprintTagKey string,
+	parents []string,
+) (configField, error) {
+	fieldName := field.Names[0].Name
+	if field.Tag == nil {
+		return configField{}, fmt.Errorf("field %q must define struct tags", fieldName)
+	}
+	tagText, err := strconv.Unquote(field.Tag.Value)
+	if err != nil {
+		return configField{}, fmt.Errorf("field %q has invalid struct tags: %w", fieldName, err)
+	}
+	tags := reflect.StructTag(tagText)
 
-		parsedTag, err := parseDprintTag(tags.Get(dprintTagKey), kind, fieldName)
-		if err != nil {
-			return nil, err
-		}
+	key, err := parseJSONKey(tags, fieldName)
+	if err != nil {
+		return configField{}, err
+	}
 
-		fields = append(fields, configField{
-			FieldName:           fieldName,
-			Key:                 key,
-			Kind:                kind,
-			DefaultValueLiteral: parsedTag.DefaultValueLiteral,
-			AllowGlobalOverride: parsedTag.AllowGlobalOverride,
-		})
+	kind, elementType, err := parseSupportedKind(field.Type, structs, fieldName)
+	if err != nil {
+		return configField{}, err
 	}
 
-	return fields, nil
-}
+	parsedTag, err := parseDprintTag(tags.Get(dprintTagKey), kind, fieldName)
+	if err != nil {
+		return configField{}, err
+	}
 
-func parseSupportedKind(expr ast.Expr, fieldName string) (string, error) {
-	ident, ok := expr.(*ast.Ident)
-	if !ok {
-		return "", fmt.Errorf("field %q must be bool or uint32", fieldName)
+	parsed := configField{
+		FieldName:           fieldName,
+		Key:                 key,
+		Kind:                kind,
+		DefaultValueLiteral: parsedTag.DefaultValueLiteral,
+		AllowedValues:       parsedTag.AllowedValues,
+		Min:                 parsedTag.Min,
+		Max:                 parsedTag.Max,
+		Pattern:             parsedTag.Pattern,
+		Required:            parsedTag.Required,
+		AllowGlobalOverride: parsedTag.AllowGlobalOverride,
+		Spellings:           parsedTag.Spellings,
+		ElementType:         elementType,
+		Validate:            parsedTag.Validate,
+	}
+	if kind == kindObjectSlice || kind == kindObject {
+		parsed.Fields, err = parseConfigFields(structs, elementType, dprintTagKey, parents)
+		if err != nil {
+			return configField{}, err
+		}
 	}
+	return parsed, nil
+}
 
-	switch ident.Name {
-	case kindUint32, kindBool:
-		return ident.Name, nil
-	default:
-		return "", fmt.Errorf("field %q has unsupported type %q", fieldName, ident.Name)
+// parseSupportedKind returns the kind of a field type and, for objects and
+// object arrays, the name of the struct type.
+func parseSupportedKind(
+	expr ast.Expr,
+	structs map[string]*ast.StructType,
+	fieldName string,
+) (string, string, error) {
+	switch expr := expr.(type) {
+	case *ast.Ident:
+		switch expr.Name {
+		case kindUint32, kindBool, kindString:
+			return expr.Name, "", nil
+		}
+		if structs[expr.Name] != nil {
+			return kindObject, expr.Name, nil
+		}
+	case *ast.ArrayType:
+		element, ok := expr.Elt.(*ast.Ident)
+		if expr.Len != nil || !ok {
+			break
+		}
+		if element.Name == kindString {
+			return kindStringSlice, "", nil
+		}
+		if structs[element.Name] != nil {
+			return kindObjectSlice, element.Name, nil
+		}
+	case *ast.MapType:
+		key, keyOK := expr.Key.(*ast.Ident)
+		value, valueOK := expr.Value.(*ast.Ident)
+		if keyOK && valueOK && key.Name == kindString && value.Name == kindString {
+			return kindStringMap, "", nil
+		}
 	}
+
+	return "", "", fmt.Errorf(
+		"field %q must be bool, uint32, string, []string, map[string]string, a struct or a slice of structs",
+		fieldName,
+	)
 }
 
 func parseJSONKey(tags reflect.StructTag, fieldName string) (string, error) {
@@ -207,31 +309,38 @@ func parseJSONKey(tags reflect.StructTag, fieldName string) (string, error) {
 }
 
 func parseDprintTag(raw string, kind string, fieldName string) (dprintTag, error) {
-	if strings.TrimSpace(raw) == "" {
+	scalar := kind == kindUint32 || kind == kindBool || kind == kindString
+	if scalar && strings.TrimSpace(raw) == "" {
 		return dprintTag{}, fmt.Errorf("field %q must define a dprint tag", fieldName)
 	}
 
 	parsed := dprintTag{}
 	hasDefault := false
+	defaultText := ""
 
-	for _, token := range strings.Split(raw, ",") {
-		part := strings.TrimSpace(token)
+	for _, option := range splitDprintTag(raw) {
+		part := strings.TrimSpace(option)
 		if part == "" {
 			continue
 		}
 
-		if part == "global" {
+		if part == "global" && scalar {
 			parsed.AllowGlobalOverride = true
 			continue
 		}
 
-		if strings.HasPrefix(part, "default=") {
+		if part == "required" && scalar {
+			parsed.Required = true
+			continue
+		}
+
+		if strings.HasPrefix(part, "default=") && scalar {
 			if hasDefault {
 				return dprintTag{}, fmt.Errorf("field %q has duplicate default options", fieldName)
 			}
 			hasDefault = true
 
-			defaultText := strings.TrimSpace(strings.TrimPrefix(part, "default="))
+			defaultText = strings.TrimSpace(strings.TrimPrefix(part, "default="))
 			valueLiteral, err := parseDefaultValueLiteral(defaultText, kind, fieldName)
 			if err != nil {
 				return dprintTag{}, err
@@ -240,16 +349,168 @@ func parseDprintTag(raw string, kind string, fieldName string) (dprintTag, error
 			continue
 		}
 
+		if bound, ok := strings.CutPrefix(part, "min="); ok && kind == kindUint32 {
+			if parsed.Min != nil {
+				return dprintTag{}, fmt.Errorf("field %q has duplicate min options", fieldName)
+			}
+			value, err := parseBound(bound, fieldName)
+			if err != nil {
+				return dprintTag{}, err
+			}
+			parsed.Min = &value
+			continue
+		}
+
+		if bound, ok := strings.CutPrefix(part, "max="); ok && kind == kindUint32 {
+			if parsed.Max != nil {
+				return dprintTag{}, fmt.Errorf("field %q has duplicate max options", fieldName)
+			}
+			value, err := parseBound(bound, fieldName)
+			if err != nil {
+				return dprintTag{}, err
+			}
+			if value == 0 {
+				return dprintTag{}, fmt.Errorf("field %q must have a max above 0", fieldName)
+			}
+			parsed.Max = &value
+			continue
+		}
+
+		if pattern, ok := strings.CutPrefix(part, "pattern="); ok && holdsStrings(kind) {
+			if _, err := regexp.Compile(pattern); err != nil {
+				return dprintTag{}, fmt.Errorf("field %q has invalid pattern: %w", fieldName, err)
+			}
+			parsed.Pattern = pattern
+			continue
+		}
+
+		if strings.HasPrefix(part, "enum=") {
+			if parsed.AllowedValues != nil {
+				return dprintTag{}, fmt.Errorf("field %q has duplicate enum options", fieldName)
+			}
+			allowedValues, err := parseEnumValues(strings.TrimPrefix(part, "enum="), kind, fieldName)
+			if err != nil {
+				return dprintTag{}, err
+			}
+			parsed.AllowedValues = allowedValues
+			continue
+		}
+
+		if spellings, ok := strings.CutPrefix(part, "spellings="); ok {
+			if parsed.Spellings != nil {
+				return dprintTag{}, fmt.Errorf("field %q has duplicate spellings options", fieldName)
+			}
+			values, err := parseSpellings(spellings, fieldName)
+			if err != nil {
+				return dprintTag{}, err
+			}
+			parsed.Spellings = values
+			continue
+		}
+
+		if validate, ok := strings.CutPrefix(part, "validate="); ok && (kind == kindObjectSlice || kind == kindObject) {
+			if !token.IsIdentifier(validate) {
+				return dprintTag{}, fmt.Errorf("field %q has invalid validate function %q", fieldName, validate)
+			}
+			parsed.Validate = validate
+			continue
+		}
+
 		return dprintTag{}, fmt.Errorf("field %q has unknown dprint option %q", fieldName, part)
 	}
 
-	if !hasDefault {
-		return dprintTag{}, fmt.Errorf("field %q must define default=... in dprint tag", fieldName)
+	switch {
+	case scalar && hasDefault && parsed.Required:
+		return dprintTag{}, fmt.Errorf("field %q cannot be both required and have a default", fieldName)
+	case scalar && !hasDefault && !parsed.Required:
+		return dprintTag{}, fmt.Errorf("field %q must define default=... or required in dprint tag", fieldName)
+	}
+	if hasDefault && parsed.AllowedValues != nil && !slices.Contains(parsed.AllowedValues, defaultText) {
+		return dprintTag{}, fmt.Errorf("field %q has default %q outside its enum values", fieldName, defaultText)
+	}
+	if parsed.Min != nil && parsed.Max != nil && *parsed.Min > *parsed.Max {
+		return dprintTag{}, fmt.Errorf("field %q has a min above its max", fieldName)
+	}
+	if hasDefault && kind == kindUint32 {
+		value, _ := strconv.ParseUint(defaultText, 10, 32)
+		if (parsed.Min != nil && value < *parsed.Min) || (parsed.Max != nil && value > *parsed.Max) {
+			return dprintTag{}, fmt.Errorf("field %q has default %d outside its bounds", fieldName, value)
+		}
+	}
+	if hasDefault && parsed.Pattern != "" && !regexp.MustCompile(parsed.Pattern).MatchString(defaultText) {
+		return dprintTag{}, fmt.Errorf("field %q has default %q not matching its pattern", fieldName, defaultText)
+	}
+
+	return parsed, nil
+}
+
+// splitDprintTag splits a dprint tag into its options. A pattern=... option
+// runs to the end of the tag, so that the regular expression may contain
+// commas.
+func splitDprintTag(raw string) []string {
+	options := make([]string, 0)
+	for raw != "" {
+		option, rest, _ := strings.Cut(raw, ",")
+		if strings.HasPrefix(strings.TrimSpace(option), "pattern=") {
+			return append(options, raw)
+		}
+		options = append(options, option)
+		raw = rest
 	}
+	return options
+}
 
+func parseBound(value string, fieldName string) (uint64, error) {
+	parsed, err := strconv.ParseUint(strings.TrimSpace(value), 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("field %q has invalid uint32 bound %q", fieldName, value)
+	}
 	return parsed, nil
 }
 
+func holdsStrings(kind string) bool {
+	return kind == kindString || kind == kindStringSlice || kind == kindStringMap
+}
+
+// parseEnumValues parses the |-separated values of an enum=... option.
+func parseEnumValues(raw string, kind string, fieldName string) ([]string, error) {
+	if !holdsStrings(kind) {
+		return nil, fmt.Errorf("field %q must hold strings to define enum values", fieldName)
+	}
+
+	values := strings.Split(raw, "|")
+	for i, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			return nil, fmt.Errorf("field %q has an empty enum value", fieldName)
+		}
+		if slices.Contains(values[:i], value) {
+			return nil, fmt.Errorf("field %q has duplicate enum value %q", fieldName, value)
+		}
+		values[i] = value
+	}
+
+	return values, nil
+}
+
+// parseSpellings parses the |-separated other spellings of a field key in a
+// spellings=... option.
+func parseSpellings(raw string, fieldName string) ([]string, error) {
+	values := strings.Split(raw, "|")
+	for i, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			return nil, fmt.Errorf("field %q has an empty spelling", fieldName)
+		}
+		if slices.Contains(values[:i], value) {
+			return nil, fmt.Errorf("field %q has duplicate spelling %q", fieldName, value)
+		}
+		values[i] = value
+	}
+
+	return values, nil
+}
+
 func parseDefaultValueLiteral(value string, kind string, fieldName string) (string, error) {
 	switch kind {
 	case kindUint32:
@@ -264,6 +525,8 @@ func parseDefaultValueLiteral(value string, kind string, fieldName string) (stri
 			return "", fmt.Errorf("field %q has invalid bool default %q", fieldName, value)
 		}
 		return strconv.FormatBool(parsed), nil
+	case kindString:
+		return strconv.Quote(value), nil
 	default:
 		return "", fmt.Errorf("field %q has unsupported type %q", fieldName, kind)
 	}
@@ -312,69 +575,236 @@ func renderSource(
 	specName string,
 	fields []configField,
 	knownKeys []string,
+	validate string,
+	aliases string,
 ) ([]byte, error) {
-	uint32Fields := make([]configField, 0)
-	boolFields := make([]configField, 0)
+	var buffer bytes.Buffer
+	buffer.WriteString("// Code generated by go generate; DO NOT EDIT.\n\n")
+	fmt.Fprintf(&buffer, "package %s\n\n", packageName)
+	if usesPattern(fields) {
+		buffer.WriteString("import (\n\"regexp\"\n\n\"github.com/hrko/dprint-plugin-shfmt/dprint\"\n)\n\n")
+	} else {
+		buffer.WriteString("import \"github.com/hrko/dprint-plugin-shfmt/dprint\"\n\n")
+	}
+
+	fmt.Fprintf(&buffer, "var %s = ", specName)
+	if err := renderSpec(&buffer, typeName, fields, knownKeys, validate, aliases); err != nil {
+		return nil, err
+	}
+	buffer.WriteString("\n")
+
+	return buffer.Bytes(), nil
+}
 
+// renderSpec writes the ConfigResolverSpec literal for typeName. Object and
+// object array fields render the spec of their objects the same way. Sections without
+// fields are left out; gofmt fixes the indentation afterwards.
+func renderSpec(
+	buffer *bytes.Buffer,
+	typeName string,
+	fields []configField,
+	knownKeys []string,
+	validate string,
+	aliases string,
+) error {
+	fieldsByKind := make(map[string][]configField)
 	for _, field := range fields {
 		switch field.Kind {
-		case kindUint32:
-			uint32Fields = append(uint32Fields, field)
-		case kindBool:
-			boolFields = append(boolFields, field)
+		case kindUint32, kindBool, kindString, kindStringSlice, kindStringMap, kindObjectSlice, kindObject:
+			fieldsByKind[field.Kind] = append(fieldsByKind[field.Kind], field)
 		default:
-			return nil, fmt.Errorf("unknown field kind %q", field.Kind)
+			return fmt.Errorf("unknown field kind %q", field.Kind)
 		}
 	}
 
-	var buffer bytes.Buffer
-	buffer.WriteString("// Code generated by go generate; DO NOT EDIT.\n\n")
-	fmt.Fprintf(&buffer, "package %s\n\n", packageName)
-	buffer.WriteString("import \"github.com/hrko/dprint-plugin-shfmt/dprint\"\n\n")
-
-	fmt.Fprintf(&buffer, "var %s = dprint.ConfigResolverSpec[%s]{\n", specName, typeName)
-
-	fmt.Fprintf(&buffer, "\tUInt32Fields: []dprint.UInt32ConfigFieldSpec[%s]{\n", typeName)
-	for _, field := range uint32Fields {
-		fmt.Fprintf(&buffer, "\t\t{\n")
-		fmt.Fprintf(&buffer, "\t\t\tKey: %q,\n", field.Key)
-		fmt.Fprintf(&buffer, "\t\t\tDefaultValue: %s,\n", field.DefaultValueLiteral)
-		fmt.Fprintf(&buffer, "\t\t\tAllowGlobalOverride: %t,\n", field.AllowGlobalOverride)
-		fmt.Fprintf(&buffer, "\t\t\tGet: func(config %s) uint32 {\n", typeName)
-		fmt.Fprintf(&buffer, "\t\t\t\treturn config.%s\n", field.FieldName)
-		fmt.Fprintf(&buffer, "\t\t\t},\n")
-		fmt.Fprintf(&buffer, "\t\t\tSet: func(config *%s, value uint32) {\n", typeName)
-		fmt.Fprintf(&buffer, "\t\t\t\tconfig.%s = value\n", field.FieldName)
-		fmt.Fprintf(&buffer, "\t\t\t},\n")
-		fmt.Fprintf(&buffer, "\t\t},\n")
-	}
-	buffer.WriteString("\t},\n")
-
-	fmt.Fprintf(&buffer, "\tBoolFields: []dprint.BoolConfigFieldSpec[%s]{\n", typeName)
-	for _, field := range boolFields {
-		fmt.Fprintf(&buffer, "\t\t{\n")
-		fmt.Fprintf(&buffer, "\t\t\tKey: %q,\n", field.Key)
-		fmt.Fprintf(&buffer, "\t\t\tDefaultValue: %s,\n", field.DefaultValueLiteral)
-		fmt.Fprintf(&buffer, "\t\t\tAllowGlobalOverride: %t,\n", field.AllowGlobalOverride)
-		fmt.Fprintf(&buffer, "\t\t\tGet: func(config %s) bool {\n", typeName)
-		fmt.Fprintf(&buffer, "\t\t\t\treturn config.%s\n", field.FieldName)
-		fmt.Fprintf(&buffer, "\t\t\t},\n")
-		fmt.Fprintf(&buffer, "\t\t\tSet: func(config *%s, value bool) {\n", typeName)
-		fmt.Fprintf(&buffer, "\t\t\t\tconfig.%s = value\n", field.FieldName)
-		fmt.Fprintf(&buffer, "\t\t\t},\n")
-		fmt.Fprintf(&buffer, "\t\t},\n")
-	}
-	buffer.WriteString("\t},\n")
-
-	buffer.WriteString("\tKnownKeys: []string{\n")
+	fmt.Fprintf(buffer, "dprint.ConfigResolverSpec[%s]{\n", typeName)
+
+	scalarSections := []struct {
+		kind    string
+		section string
+		spec    string
+		goType  string
+	}{
+		{kind: kindUint32, section: "UInt32Fields", spec: "UInt32ConfigFieldSpec", goType: "uint32"},
+		{kind: kindBool, section: "BoolFields", spec: "BoolConfigFieldSpec", goType: "bool"},
+		{kind: kindString, section: "StringFields", spec: "StringConfigFieldSpec", goType: "string"},
+	}
+	for _, section := range scalarSections {
+		if len(fieldsByKind[section.kind]) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(buffer, "%s: []dprint.%s[%s]{\n", section.section, section.spec, typeName)
+		for _, field := range fieldsByKind[section.kind] {
+			buffer.WriteString("{\n")
+			fmt.Fprintf(buffer, "Key: %q,\n", field.Key)
+			if field.Required {
+				buffer.WriteString("Required: true,\n")
+			} else {
+				fmt.Fprintf(buffer, "DefaultValue: %s,\n", field.DefaultValueLiteral)
+			}
+			if field.Min != nil {
+				fmt.Fprintf(buffer, "Min: %d,\n", *field.Min)
+			}
+			if field.Max != nil {
+				fmt.Fprintf(buffer, "Max: %d,\nHasMax: true,\n", *field.Max)
+			}
+			renderAllowedValues(buffer, field.AllowedValues)
+			renderPattern(buffer, field.Pattern)
+			fmt.Fprintf(buffer, "AllowGlobalOverride: %t,\n", field.AllowGlobalOverride)
+			fmt.Fprintf(buffer, "Get: func(config %s) %s {\n", typeName, section.goType)
+			fmt.Fprintf(buffer, "return config.%s\n", field.FieldName)
+			buffer.WriteString("},\n")
+			fmt.Fprintf(buffer, "Set: func(config *%s, value %s) {\n", typeName, section.goType)
+			fmt.Fprintf(buffer, "config.%s = value\n", field.FieldName)
+			buffer.WriteString("},\n")
+			buffer.WriteString("},\n")
+		}
+		buffer.WriteString("},\n")
+	}
+
+	collectionSections := []struct {
+		kind    string
+		section string
+		spec    string
+	}{
+		{kind: kindStringSlice, section: "StringSliceFields", spec: "StringSliceConfigFieldSpec"},
+		{kind: kindStringMap, section: "StringMapFields", spec: "StringMapConfigFieldSpec"},
+	}
+	for _, section := range collectionSections {
+		if len(fieldsByKind[section.kind]) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(buffer, "%s: []dprint.%s[%s]{\n", section.section, section.spec, typeName)
+		for _, field := range fieldsByKind[section.kind] {
+			buffer.WriteString("{\n")
+			fmt.Fprintf(buffer, "Key: %q,\n", field.Key)
+			renderAllowedValues(buffer, field.AllowedValues)
+			renderPattern(buffer, field.Pattern)
+			fmt.Fprintf(buffer, "Set: func(config *%s, value %s) {\n", typeName, section.kind)
+			fmt.Fprintf(buffer, "config.%s = value\n", field.FieldName)
+			buffer.WriteString("},\n")
+			buffer.WriteString("},\n")
+		}
+		buffer.WriteString("},\n")
+	}
+
+	objectSections := []struct {
+		kind    string
+		section string
+		spec    string
+		prefix  string
+	}{
+		{kind: kindObjectSlice, section: "ObjectSliceFields", spec: "ObjectSliceConfigFieldSpec", prefix: "[]"},
+		{kind: kindObject, section: "ObjectFields", spec: "ObjectConfigFieldSpec"},
+	}
+	for _, section := range objectSections {
+		if len(fieldsByKind[section.kind]) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(buffer, "%s: []dprint.%s[%s]{\n", section.section, section.spec, typeName)
+		for _, field := range fieldsByKind[section.kind] {
+			fmt.Fprintf(buffer, "dprint.New%s(\n", section.spec)
+			fmt.Fprintf(buffer, "%q,\n", field.Key)
+			if err := renderSpec(buffer, field.ElementType, field.Fields, mergeKnownKeys(field.Fields, nil), field.Validate, ""); err != nil {
+				return err
+			}
+			buffer.WriteString(",\n")
+			fmt.Fprintf(buffer, "func(config *%s, value %s%s) {\n", typeName, section.prefix, field.ElementType)
+			fmt.Fprintf(buffer, "config.%s = value\n", field.FieldName)
+			buffer.WriteString("},\n")
+			buffer.WriteString("),\n")
+		}
+		buffer.WriteString("},\n")
+	}
+
+	buffer.WriteString("KnownKeys: []string{\n")
 	for _, key := range knownKeys {
-		fmt.Fprintf(&buffer, "\t\t%q,\n", key)
+		fmt.Fprintf(buffer, "%q,\n", key)
 	}
-	buffer.WriteString("\t},\n")
+	buffer.WriteString("},\n")
 
-	buffer.WriteString("}\n")
+	if err := renderSpellings(buffer, fields, knownKeys); err != nil {
+		return err
+	}
+	if aliases != "" {
+		fmt.Fprintf(buffer, "Aliases: %s,\n", aliases)
+	}
+	if validate != "" {
+		fmt.Fprintf(buffer, "Validate: %s,\n", validate)
+	}
 
-	return buffer.Bytes(), nil
+	buffer.WriteString("}")
+	return nil
+}
+
+// renderSpellings writes the Spellings map of a spec, in field order. A
+// spelling may stand for only one key and cannot be a key itself.
+func renderSpellings(buffer *bytes.Buffer, fields []configField, knownKeys []string) error {
+	targets := make(map[string]string)
+	var spellings bytes.Buffer
+	for _, field := range fields {
+		for _, spelling := range field.Spellings {
+			if slices.Contains(knownKeys, spelling) {
+				return fmt.Errorf("spelling %q of field %q is a known key", spelling, field.FieldName)
+			}
+			if target, ok := targets[spelling]; ok {
+				return fmt.Errorf("spelling %q stands for both %q and %q", spelling, target, field.Key)
+			}
+			targets[spelling] = field.Key
+			fmt.Fprintf(&spellings, "%q: %q,\n", spelling, field.Key)
+		}
+	}
+	if len(targets) == 0 {
+		return nil
+	}
+
+	buffer.WriteString("Spellings: map[string]string{\n")
+	buffer.Write(spellings.Bytes())
+	buffer.WriteString("},\n")
+	return nil
+}
+
+// usesPattern reports whether any of fields, including the fields of objects,
+// has a pattern, so that the generated file needs regexp.
+func usesPattern(fields []configField) bool {
+	for _, field := range fields {
+		if field.Pattern != "" || usesPattern(field.Fields) {
+			return true
+		}
+	}
+	return false
+}
+
+// renderPattern writes the Pattern of a field, compiled once when the spec
+// is created.
+func renderPattern(buffer *bytes.Buffer, pattern string) {
+	if pattern == "" {
+		return
+	}
+
+	if strconv.CanBackquote(pattern) {
+		fmt.Fprintf(buffer, "Pattern: regexp.MustCompile(`%s`),\n", pattern)
+	} else {
+		fmt.Fprintf(buffer, "Pattern: regexp.MustCompile(%q),\n", pattern)
+	}
+}
+
+func renderAllowedValues(buffer *bytes.Buffer, allowedValues []string) {
+	if len(allowedValues) == 0 {
+		return
+	}
+
+	buffer.WriteString("AllowedValues: []string{")
+	for i, value := range allowedValues {
+		if i > 0 {
+			buffer.WriteString(", ")
+		}
+		fmt.Fprintf(buffer, "%q", value)
+	}
+	buffer.WriteString("},\n")
 }
 
 func exitWithError(err error) {
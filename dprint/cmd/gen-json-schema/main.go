@@ -11,6 +11,8 @@ import (
 	"io/fs"
 	"os"
 	"reflect"
+	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -18,19 +20,38 @@ import (
 )
 
 type configField struct {
-	Key          string
-	Kind         string
-	DefaultValue any
-	Description  string
+	Key           string
+	Kind          string
+	DefaultValue  any
+	AllowedValues []string
+	Min           *uint64
+	Max           *uint64
+	Pattern       string
+	Required      bool
+	Description   string
+
+	// Fields describes the object of an object field, or the objects of an
+	// object array field.
+	Fields []configField
 }
 
 type dprintTag struct {
-	DefaultValue any
+	DefaultValue  any
+	AllowedValues []string
+	Min           *uint64
+	Max           *uint64
+	Pattern       string
+	Required      bool
 }
 
 const (
 	kindUint32         = "uint32"
 	kindBool           = "bool"
+	kindString         = "string"
+	kindStringSlice    = "[]string"
+	kindStringMap      = "map[string]string"
+	kindObjectSlice    = "[]struct"
+	kindObject         = "struct"
 	defaultDraftSchema = "http://json-schema.org/draft-07/schema#"
 )
 
@@ -90,15 +111,11 @@ func parseStructFields(
 	}
 
 	for _, pkg := range pkgs {
-		for _, file := range pkg.Files {
-			fields, ok, parseErr := parseStructTypeFromFile(file, typeName, dprintTagKey, descriptionTagKey)
-			if parseErr != nil {
-				return nil, parseErr
-			}
-			if ok {
-				return fields, nil
-			}
+		structs := collectStructTypes(pkg.Files)
+		if _, ok := structs[typeName]; !ok {
+			continue
 		}
+		return parseConfigFields(structs, typeName, dprintTagKey, descriptionTagKey, nil)
 	}
 
 	return nil, fmt.Errorf("type %q not found", typeName)
@@ -109,105 +126,162 @@ func includeSourceFile(info fs.FileInfo) bool {
 	return strings.HasSuffix(name, ".go") && !strings.HasSuffix(name, "_test.go")
 }
 
-func parseStructTypeFromFile(
-	file *ast.File,
-	typeName string,
-	dprintTagKey string,
-	descriptionTagKey string,
-) ([]configField, bool, error) {
-	for _, decl := range file.Decls {
-		genDecl, ok := decl.(*ast.GenDecl)
-		if !ok || genDecl.Tok != token.TYPE {
-			continue
-		}
-
-		for _, spec := range genDecl.Specs {
-			typeSpec, ok := spec.(*ast.TypeSpec)
-			if !ok || typeSpec.Name.Name != typeName {
+// collectStructTypes indexes the struct types declared in files by name, so
+// that the element types of object array fields can be found. Other types map
+// to nil.
+func collectStructTypes(files map[string]*ast.File) map[string]*ast.StructType {
+	structs := make(map[string]*ast.StructType)
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			genDecl, ok := decl.(*ast.GenDecl)
+			if !ok || genDecl.Tok != token.TYPE {
 				continue
 			}
 
-			structType, ok := typeSpec.Type.(*ast.StructType)
-			if !ok {
-				return nil, false, fmt.Errorf("type %q is not a struct", typeName)
-			}
-
-			fields, err := parseConfigFields(structType, dprintTagKey, descriptionTagKey)
-			if err != nil {
-				return nil, false, fmt.Errorf("failed to parse %q: %w", typeName, err)
+			for _, spec := range genDecl.Specs {
+				typeSpec, ok := spec.(*ast.TypeSpec)
+				if !ok {
+					continue
+				}
+				structType, _ := typeSpec.Type.(*ast.StructType)
+				structs[typeSpec.Name.Name] = structType
 			}
-			return fields, true, nil
 		}
 	}
-
-	return nil, false, nil
+	return structs
 }
 
+// parseConfigFields parses the fields of the struct typeName. parents lists
+// the types whose fields are being parsed, to reject recursive types.
 func parseConfigFields(
-	structType *ast.StructType,
+	structs map[string]*ast.StructType,
+	typeName string,
 	dprintTagKey string,
 	descriptionTagKey string,
+	parents []string,
 ) ([]configField, error) {
+	structType := structs[typeName]
+	if structType == nil {
+		return nil, fmt.Errorf("type %q is not a struct", typeName)
+	}
+	if slices.Contains(parents, typeName) {
+		return nil, fmt.Errorf("type %q contains itself", typeName)
+	}
+	parents = append(parents, typeName)
+
 	fields := make([]configField, 0, len(structType.Fields.List))
 
 	for _, field := range structType.Fields.List {
 		if len(field.Names) != 1 {
-			return nil, fmt.Errorf("each configuration field must declare exactly one name")
+			return nil, fmt.Errorf("failed to parse %q: each configuration field must declare exactly one name", typeName)
 		}
 
-		fieldName := field.Names[0].Name
-		kind, err := parseSupportedKind(field.Type, fieldName)
+		parsed, err := parseConfigField(structs, field, dprintTagKey, descriptionTagKey, parents)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("failed to parse %q: %w", typeName, err)
 		}
+		fields = append(fields, parsed)
+	}
 
-		if field.Tag == nil {
-			return nil, fmt.Errorf("field %q must define struct tags", fieldName)
-		}
-		tagText, err := strconv.Unquote(field.Tag.Value)
-		if err != nil {
-			return nil, fmt.Errorf("field %q has invalid struct tags: %w", fieldName, err)
-		}
-		tags := reflect.StructTag(tagText)
+	return fields, nil
+}
 
-		key, err := parseJSONKey(tags, fieldName)
-		if err != nil {
-			return nil, err
-		}
+func parseConfigField(
+	structs map[string]*ast.StructType,
+	field *ast.Field,
+	dprintTagKey string,
+	descriptionTagKey string,
+	parents []string,
+) (configField, error) {
+	fieldName := field.Names[0].Name
+	if field.Tag == nil {
+		return configField{}, fmt.Errorf("field %q must define struct tags", fieldName)
+	}
+	tagText, err := strconv.Unquote(field.Tag.Value)
+	if err != nil {
+		return configField{}, fmt.Errorf("field %q has invalid struct tags: %w", fieldName, err)
+	}
+	tags := reflect.StructTag(tagText)
 
-		description, err := parseDescription(tags.Get(descriptionTagKey), fieldName, descriptionTagKey)
-		if err != nil {
-			return nil, err
-		}
+	key, err := parseJSONKey(tags, fieldName)
+	if err != nil {
+		return configField{}, err
+	}
 
-		parsedTag, err := parseDprintTag(tags.Get(dprintTagKey), kind, fieldName)
-		if err != nil {
-			return nil, err
-		}
+	kind, elementType, err := parseSupportedKind(field.Type, structs, fieldName)
+	if err != nil {
+		return configField{}, err
+	}
 
-		fields = append(fields, configField{
-			Key:          key,
-			Kind:         kind,
-			DefaultValue: parsedTag.DefaultValue,
-			Description:  description,
-		})
+	description, err := parseDescription(tags.Get(descriptionTagKey), fieldName, descriptionTagKey)
+	if err != nil {
+		return configField{}, err
 	}
 
-	return fields, nil
-}
+	parsedTag, err := parseDprintTag(tags.Get(dprintTagKey), kind, fieldName)
+	if err != nil {
+		return configField{}, err
+	}
 
-func parseSupportedKind(expr ast.Expr, fieldName string) (string, error) {
-	ident, ok := expr.(*ast.Ident)
-	if !ok {
-		return "", fmt.Errorf("field %q must be bool or uint32", fieldName)
+	parsed := configField{
+		Key:           key,
+		Kind:          kind,
+		DefaultValue:  parsedTag.DefaultValue,
+		AllowedValues: parsedTag.AllowedValues,
+		Min:           parsedTag.Min,
+		Max:           parsedTag.Max,
+		Pattern:       parsedTag.Pattern,
+		Required:      parsedTag.Required,
+		Description:   description,
+	}
+	if kind == kindObjectSlice || kind == kindObject {
+		parsed.Fields, err = parseConfigFields(structs, elementType, dprintTagKey, descriptionTagKey, parents)
+		if err != nil {
+			return configField{}, err
+		}
 	}
+	return parsed, nil
+}
 
-	switch ident.Name {
-	case kindUint32, kindBool:
-		return ident.Name, nil
-	default:
-		return "", fmt.Errorf("field %q has unsupported type %q", fieldName, ident.Name)
+// parseSupportedKind returns the kind of a field type and, for objects and
+// object arrays, the name of the struct type.
+func parseSupportedKind(
+	expr ast.Expr,
+	structs map[string]*ast.StructType,
+	fieldName string,
+) (string, string, error) {
+	switch expr := expr.(type) {
+	case *ast.Ident:
+		switch expr.Name {
+		case kindUint32, kindBool, kindString:
+			return expr.Name, "", nil
+		}
+		if structs[expr.Name] != nil {
+			return kindObject, expr.Name, nil
+		}
+	case *ast.ArrayType:
+		element, ok := expr.Elt.(*ast.Ident)
+		if expr.Len != nil || !ok {
+			break
+		}
+		if element.Name == kindString {
+			return kindStringSlice, "", nil
+		}
+		if structs[element.Name] != nil {
+			return kindObjectSlice, element.Name, nil
+		}
+	case *ast.MapType:
+		key, keyOK := expr.Key.(*ast.Ident)
+		value, valueOK := expr.Value.(*ast.Ident)
+		if keyOK && valueOK && key.Name == kindString && value.Name == kindString {
+			return kindStringMap, "", nil
+		}
 	}
+
+	return "", "", fmt.Errorf(
+		"field %q must be bool, uint32, string, []string, map[string]string, a struct or a slice of structs",
+		fieldName,
+	)
 }
 
 func parseJSONKey(tags reflect.StructTag, fieldName string) (string, error) {
@@ -237,30 +311,37 @@ func parseDescription(raw string, fieldName string, descriptionTagKey string) (s
 }
 
 func parseDprintTag(raw string, kind string, fieldName string) (dprintTag, error) {
-	if strings.TrimSpace(raw) == "" {
+	scalar := kind == kindUint32 || kind == kindBool || kind == kindString
+	if scalar && strings.TrimSpace(raw) == "" {
 		return dprintTag{}, fmt.Errorf("field %q must define a dprint tag", fieldName)
 	}
 
 	parsed := dprintTag{}
 	hasDefault := false
+	defaultText := ""
 
-	for _, token := range strings.Split(raw, ",") {
-		part := strings.TrimSpace(token)
+	for _, option := range splitDprintTag(raw) {
+		part := strings.TrimSpace(option)
 		if part == "" {
 			continue
 		}
 
-		if part == "global" {
+		if part == "global" && scalar {
+			continue
+		}
+
+		if part == "required" && scalar {
+			parsed.Required = true
 			continue
 		}
 
-		if strings.HasPrefix(part, "default=") {
+		if strings.HasPrefix(part, "default=") && scalar {
 			if hasDefault {
 				return dprintTag{}, fmt.Errorf("field %q has duplicate default options", fieldName)
 			}
 			hasDefault = true
 
-			defaultText := strings.TrimSpace(strings.TrimPrefix(part, "default="))
+			defaultText = strings.TrimSpace(strings.TrimPrefix(part, "default="))
 			value, err := parseDefaultValue(defaultText, kind, fieldName)
 			if err != nil {
 				return dprintTag{}, err
@@ -269,16 +350,141 @@ func parseDprintTag(raw string, kind string, fieldName string) (dprintTag, error
 			continue
 		}
 
+		if bound, ok := strings.CutPrefix(part, "min="); ok && kind == kindUint32 {
+			if parsed.Min != nil {
+				return dprintTag{}, fmt.Errorf("field %q has duplicate min options", fieldName)
+			}
+			value, err := parseBound(bound, fieldName)
+			if err != nil {
+				return dprintTag{}, err
+			}
+			parsed.Min = &value
+			continue
+		}
+
+		if bound, ok := strings.CutPrefix(part, "max="); ok && kind == kindUint32 {
+			if parsed.Max != nil {
+				return dprintTag{}, fmt.Errorf("field %q has duplicate max options", fieldName)
+			}
+			value, err := parseBound(bound, fieldName)
+			if err != nil {
+				return dprintTag{}, err
+			}
+			if value == 0 {
+				return dprintTag{}, fmt.Errorf("field %q must have a max above 0", fieldName)
+			}
+			parsed.Max = &value
+			continue
+		}
+
+		if pattern, ok := strings.CutPrefix(part, "pattern="); ok && holdsStrings(kind) {
+			if _, err := regexp.Compile(pattern); err != nil {
+				return dprintTag{}, fmt.Errorf("field %q has invalid pattern: %w", fieldName, err)
+			}
+			parsed.Pattern = pattern
+			continue
+		}
+
+		if strings.HasPrefix(part, "enum=") {
+			if parsed.AllowedValues != nil {
+				return dprintTag{}, fmt.Errorf("field %q has duplicate enum options", fieldName)
+			}
+			allowedValues, err := parseEnumValues(strings.TrimPrefix(part, "enum="), kind, fieldName)
+			if err != nil {
+				return dprintTag{}, err
+			}
+			parsed.AllowedValues = allowedValues
+			continue
+		}
+
+		if strings.HasPrefix(part, "validate=") && (kind == kindObjectSlice || kind == kindObject) {
+			// Validation functions only matter to the resolver.
+			continue
+		}
+
+		if strings.HasPrefix(part, "spellings=") {
+			// Other spellings only matter to the resolver, which suggests
+			// keys for unknown properties.
+			continue
+		}
+
 		return dprintTag{}, fmt.Errorf("field %q has unknown dprint option %q", fieldName, part)
 	}
 
-	if !hasDefault {
-		return dprintTag{}, fmt.Errorf("field %q must define default=... in dprint tag", fieldName)
+	switch {
+	case scalar && hasDefault && parsed.Required:
+		return dprintTag{}, fmt.Errorf("field %q cannot be both required and have a default", fieldName)
+	case scalar && !hasDefault && !parsed.Required:
+		return dprintTag{}, fmt.Errorf("field %q must define default=... or required in dprint tag", fieldName)
+	}
+	if hasDefault && parsed.AllowedValues != nil && !slices.Contains(parsed.AllowedValues, defaultText) {
+		return dprintTag{}, fmt.Errorf("field %q has default %q outside its enum values", fieldName, defaultText)
+	}
+	if parsed.Min != nil && parsed.Max != nil && *parsed.Min > *parsed.Max {
+		return dprintTag{}, fmt.Errorf("field %q has a min above its max", fieldName)
+	}
+	if hasDefault && kind == kindUint32 {
+		value, _ := strconv.ParseUint(defaultText, 10, 32)
+		if (parsed.Min != nil && value < *parsed.Min) || (parsed.Max != nil && value > *parsed.Max) {
+			return dprintTag{}, fmt.Errorf("field %q has default %d outside its bounds", fieldName, value)
+		}
+	}
+	if hasDefault && parsed.Pattern != "" && !regexp.MustCompile(parsed.Pattern).MatchString(defaultText) {
+		return dprintTag{}, fmt.Errorf("field %q has default %q not matching its pattern", fieldName, defaultText)
 	}
 
 	return parsed, nil
 }
 
+// splitDprintTag splits a dprint tag into its options. A pattern=... option
+// runs to the end of the tag, so that the regular expression may contain
+// commas.
+func splitDprintTag(raw string) []string {
+	options := make([]string, 0)
+	for raw != "" {
+		option, rest, _ := strings.Cut(raw, ",")
+		if strings.HasPrefix(strings.TrimSpace(option), "pattern=") {
+			return append(options, raw)
+		}
+		options = append(options, option)
+		raw = rest
+	}
+	return options
+}
+
+func parseBound(value string, fieldName string) (uint64, error) {
+	parsed, err := strconv.ParseUint(strings.TrimSpace(value), 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("field %q has invalid uint32 bound %q", fieldName, value)
+	}
+	return parsed, nil
+}
+
+func holdsStrings(kind string) bool {
+	return kind == kindString || kind == kindStringSlice || kind == kindStringMap
+}
+
+// parseEnumValues parses the |-separated values of an enum=... option.
+func parseEnumValues(raw string, kind string, fieldName string) ([]string, error) {
+	if !holdsStrings(kind) {
+		return nil, fmt.Errorf("field %q must hold strings to define enum values", fieldName)
+	}
+
+	values := strings.Split(raw, "|")
+	for i, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			return nil, fmt.Errorf("field %q has an empty enum value", fieldName)
+		}
+		if slices.Contains(values[:i], value) {
+			return nil, fmt.Errorf("field %q has duplicate enum value %q", fieldName, value)
+		}
+		values[i] = value
+	}
+
+	return values, nil
+}
+
 func parseDefaultValue(value string, kind string, fieldName string) (any, error) {
 	switch kind {
 	case kindUint32:
@@ -293,6 +499,8 @@ func parseDefaultValue(value string, kind string, fieldName string) (any, error)
 			return nil, fmt.Errorf("field %q has invalid bool default %q", fieldName, value)
 		}
 		return parsed, nil
+	case kindString:
+		return value, nil
 	default:
 		return nil, fmt.Errorf("field %q has unsupported type %q", fieldName, kind)
 	}
@@ -342,30 +550,110 @@ func renderSchemaJSON(
 }
 
 func toSchemaProperty(field configField) (*jsonschema.Schema, error) {
-	defaultValue, err := encodeDefaultValue(field.DefaultValue)
-	if err != nil {
-		return nil, fmt.Errorf("failed to encode default for %q: %w", field.Key, err)
+	var defaultValue json.RawMessage
+	if !field.Required {
+		var err error
+		defaultValue, err = encodeDefaultValue(field.DefaultValue)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode default for %q: %w", field.Key, err)
+		}
 	}
 
 	switch field.Kind {
 	case kindUint32:
-		return &jsonschema.Schema{
+		property := &jsonschema.Schema{
 			Description: field.Description,
 			Default:     defaultValue,
 			Type:        "integer",
 			Minimum:     jsonschema.Ptr(0.0),
-		}, nil
+		}
+		if field.Min != nil {
+			property.Minimum = jsonschema.Ptr(float64(*field.Min))
+		}
+		if field.Max != nil {
+			property.Maximum = jsonschema.Ptr(float64(*field.Max))
+		}
+		return property, nil
 	case kindBool:
 		return &jsonschema.Schema{
 			Description: field.Description,
 			Default:     defaultValue,
 			Type:        "boolean",
 		}, nil
+	case kindString:
+		property := stringSchema(field.AllowedValues, field.Pattern)
+		property.Description = field.Description
+		property.Default = defaultValue
+		return property, nil
+	case kindStringSlice:
+		return &jsonschema.Schema{
+			Description: field.Description,
+			Default:     json.RawMessage("[]"),
+			Type:        "array",
+			Items:       stringSchema(field.AllowedValues, field.Pattern),
+		}, nil
+	case kindStringMap:
+		return &jsonschema.Schema{
+			Description:          field.Description,
+			Default:              json.RawMessage("{}"),
+			Type:                 "object",
+			AdditionalProperties: stringSchema(field.AllowedValues, field.Pattern),
+		}, nil
+	case kindObjectSlice:
+		items, err := objectSchema(field.Fields)
+		if err != nil {
+			return nil, err
+		}
+		return &jsonschema.Schema{
+			Description: field.Description,
+			Default:     json.RawMessage("[]"),
+			Type:        "array",
+			Items:       items,
+		}, nil
+	case kindObject:
+		// The properties declare their own defaults.
+		object, err := objectSchema(field.Fields)
+		if err != nil {
+			return nil, err
+		}
+		object.Description = field.Description
+		return object, nil
 	default:
 		return nil, fmt.Errorf("unknown field kind %q", field.Kind)
 	}
 }
 
+func stringSchema(allowedValues []string, pattern string) *jsonschema.Schema {
+	property := &jsonschema.Schema{Type: "string", Pattern: pattern}
+	for _, value := range allowedValues {
+		property.Enum = append(property.Enum, value)
+	}
+	return property
+}
+
+// objectSchema describes the object of an object field or the objects of an
+// object array field, which may only have the properties declared for them.
+func objectSchema(fields []configField) (*jsonschema.Schema, error) {
+	object := &jsonschema.Schema{
+		Type:       "object",
+		Properties: make(map[string]*jsonschema.Schema, len(fields)),
+		// An empty schema under "not" is how the library spells false.
+		AdditionalProperties: &jsonschema.Schema{Not: &jsonschema.Schema{}},
+	}
+	for _, field := range fields {
+		property, err := toSchemaProperty(field)
+		if err != nil {
+			return nil, err
+		}
+		object.Properties[field.Key] = property
+		object.PropertyOrder = append(object.PropertyOrder, field.Key)
+		if field.Required {
+			object.Required = append(object.Required, field.Key)
+		}
+	}
+	return object, nil
+}
+
 func encodeDefaultValue(value any) (json.RawMessage, error) {
 	raw, err := json.Marshal(value)
 	if err != nil {
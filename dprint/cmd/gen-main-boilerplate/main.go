@@ -1,4 +1,6 @@
 // Package main generates wasm entrypoint boilerplate for this plugin project.
+// The generated file is only built for wasm, so that the package can also be
+// built as a native process plugin.
 package main
 
 import (
@@ -16,6 +18,8 @@ type templateData struct {
 
 const sourceTemplate = `// Code generated by go generate; DO NOT EDIT.
 
+//go:build wasm
+
 package main
 
 //export dprint_plugin_version_4
@@ -0,0 +1,156 @@
+// Package main generates the dprint process plugin manifest (plugin.json) for
+// the release archives built by goreleaser.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// processPluginSchemaVersion is the schema version of the manifest format.
+const processPluginSchemaVersion = 2
+
+// platforms maps the GOOS-GOARCH suffix of an archive name to the dprint
+// platform names it can run on. The executables are built without cgo, so the
+// Linux ones run on musl as well.
+var platforms = []struct {
+	target string
+	names  []string
+}{
+	{target: "darwin-amd64", names: []string{"darwin-x86_64"}},
+	{target: "darwin-arm64", names: []string{"darwin-aarch64"}},
+	{target: "linux-amd64", names: []string{"linux-x86_64", "linux-x86_64-musl"}},
+	{target: "linux-arm64", names: []string{"linux-aarch64", "linux-aarch64-musl"}},
+	{target: "windows-amd64", names: []string{"windows-x86_64"}},
+}
+
+type releaseMetadata struct {
+	Tag     string `json:"tag"`
+	Version string `json:"version"`
+}
+
+type platformArchive struct {
+	Reference string `json:"reference"`
+	Checksum  string `json:"checksum"`
+}
+
+func main() {
+	var (
+		distDir   = flag.String("dist", "dist", "goreleaser output directory")
+		name      = flag.String("name", "", "plugin name, which is also the archive name prefix")
+		urlPrefix = flag.String("url-prefix", "", "URL the release tag and archive name are appended to")
+		outFile   = flag.String("out", "", "output file path (default <dist>/plugin.json)")
+	)
+	flag.Parse()
+
+	if *name == "" {
+		exitWithError(fmt.Errorf("plugin name must not be empty"))
+	}
+	if *urlPrefix == "" {
+		exitWithError(fmt.Errorf("url-prefix must not be empty"))
+	}
+	if *outFile == "" {
+		*outFile = filepath.Join(*distDir, "plugin.json")
+	}
+
+	metadata, err := readMetadata(filepath.Join(*distDir, "metadata.json"))
+	if err != nil {
+		exitWithError(err)
+	}
+	checksums, err := readChecksums(filepath.Join(*distDir, "checksums.txt"))
+	if err != nil {
+		exitWithError(err)
+	}
+
+	source, err := renderManifest(*name, *urlPrefix, metadata, checksums)
+	if err != nil {
+		exitWithError(err)
+	}
+
+	if err := os.WriteFile(*outFile, source, 0o644); err != nil {
+		exitWithError(err)
+	}
+}
+
+func readMetadata(path string) (releaseMetadata, error) {
+	var metadata releaseMetadata
+	source, err := os.ReadFile(path)
+	if err != nil {
+		return metadata, err
+	}
+	if err := json.Unmarshal(source, &metadata); err != nil {
+		return metadata, fmt.Errorf("failed to parse %q: %w", path, err)
+	}
+	if metadata.Tag == "" || metadata.Version == "" {
+		return metadata, fmt.Errorf("%q has no tag or version", path)
+	}
+	return metadata, nil
+}
+
+// readChecksums reads the "<sha256>  <file name>" lines goreleaser writes,
+// keyed by file name.
+func readChecksums(path string) (map[string]string, error) {
+	source, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	checksums := map[string]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(source))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		checksum, fileName, ok := strings.Cut(line, "  ")
+		if !ok {
+			return nil, fmt.Errorf("malformed line in %q: %q", path, line)
+		}
+		checksums[fileName] = checksum
+	}
+	return checksums, scanner.Err()
+}
+
+func renderManifest(
+	name string,
+	urlPrefix string,
+	metadata releaseMetadata,
+	checksums map[string]string,
+) ([]byte, error) {
+	manifest := map[string]any{
+		"schemaVersion": processPluginSchemaVersion,
+		"kind":          "process",
+		"name":          name,
+		"version":       metadata.Version,
+	}
+	for _, platform := range platforms {
+		archive := name + "-" + platform.target + ".zip"
+		checksum, ok := checksums[archive]
+		if !ok {
+			return nil, fmt.Errorf("no checksum for archive %q", archive)
+		}
+		for _, platformName := range platform.names {
+			manifest[platformName] = platformArchive{
+				Reference: strings.TrimSuffix(urlPrefix, "/") + "/" + metadata.Tag + "/" + archive,
+				Checksum:  checksum,
+			}
+		}
+	}
+
+	source, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(source, '\n'), nil
+}
+
+func exitWithError(err error) {
+	_, _ = fmt.Fprintf(os.Stderr, "gen-process-plugin-manifest: %v\n", err)
+	os.Exit(1)
+}
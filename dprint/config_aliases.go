@@ -0,0 +1,136 @@
+package dprint
+
+import (
+	"maps"
+	"slices"
+	"strings"
+)
+
+// ConfigAlias is a deprecated top-level key that is still accepted in place of
+// Key, such as a name another tool uses for the same setting. When Values is
+// set, the alias takes string values, which Values translates to values of
+// Key; otherwise values are passed on unchanged.
+type ConfigAlias struct {
+	Alias  string
+	Key    string
+	Values map[string]any
+}
+
+// value returns the value for the alias's key that value stands for.
+func (a ConfigAlias) value(value any) (any, bool) {
+	if a.Values == nil || value == nil {
+		return value, true
+	}
+	text, ok := value.(string)
+	if !ok {
+		return nil, false
+	}
+	translated, ok := a.Values[text]
+	return translated, ok
+}
+
+// applyConfigAliases returns config with the aliases in it replaced by their
+// keys, along with a diagnostic for each alias and the alias each key was set
+// through. An alias is dropped when its key is set as well, or set by an
+// earlier alias.
+func applyConfigAliases(config ConfigKeyMap, aliases []ConfigAlias) (ConfigKeyMap, map[string]string, []ConfigurationDiagnostic) {
+	diagnostics := make([]ConfigurationDiagnostic, 0)
+	if !hasConfigAliases(config, aliases) {
+		return config, nil, diagnostics
+	}
+
+	resolved := maps.Clone(config)
+	aliased := make(map[string]string)
+	for _, alias := range aliases {
+		value, ok := resolved[alias.Alias]
+		if !ok {
+			continue
+		}
+		delete(resolved, alias.Alias)
+
+		location := configLocation{}.child(alias.Alias)
+		if _, ok := resolved[alias.Key]; ok {
+			diagnostic := location.diagnostic(
+				"Property '%s' is deprecated and ignored because '%s' is set. Remove it.", alias.Alias, alias.Key,
+			)
+			diagnostic["suggestion"] = alias.Key
+			diagnostics = append(diagnostics, diagnostic)
+			continue
+		}
+
+		translated, ok := alias.value(value)
+		if !ok {
+			diagnostics = append(diagnostics, location.diagnostic(
+				"Expected '%s' to be one of %s, but got %#v.",
+				alias.Alias, quoteChoices(slices.Sorted(maps.Keys(alias.Values))), value,
+			))
+			continue
+		}
+		resolved[alias.Key] = translated
+		aliased[alias.Key] = alias.Alias
+
+		diagnostic := location.diagnostic("Property '%s' is deprecated. Use '%s' instead.", alias.Alias, alias.Key)
+		diagnostic["suggestion"] = alias.Key
+		diagnostics = append(diagnostics, diagnostic)
+	}
+	return resolved, aliased, diagnostics
+}
+
+// renameAliasedDiagnostics points diagnostics about keys set through an alias
+// at the alias, which is what the user wrote.
+func renameAliasedDiagnostics(diagnostics []ConfigurationDiagnostic, aliased map[string]string) {
+	for _, diagnostic := range diagnostics {
+		key, _ := diagnostic["propertyName"].(string)
+		alias, ok := aliased[key]
+		if !ok {
+			continue
+		}
+		diagnostic["propertyName"] = alias
+		if message, ok := diagnostic["message"].(string); ok {
+			diagnostic["message"] = strings.ReplaceAll(message, "'"+key+"'", "'"+alias+"'")
+		}
+	}
+}
+
+func hasConfigAliases(config ConfigKeyMap, aliases []ConfigAlias) bool {
+	for _, alias := range aliases {
+		if _, ok := config[alias.Alias]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ConfigAliasChanges returns the changes that rewrite the aliases in config to
+// their keys, for a plugin's CheckConfigUpdates. Aliases whose key is set as
+// well are removed. Aliases with values that cannot be translated are left
+// for the user to fix. A null alias is rewritten to a null key, which keeps
+// the default the same way.
+func ConfigAliasChanges(config ConfigKeyMap, aliases []ConfigAlias) []ConfigChange {
+	changes := make([]ConfigChange, 0)
+	added := make(map[string]bool)
+	for _, alias := range aliases {
+		value, ok := config[alias.Alias]
+		if !ok {
+			continue
+		}
+
+		if _, ok := config[alias.Key]; !ok && !added[alias.Key] {
+			translated, ok := alias.value(value)
+			if !ok {
+				continue
+			}
+			added[alias.Key] = true
+			changes = append(changes, ConfigChange{
+				Path:  []any{alias.Key},
+				Kind:  ConfigChangeKindAdd,
+				Value: translated,
+			})
+		}
+		changes = append(changes, ConfigChange{
+			Path: []any{alias.Alias},
+			Kind: ConfigChangeKindRemove,
+		})
+	}
+	return changes
+}
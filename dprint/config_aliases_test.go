@@ -0,0 +1,161 @@
+package dprint
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+var configAliasesTestAliases = []ConfigAlias{
+	{Alias: "indent_size", Key: "indentWidth"},
+	{Alias: "indent_style", Key: "useTabs", Values: map[string]any{"tab": true, "space": false}},
+}
+
+func TestResolveConfigWithSpecAcceptsAliases(t *testing.T) {
+	cases := []struct {
+		name       string
+		config     ConfigKeyMap
+		width      uint32
+		useTabs    bool
+		messages   []string
+		suggestion any
+		property   string
+	}{
+		{
+			name:       "alias",
+			config:     ConfigKeyMap{"indent_size": float64(4)},
+			width:      4,
+			messages:   []string{"Property 'indent_size' is deprecated. Use 'indentWidth' instead."},
+			suggestion: "indentWidth",
+		},
+		{
+			name:       "translated value",
+			config:     ConfigKeyMap{"indent_style": "tab"},
+			width:      2,
+			useTabs:    true,
+			messages:   []string{"Property 'indent_style' is deprecated. Use 'useTabs' instead."},
+			suggestion: "useTabs",
+		},
+		{
+			name:     "value without translation",
+			config:   ConfigKeyMap{"indent_style": "tabs"},
+			width:    2,
+			messages: []string{"Expected 'indent_style' to be one of 'space', 'tab', but got \"tabs\"."},
+		},
+		{
+			name:       "bad value names the alias",
+			config:     ConfigKeyMap{"indent_size": "tab"},
+			width:      2,
+			messages:   []string{"Property 'indent_size' is deprecated. Use 'indentWidth' instead.", "Expected 'indent_size' to be a non-negative integer, but got string."},
+			suggestion: "indentWidth",
+			property:   "indent_size",
+		},
+		{
+			name:       "null",
+			config:     ConfigKeyMap{"indent_size": nil},
+			width:      2,
+			messages:   []string{"Property 'indent_size' is deprecated. Use 'indentWidth' instead."},
+			suggestion: "indentWidth",
+		},
+		{
+			name:       "key set as well",
+			config:     ConfigKeyMap{"indent_size": float64(4), "indentWidth": float64(8)},
+			width:      8,
+			messages:   []string{"Property 'indent_size' is deprecated and ignored because 'indentWidth' is set. Remove it."},
+			suggestion: "indentWidth",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			spec := resolveConfigSpecTestSpec
+			spec.Aliases = configAliasesTestAliases
+			resolved, diagnostics := ResolveConfigWithSpec(tc.config, GlobalConfiguration{}, spec)
+
+			if resolved.IndentWidth != tc.width || resolved.UseTabs != tc.useTabs {
+				t.Fatalf("unexpected config: %+v", resolved)
+			}
+			messages := make([]string, 0, len(diagnostics))
+			for _, diagnostic := range diagnostics {
+				messages = append(messages, diagnostic["message"].(string))
+			}
+			if !reflect.DeepEqual(messages, tc.messages) {
+				t.Fatalf("expected messages %q, got %q", tc.messages, messages)
+			}
+			if diagnostics[0]["suggestion"] != tc.suggestion {
+				t.Fatalf("expected suggestion %#v, got %#v", tc.suggestion, diagnostics[0]["suggestion"])
+			}
+			if tc.property != "" && diagnostics[len(diagnostics)-1]["propertyName"] != tc.property {
+				t.Fatalf("expected diagnostic for %q, got %#v", tc.property, diagnostics[len(diagnostics)-1]["propertyName"])
+			}
+		})
+	}
+}
+
+func TestConfigAliasChanges(t *testing.T) {
+	cases := []struct {
+		name   string
+		config ConfigKeyMap
+		want   []ConfigChange
+	}{
+		{
+			name:   "no aliases",
+			config: ConfigKeyMap{"indentWidth": float64(4)},
+			want:   []ConfigChange{},
+		},
+		{
+			name:   "aliases",
+			config: ConfigKeyMap{"indent_size": float64(4), "indent_style": "tab"},
+			want: []ConfigChange{
+				{Path: []any{"indentWidth"}, Kind: ConfigChangeKindAdd, Value: float64(4)},
+				{Path: []any{"indent_size"}, Kind: ConfigChangeKindRemove},
+				{Path: []any{"useTabs"}, Kind: ConfigChangeKindAdd, Value: true},
+				{Path: []any{"indent_style"}, Kind: ConfigChangeKindRemove},
+			},
+		},
+		{
+			name:   "key set as well",
+			config: ConfigKeyMap{"indent_size": float64(4), "indentWidth": float64(8)},
+			want: []ConfigChange{
+				{Path: []any{"indent_size"}, Kind: ConfigChangeKindRemove},
+			},
+		},
+		{
+			name:   "value without translation",
+			config: ConfigKeyMap{"indent_style": "tabs"},
+			want:   []ConfigChange{},
+		},
+		{
+			name:   "null",
+			config: ConfigKeyMap{"indent_size": nil},
+			want: []ConfigChange{
+				{Path: []any{"indentWidth"}, Kind: ConfigChangeKindAdd, Value: nil},
+				{Path: []any{"indent_size"}, Kind: ConfigChangeKindRemove},
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			changes := ConfigAliasChanges(tc.config, configAliasesTestAliases)
+			if !reflect.DeepEqual(changes, tc.want) {
+				t.Fatalf("expected changes %#v, got %#v", tc.want, changes)
+			}
+		})
+	}
+}
+
+func TestConfigChangeMarshalJSON(t *testing.T) {
+	changes := []ConfigChange{
+		{Path: []any{"indentWidth"}, Kind: ConfigChangeKindAdd, Value: nil},
+		{Path: []any{"indent_size"}, Kind: ConfigChangeKindRemove},
+	}
+	data, err := json.Marshal(changes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `[{"path":["indentWidth"],"kind":"add","value":null},{"path":["indent_size"],"kind":"remove"}]`
+	if string(data) != want {
+		t.Fatalf("expected %s, got %s", want, data)
+	}
+}
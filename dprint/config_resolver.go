@@ -2,13 +2,24 @@ package dprint
 
 import (
 	"fmt"
+	"maps"
+	"regexp"
+	"slices"
 	"sort"
+	"strconv"
+	"strings"
 )
 
 // UInt32ConfigFieldSpec describes how to resolve one uint32 configuration field.
+// Values below Min are rejected, and so are values above Max when HasMax is
+// set.
 type UInt32ConfigFieldSpec[T any] struct {
 	Key                 string
 	DefaultValue        uint32
+	Min                 uint32
+	Max                 uint32
+	HasMax              bool
+	Required            bool
 	AllowGlobalOverride bool
 	Get                 func(config T) uint32
 	Set                 func(config *T, value uint32)
@@ -18,16 +29,174 @@ type UInt32ConfigFieldSpec[T any] struct {
 type BoolConfigFieldSpec[T any] struct {
 	Key                 string
 	DefaultValue        bool
+	Required            bool
 	AllowGlobalOverride bool
 	Get                 func(config T) bool
 	Set                 func(config *T, value bool)
 }
 
+// StringConfigFieldSpec describes how to resolve one string configuration
+// field. When AllowedValues is not empty, the value must be one of them, and
+// when Pattern is not nil, it must match that regular expression.
+type StringConfigFieldSpec[T any] struct {
+	Key                 string
+	DefaultValue        string
+	AllowedValues       []string
+	Pattern             *regexp.Regexp
+	Required            bool
+	AllowGlobalOverride bool
+	Get                 func(config T) string
+	Set                 func(config *T, value string)
+}
+
+// StringSliceConfigFieldSpec describes how to resolve an array of strings.
+// Each element is checked against AllowedValues and Pattern like a string
+// field. Invalid elements are reported and left out.
+type StringSliceConfigFieldSpec[T any] struct {
+	Key           string
+	DefaultValue  []string
+	AllowedValues []string
+	Pattern       *regexp.Regexp
+	Set           func(config *T, value []string)
+}
+
+// StringMapConfigFieldSpec describes how to resolve an object mapping any keys
+// to strings. Each value is checked against AllowedValues and Pattern like a
+// string field. Invalid entries are reported and left out.
+type StringMapConfigFieldSpec[T any] struct {
+	Key           string
+	DefaultValue  map[string]string
+	AllowedValues []string
+	Pattern       *regexp.Regexp
+	Set           func(config *T, value map[string]string)
+}
+
+// ObjectSliceConfigFieldSpec describes how to resolve an array of objects.
+// Create one with NewObjectSliceConfigFieldSpec, which knows the Go type of
+// the elements.
+type ObjectSliceConfigFieldSpec[T any] struct {
+	Key string
+
+	setDefault func(config *T)
+	resolve    func(config *T, value any, location configLocation, diagnostics *[]ConfigurationDiagnostic)
+}
+
+// ObjectConfigFieldSpec describes how to resolve an object with known
+// properties. Create one with NewObjectConfigFieldSpec, which knows the Go
+// type of the object.
+type ObjectConfigFieldSpec[T any] struct {
+	Key string
+
+	setDefault func(config *T)
+	resolve    func(config *T, value any, location configLocation, diagnostics *[]ConfigurationDiagnostic)
+}
+
+// ConfigProblem describes a resolved value that a spec's Validate function
+// rejects. It is reported as "Expected '<key>' to be <Expected>, but got
+// <Value>.", with the key written as a path from the top-level property.
+type ConfigProblem struct {
+	Key      string
+	Expected string
+	Value    any
+}
+
 // ConfigResolverSpec declares all fields used for configuration resolution.
+// The same spec type describes object fields and the objects in object array
+// fields, which are the only places Required fields are checked.
 type ConfigResolverSpec[T any] struct {
-	UInt32Fields []UInt32ConfigFieldSpec[T]
-	BoolFields   []BoolConfigFieldSpec[T]
-	KnownKeys    []string
+	UInt32Fields      []UInt32ConfigFieldSpec[T]
+	BoolFields        []BoolConfigFieldSpec[T]
+	StringFields      []StringConfigFieldSpec[T]
+	StringSliceFields []StringSliceConfigFieldSpec[T]
+	StringMapFields   []StringMapConfigFieldSpec[T]
+	ObjectSliceFields []ObjectSliceConfigFieldSpec[T]
+	ObjectFields      []ObjectConfigFieldSpec[T]
+	KnownKeys         []string
+
+	// Spellings maps other spellings of known keys, such as the names of
+	// matching command-line flags, to the key they stand for. They are only
+	// used to suggest a key for an unknown property.
+	Spellings map[string]string
+
+	// Aliases are deprecated keys accepted in place of top-level keys. Each
+	// one used is reported, so that it can be replaced.
+	Aliases []ConfigAlias
+
+	// Validate checks the resolved values beyond what the field specs
+	// declare, such as rules involving several fields. An object in an array
+	// is left out when it has problems; any other property with a problem
+	// falls back to its default.
+	Validate func(config T) []ConfigProblem
+}
+
+// NewObjectSliceConfigFieldSpec creates the spec of the array field key,
+// whose elements are objects resolved with items. Elements that are not
+// valid are reported and left out.
+func NewObjectSliceConfigFieldSpec[T any, E any](
+	key string,
+	items ConfigResolverSpec[E],
+	set func(config *T, value []E),
+) ObjectSliceConfigFieldSpec[T] {
+	return ObjectSliceConfigFieldSpec[T]{
+		Key: key,
+		setDefault: func(config *T) {
+			set(config, []E{})
+		},
+		resolve: func(config *T, value any, location configLocation, diagnostics *[]ConfigurationDiagnostic) {
+			values, ok := value.([]any)
+			if !ok {
+				*diagnostics = append(*diagnostics, location.diagnostic(
+					"Expected '%s' to be an array, but got %T.", location.path, value,
+				))
+				return
+			}
+
+			resolved := make([]E, 0, len(values))
+			for i, element := range values {
+				elementLocation := location.index(i)
+				object, ok := element.(map[string]any)
+				if !ok {
+					*diagnostics = append(*diagnostics, elementLocation.diagnostic(
+						"Expected '%s' to be an object, but got %T.", elementLocation.path, element,
+					))
+					continue
+				}
+
+				count := len(*diagnostics)
+				item := resolveObject(object, elementLocation, items, diagnostics)
+				if len(*diagnostics) == count {
+					resolved = append(resolved, item)
+				}
+			}
+			set(config, resolved)
+		},
+	}
+}
+
+// NewObjectConfigFieldSpec creates the spec of the object field key, whose
+// properties are resolved with fields. Like top-level properties, properties
+// that are not valid are reported and fall back to their defaults.
+func NewObjectConfigFieldSpec[T any, E any](
+	key string,
+	fields ConfigResolverSpec[E],
+	set func(config *T, value E),
+) ObjectConfigFieldSpec[T] {
+	return ObjectConfigFieldSpec[T]{
+		Key: key,
+		setDefault: func(config *T) {
+			set(config, defaultConfigurationFromSpec(fields))
+		},
+		resolve: func(config *T, value any, location configLocation, diagnostics *[]ConfigurationDiagnostic) {
+			object, ok := value.(map[string]any)
+			if !ok {
+				*diagnostics = append(*diagnostics, location.diagnostic(
+					"Expected '%s' to be an object, but got %T.", location.path, value,
+				))
+				return
+			}
+			set(config, resolveObject(object, location, fields, diagnostics))
+		},
+	}
 }
 
 // ResolveConfigWithSpec resolves plugin and global settings based on field specs.
@@ -36,15 +205,48 @@ func ResolveConfigWithSpec[T any](
 	global GlobalConfiguration,
 	spec ConfigResolverSpec[T],
 ) (T, []ConfigurationDiagnostic) {
-	diagnostics := unknownPropertyDiagnosticsWithKnownKeys(config, knownConfigKeys(spec))
+	var location configLocation
+	config, aliased, diagnostics := applyConfigAliases(config, spec.Aliases)
+	diagnostics = append(diagnostics, unknownPropertyDiagnosticsWithKnownKeys(config, knownConfigKeys(spec), spec.Spellings, location)...)
 	resolved := defaultConfigurationFromSpec(spec)
 
 	applyGlobalOverridesWithSpec(&resolved, global, spec, &diagnostics)
-	applyConfigValuesWithSpec(&resolved, config, spec, &diagnostics)
+	count := len(diagnostics)
+	applyConfigValuesWithSpec(&resolved, config, location, spec, &diagnostics)
+	for _, problem := range validateWithSpec(resolved, location, spec, &diagnostics) {
+		resetToDefault(&resolved, problem.Key, spec)
+	}
+	renameAliasedDiagnostics(diagnostics[count:], aliased)
 
 	return resolved, diagnostics
 }
 
+// resolveObject resolves an object nested in the configuration at location.
+func resolveObject[T any](
+	config map[string]any,
+	location configLocation,
+	spec ConfigResolverSpec[T],
+	diagnostics *[]ConfigurationDiagnostic,
+) T {
+	*diagnostics = append(*diagnostics, unknownPropertyDiagnosticsWithKnownKeys(config, knownConfigKeys(spec), spec.Spellings, location)...)
+	count := len(*diagnostics)
+	for _, key := range requiredConfigKeys(spec) {
+		if config[key] == nil {
+			child := location.child(key)
+			*diagnostics = append(*diagnostics, child.diagnostic("Expected '%s' to be set.", child.path))
+		}
+	}
+
+	resolved := defaultConfigurationFromSpec(spec)
+	applyConfigValuesWithSpec(&resolved, config, location, spec, diagnostics)
+	if len(*diagnostics) == count {
+		for _, problem := range validateWithSpec(resolved, location, spec, diagnostics) {
+			resetToDefault(&resolved, problem.Key, spec)
+		}
+	}
+	return resolved
+}
+
 func defaultConfigurationFromSpec[T any](spec ConfigResolverSpec[T]) T {
 	var resolved T
 
@@ -54,28 +256,147 @@ func defaultConfigurationFromSpec[T any](spec ConfigResolverSpec[T]) T {
 	for _, field := range spec.BoolFields {
 		field.Set(&resolved, field.DefaultValue)
 	}
+	for _, field := range spec.StringFields {
+		field.Set(&resolved, field.DefaultValue)
+	}
+	for _, field := range spec.StringSliceFields {
+		field.Set(&resolved, append([]string{}, field.DefaultValue...))
+	}
+	for _, field := range spec.StringMapFields {
+		field.Set(&resolved, cloneStringMap(field.DefaultValue))
+	}
+	for _, field := range spec.ObjectSliceFields {
+		field.setDefault(&resolved)
+	}
+	for _, field := range spec.ObjectFields {
+		field.setDefault(&resolved)
+	}
 
 	return resolved
 }
 
+// formatConfigValue writes a resolved value the way it appears in the
+// configuration: strings quoted and numbers in decimal.
+func formatConfigValue(value any) string {
+	if text, ok := value.(string); ok {
+		return strconv.Quote(text)
+	}
+	return fmt.Sprint(value)
+}
+
+// resetToDefault sets the field of key back to its default value.
+func resetToDefault[T any](resolved *T, key string, spec ConfigResolverSpec[T]) {
+	defaults := defaultConfigurationFromSpec(spec)
+	for _, field := range spec.UInt32Fields {
+		if field.Key == key {
+			field.Set(resolved, field.Get(defaults))
+		}
+	}
+	for _, field := range spec.BoolFields {
+		if field.Key == key {
+			field.Set(resolved, field.Get(defaults))
+		}
+	}
+	for _, field := range spec.StringFields {
+		if field.Key == key {
+			field.Set(resolved, field.Get(defaults))
+		}
+	}
+	for _, field := range spec.StringSliceFields {
+		if field.Key == key {
+			field.Set(resolved, append([]string{}, field.DefaultValue...))
+		}
+	}
+	for _, field := range spec.StringMapFields {
+		if field.Key == key {
+			field.Set(resolved, cloneStringMap(field.DefaultValue))
+		}
+	}
+	for _, field := range spec.ObjectSliceFields {
+		if field.Key == key {
+			field.setDefault(resolved)
+		}
+	}
+	for _, field := range spec.ObjectFields {
+		if field.Key == key {
+			field.setDefault(resolved)
+		}
+	}
+}
+
+func cloneStringMap(values map[string]string) map[string]string {
+	clone := make(map[string]string, len(values))
+	maps.Copy(clone, values)
+	return clone
+}
+
 func applyConfigValuesWithSpec[T any](
 	resolved *T,
 	config map[string]any,
+	location configLocation,
 	spec ConfigResolverSpec[T],
 	diagnostics *[]ConfigurationDiagnostic,
 ) {
 	for _, field := range spec.UInt32Fields {
 		field.Set(
 			resolved,
-			getUInt32(config, field.Key, field.Get(*resolved), diagnostics),
+			getUInt32(config, field, field.Get(*resolved), location, diagnostics),
 		)
 	}
 	for _, field := range spec.BoolFields {
 		field.Set(
 			resolved,
-			getBool(config, field.Key, field.Get(*resolved), diagnostics),
+			getBool(config, field.Key, field.Get(*resolved), location, diagnostics),
 		)
 	}
+	for _, field := range spec.StringFields {
+		field.Set(
+			resolved,
+			getString(config, field, field.Get(*resolved), location, diagnostics),
+		)
+	}
+	for _, field := range spec.StringSliceFields {
+		if values, ok := getStringSlice(config, field, location, diagnostics); ok {
+			field.Set(resolved, values)
+		}
+	}
+	for _, field := range spec.StringMapFields {
+		if values, ok := getStringMap(config, field, location, diagnostics); ok {
+			field.Set(resolved, values)
+		}
+	}
+	for _, field := range spec.ObjectSliceFields {
+		if value := config[field.Key]; value != nil {
+			field.resolve(resolved, value, location.child(field.Key), diagnostics)
+		}
+	}
+	for _, field := range spec.ObjectFields {
+		if value := config[field.Key]; value != nil {
+			field.resolve(resolved, value, location.child(field.Key), diagnostics)
+		}
+	}
+}
+
+// validateWithSpec reports the problems spec.Validate finds in resolved and
+// returns them.
+func validateWithSpec[T any](
+	resolved T,
+	location configLocation,
+	spec ConfigResolverSpec[T],
+	diagnostics *[]ConfigurationDiagnostic,
+) []ConfigProblem {
+	if spec.Validate == nil {
+		return nil
+	}
+
+	problems := spec.Validate(resolved)
+	for _, problem := range problems {
+		child := location.child(problem.Key)
+		*diagnostics = append(*diagnostics, child.diagnostic(
+			"Expected '%s' to be %s, but got %s.", child.path, problem.Expected, formatConfigValue(problem.Value),
+		))
+	}
+	return problems
 }
 
 func applyGlobalOverridesWithSpec[T any](
@@ -91,7 +412,7 @@ func applyGlobalOverridesWithSpec[T any](
 
 		field.Set(
 			resolved,
-			getUInt32(global, field.Key, field.Get(*resolved), diagnostics),
+			getUInt32(global, field, field.Get(*resolved), configLocation{}, diagnostics),
 		)
 	}
 
@@ -102,7 +423,18 @@ func applyGlobalOverridesWithSpec[T any](
 
 		field.Set(
 			resolved,
-			getBool(global, field.Key, field.Get(*resolved), diagnostics),
+			getBool(global, field.Key, field.Get(*resolved), configLocation{}, diagnostics),
+		)
+	}
+
+	for _, field := range spec.StringFields {
+		if !field.AllowGlobalOverride {
+			continue
+		}
+
+		field.Set(
+			resolved,
+			getString(global, field, field.Get(*resolved), configLocation{}, diagnostics),
 		)
 	}
 }
@@ -112,17 +444,57 @@ func knownConfigKeys[T any](spec ConfigResolverSpec[T]) []string {
 		return spec.KnownKeys
 	}
 
-	keys := make([]string, 0, len(spec.UInt32Fields)+len(spec.BoolFields))
+	keys := make([]string, 0)
 	for _, field := range spec.UInt32Fields {
 		keys = append(keys, field.Key)
 	}
 	for _, field := range spec.BoolFields {
 		keys = append(keys, field.Key)
 	}
+	for _, field := range spec.StringFields {
+		keys = append(keys, field.Key)
+	}
+	for _, field := range spec.StringSliceFields {
+		keys = append(keys, field.Key)
+	}
+	for _, field := range spec.StringMapFields {
+		keys = append(keys, field.Key)
+	}
+	for _, field := range spec.ObjectSliceFields {
+		keys = append(keys, field.Key)
+	}
+	for _, field := range spec.ObjectFields {
+		keys = append(keys, field.Key)
+	}
 	return keys
 }
 
-func unknownPropertyDiagnosticsWithKnownKeys(config map[string]any, knownKeys []string) []ConfigurationDiagnostic {
+func requiredConfigKeys[T any](spec ConfigResolverSpec[T]) []string {
+	keys := make([]string, 0)
+	for _, field := range spec.UInt32Fields {
+		if field.Required {
+			keys = append(keys, field.Key)
+		}
+	}
+	for _, field := range spec.BoolFields {
+		if field.Required {
+			keys = append(keys, field.Key)
+		}
+	}
+	for _, field := range spec.StringFields {
+		if field.Required {
+			keys = append(keys, field.Key)
+		}
+	}
+	return keys
+}
+
+func unknownPropertyDiagnosticsWithKnownKeys(
+	config map[string]any,
+	knownKeys []string,
+	spellings map[string]string,
+	location configLocation,
+) []ConfigurationDiagnostic {
 	if len(config) == 0 {
 		return nil
 	}
@@ -144,21 +516,29 @@ func unknownPropertyDiagnosticsWithKnownKeys(config map[string]any, knownKeys []
 
 	diagnostics := make([]ConfigurationDiagnostic, 0, len(unknownKeys))
 	for _, key := range unknownKeys {
-		diagnostics = append(diagnostics, ConfigurationDiagnostic{
-			"propertyName": key,
-			"message":      fmt.Sprintf("Unknown property '%s'.", key),
-		})
+		child := location.child(key)
+		suggestion, ok := suggestConfigKey(key, knownKeys, spellings)
+		if !ok {
+			diagnostics = append(diagnostics, child.diagnostic("Unknown property '%s'.", child.path))
+			continue
+		}
+
+		diagnostic := child.diagnostic("Unknown property '%s'. Did you mean '%s'?", child.path, location.child(suggestion).path)
+		diagnostic["suggestion"] = suggestion
+		diagnostics = append(diagnostics, diagnostic)
 	}
 
 	return diagnostics
 }
 
-func getUInt32(
+func getUInt32[T any](
 	config map[string]any,
-	key string,
+	field UInt32ConfigFieldSpec[T],
 	fallback uint32,
+	location configLocation,
 	diagnostics *[]ConfigurationDiagnostic,
 ) uint32 {
+	key := field.Key
 	value, ok := config[key]
 	if !ok {
 		return fallback
@@ -169,10 +549,24 @@ func getUInt32(
 
 	uintValue, ok := CoerceUInt32(value)
 	if !ok {
-		*diagnostics = append(*diagnostics, ConfigurationDiagnostic{
-			"propertyName": key,
-			"message":      fmt.Sprintf("Expected '%s' to be a non-negative integer, but got %T.", key, value),
-		})
+		child := location.child(key)
+		*diagnostics = append(*diagnostics, child.diagnostic(
+			"Expected '%s' to be a non-negative integer, but got %T.", child.path, value,
+		))
+		return fallback
+	}
+	if uintValue < field.Min {
+		child := location.child(key)
+		*diagnostics = append(*diagnostics, child.diagnostic(
+			"Expected '%s' to be at least %d, but got %d.", child.path, field.Min, uintValue,
+		))
+		return fallback
+	}
+	if field.HasMax && uintValue > field.Max {
+		child := location.child(key)
+		*diagnostics = append(*diagnostics, child.diagnostic(
+			"Expected '%s' to be at most %d, but got %d.", child.path, field.Max, uintValue,
+		))
 		return fallback
 	}
 
@@ -183,6 +577,7 @@ func getBool(
 	config map[string]any,
 	key string,
 	fallback bool,
+	location configLocation,
 	diagnostics *[]ConfigurationDiagnostic,
 ) bool {
 	value, ok := config[key]
@@ -195,12 +590,190 @@ func getBool(
 
 	boolValue, ok := CoerceBool(value)
 	if !ok {
-		*diagnostics = append(*diagnostics, ConfigurationDiagnostic{
-			"propertyName": key,
-			"message":      fmt.Sprintf("Expected '%s' to be a boolean, but got %T.", key, value),
-		})
+		child := location.child(key)
+		*diagnostics = append(*diagnostics, child.diagnostic(
+			"Expected '%s' to be a boolean, but got %T.", child.path, value,
+		))
 		return fallback
 	}
 
 	return boolValue
 }
+
+func getString[T any](
+	config map[string]any,
+	field StringConfigFieldSpec[T],
+	fallback string,
+	location configLocation,
+	diagnostics *[]ConfigurationDiagnostic,
+) string {
+	value, ok := config[field.Key]
+	if !ok {
+		return fallback
+	}
+	if value == nil {
+		return fallback
+	}
+
+	check := stringCheck{allowedValues: field.AllowedValues, pattern: field.Pattern}
+	text, ok := check.apply(value, location.child(field.Key), diagnostics)
+	if !ok {
+		return fallback
+	}
+	return text
+}
+
+func getStringSlice[T any](
+	config map[string]any,
+	field StringSliceConfigFieldSpec[T],
+	location configLocation,
+	diagnostics *[]ConfigurationDiagnostic,
+) ([]string, bool) {
+	value := config[field.Key]
+	if value == nil {
+		return nil, false
+	}
+
+	location = location.child(field.Key)
+	elements, ok := value.([]any)
+	if !ok {
+		*diagnostics = append(*diagnostics, location.diagnostic(
+			"Expected '%s' to be an array, but got %T.", location.path, value,
+		))
+		return nil, false
+	}
+
+	check := stringCheck{allowedValues: field.AllowedValues, pattern: field.Pattern}
+	values := make([]string, 0, len(elements))
+	for i, element := range elements {
+		if text, ok := check.apply(element, location.index(i), diagnostics); ok {
+			values = append(values, text)
+		}
+	}
+	return values, true
+}
+
+func getStringMap[T any](
+	config map[string]any,
+	field StringMapConfigFieldSpec[T],
+	location configLocation,
+	diagnostics *[]ConfigurationDiagnostic,
+) (map[string]string, bool) {
+	value := config[field.Key]
+	if value == nil {
+		return nil, false
+	}
+
+	location = location.child(field.Key)
+	entries, ok := value.(map[string]any)
+	if !ok {
+		*diagnostics = append(*diagnostics, location.diagnostic(
+			"Expected '%s' to be an object, but got %T.", location.path, value,
+		))
+		return nil, false
+	}
+
+	check := stringCheck{allowedValues: field.AllowedValues, pattern: field.Pattern}
+	values := make(map[string]string, len(entries))
+	for _, entry := range slices.Sorted(maps.Keys(entries)) {
+		if text, ok := check.apply(entries[entry], location.child(entry), diagnostics); ok {
+			values[entry] = text
+		}
+	}
+	return values, true
+}
+
+// stringCheck holds the constraints of the strings in a field.
+type stringCheck struct {
+	allowedValues []string
+	pattern       *regexp.Regexp
+}
+
+// apply reports value when it is not a string, not one of the allowed values
+// or does not match the pattern.
+func (c stringCheck) apply(
+	value any,
+	location configLocation,
+	diagnostics *[]ConfigurationDiagnostic,
+) (string, bool) {
+	text, ok := value.(string)
+	if len(c.allowedValues) > 0 && (!ok || !slices.Contains(c.allowedValues, text)) {
+		*diagnostics = append(*diagnostics, location.diagnostic(
+			"Expected '%s' to be one of %s, but got %#v.",
+			location.path,
+			quoteChoices(c.allowedValues),
+			value,
+		))
+		return "", false
+	}
+	if !ok {
+		*diagnostics = append(*diagnostics, location.diagnostic(
+			"Expected '%s' to be a string, but got %T.", location.path, value,
+		))
+		return "", false
+	}
+	if c.pattern != nil && !c.pattern.MatchString(text) {
+		*diagnostics = append(*diagnostics, location.diagnostic(
+			"Expected '%s' to match the pattern '%s', but got %#v.", location.path, c.pattern, value,
+		))
+		return "", false
+	}
+	return text, true
+}
+
+// quoteChoices lists the valid values of an option for a diagnostic, for
+// example "'auto', 'lf'".
+func quoteChoices(choices []string) string {
+	quoted := make([]string, len(choices))
+	for i, choice := range choices {
+		quoted[i] = "'" + choice + "'"
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// configLocation names a value in the configuration for diagnostics. property
+// is the top-level property the value belongs to and path is the way to it,
+// such as "overrides[2].files". The zero value is the configuration itself.
+type configLocation struct {
+	property string
+	path     string
+}
+
+// child returns the location of the property key of the object at l. Keys
+// that are not plain identifiers are quoted, as in fileNames[".bashrc"].
+func (l configLocation) child(key string) configLocation {
+	if l.path == "" {
+		return configLocation{property: key, path: key}
+	}
+	if isPlainConfigKey(key) {
+		return configLocation{property: l.property, path: l.path + "." + key}
+	}
+	return configLocation{property: l.property, path: l.path + "[" + strconv.Quote(key) + "]"}
+}
+
+// index returns the location of element i of the array at l.
+func (l configLocation) index(i int) configLocation {
+	return configLocation{property: l.property, path: fmt.Sprintf("%s[%d]", l.path, i)}
+}
+
+func (l configLocation) diagnostic(format string, args ...any) ConfigurationDiagnostic {
+	return ConfigurationDiagnostic{
+		"propertyName": l.property,
+		"message":      fmt.Sprintf(format, args...),
+	}
+}
+
+func isPlainConfigKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for i, r := range key {
+		switch {
+		case r == '_', r == '$', 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z':
+		case i > 0 && '0' <= r && r <= '9':
+		default:
+			return false
+		}
+	}
+	return true
+}
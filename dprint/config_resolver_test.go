@@ -2,6 +2,7 @@ package dprint
 
 import (
 	"encoding/json"
+	"regexp"
 	"testing"
 )
 
@@ -9,6 +10,8 @@ type resolveConfigSpecTestConfig struct {
 	IndentWidth uint32
 	UseTabs     bool
 	Minify      bool
+	NewLineKind string
+	Comment     string
 }
 
 var resolveConfigSpecTestSpec = ConfigResolverSpec[resolveConfigSpecTestConfig]{
@@ -16,6 +19,8 @@ var resolveConfigSpecTestSpec = ConfigResolverSpec[resolveConfigSpecTestConfig]{
 		{
 			Key:                 "indentWidth",
 			DefaultValue:        2,
+			Max:                 16,
+			HasMax:              true,
 			AllowGlobalOverride: true,
 			Get: func(config resolveConfigSpecTestConfig) uint32 {
 				return config.IndentWidth
@@ -49,11 +54,49 @@ var resolveConfigSpecTestSpec = ConfigResolverSpec[resolveConfigSpecTestConfig]{
 			},
 		},
 	},
+	StringFields: []StringConfigFieldSpec[resolveConfigSpecTestConfig]{
+		{
+			Key:                 "newLineKind",
+			DefaultValue:        "auto",
+			AllowedValues:       []string{"auto", "lf", "crlf"},
+			AllowGlobalOverride: true,
+			Get: func(config resolveConfigSpecTestConfig) string {
+				return config.NewLineKind
+			},
+			Set: func(config *resolveConfigSpecTestConfig, value string) {
+				config.NewLineKind = value
+			},
+		},
+		{
+			Key:                 "comment",
+			DefaultValue:        "#",
+			Pattern:             regexp.MustCompile(`^\S+$`),
+			AllowGlobalOverride: false,
+			Get: func(config resolveConfigSpecTestConfig) string {
+				return config.Comment
+			},
+			Set: func(config *resolveConfigSpecTestConfig, value string) {
+				config.Comment = value
+			},
+		},
+	},
 	KnownKeys: []string{
 		"locked",
 		"indentWidth",
 		"useTabs",
 		"minify",
+		"newLineKind",
+		"comment",
+	},
+	Spellings: map[string]string{
+		"mn":     "minify",
+		"indent": "indentWidth",
+	},
+	Validate: func(config resolveConfigSpecTestConfig) []ConfigProblem {
+		if config.IndentWidth == 0 && !config.UseTabs {
+			return []ConfigProblem{{Key: "indentWidth", Expected: "at least 1 when 'useTabs' is false", Value: config.IndentWidth}}
+		}
+		return nil
 	},
 }
 
@@ -73,6 +116,12 @@ func TestResolveConfigWithSpecDefaults(t *testing.T) {
 	if resolved.Minify {
 		t.Fatal("expected minify=false")
 	}
+	if resolved.NewLineKind != "auto" {
+		t.Fatalf("expected newLineKind=auto, got %q", resolved.NewLineKind)
+	}
+	if resolved.Comment != "#" {
+		t.Fatalf("expected comment=#, got %q", resolved.Comment)
+	}
 	if len(diagnostics) != 0 {
 		t.Fatalf("expected no diagnostics, got %d", len(diagnostics))
 	}
@@ -124,6 +173,49 @@ func TestResolveConfigWithSpecAllowsKnownExtraKey(t *testing.T) {
 	}
 }
 
+func TestResolveConfigWithSpecSuggestsKeys(t *testing.T) {
+	cases := []struct {
+		name       string
+		key        string
+		suggestion string
+	}{
+		{name: "typo", key: "useTbas", suggestion: "useTabs"},
+		{name: "snake case", key: "new_line_kind", suggestion: "newLineKind"},
+		{name: "different case", key: "IndentWidth", suggestion: "indentWidth"},
+		{name: "spelling", key: "mn", suggestion: "minify"},
+		{name: "snake case spelling", key: "Indent", suggestion: "indentWidth"},
+		{name: "no close key", key: "colour"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, diagnostics := ResolveConfigWithSpec(
+				ConfigKeyMap{tc.key: true},
+				GlobalConfiguration{},
+				resolveConfigSpecTestSpec,
+			)
+
+			if len(diagnostics) != 1 {
+				t.Fatalf("expected 1 diagnostic, got %#v", diagnostics)
+			}
+			message := "Unknown property '" + tc.key + "'."
+			if tc.suggestion != "" {
+				message += " Did you mean '" + tc.suggestion + "'?"
+			}
+			if diagnostics[0]["message"] != message {
+				t.Fatalf("expected message %q, got %q", message, diagnostics[0]["message"])
+			}
+			suggestion, ok := diagnostics[0]["suggestion"]
+			if tc.suggestion == "" && ok {
+				t.Fatalf("expected no suggestion, got %#v", suggestion)
+			}
+			if tc.suggestion != "" && suggestion != tc.suggestion {
+				t.Fatalf("expected suggestion %q, got %#v", tc.suggestion, suggestion)
+			}
+		})
+	}
+}
+
 func TestResolveConfigWithSpecIgnoresNilValues(t *testing.T) {
 	resolved, diagnostics := ResolveConfigWithSpec(
 		ConfigKeyMap{
@@ -147,3 +239,493 @@ func TestResolveConfigWithSpecIgnoresNilValues(t *testing.T) {
 		t.Fatalf("expected no diagnostics, got %d", len(diagnostics))
 	}
 }
+
+func TestResolveConfigWithSpecResolvesStrings(t *testing.T) {
+	resolved, diagnostics := ResolveConfigWithSpec(
+		ConfigKeyMap{
+			"comment": "//",
+		},
+		GlobalConfiguration{
+			"newLineKind": "crlf",
+			"comment":     ";",
+		},
+		resolveConfigSpecTestSpec,
+	)
+
+	if resolved.NewLineKind != "crlf" {
+		t.Fatalf("expected global newLineKind=crlf, got %q", resolved.NewLineKind)
+	}
+	if resolved.Comment != "//" {
+		t.Fatalf("expected comment=//, got %q", resolved.Comment)
+	}
+	if len(diagnostics) != 0 {
+		t.Fatalf("expected no diagnostics, got %#v", diagnostics)
+	}
+}
+
+func TestResolveConfigWithSpecReportsInvalidStrings(t *testing.T) {
+	cases := []struct {
+		name    string
+		config  ConfigKeyMap
+		message string
+	}{
+		{
+			name:    "value outside the allowed values",
+			config:  ConfigKeyMap{"newLineKind": "cr"},
+			message: "Expected 'newLineKind' to be one of 'auto', 'lf', 'crlf', but got \"cr\".",
+		},
+		{
+			name:    "non-string enum value",
+			config:  ConfigKeyMap{"newLineKind": true},
+			message: "Expected 'newLineKind' to be one of 'auto', 'lf', 'crlf', but got true.",
+		},
+		{
+			name:    "non-string value",
+			config:  ConfigKeyMap{"comment": float64(1)},
+			message: "Expected 'comment' to be a string, but got float64.",
+		},
+		{
+			name:    "value not matching the pattern",
+			config:  ConfigKeyMap{"comment": "# "},
+			message: "Expected 'comment' to match the pattern '^\\S+$', but got \"# \".",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resolved, diagnostics := ResolveConfigWithSpec(
+				tc.config,
+				GlobalConfiguration{},
+				resolveConfigSpecTestSpec,
+			)
+
+			if resolved.NewLineKind != "auto" || resolved.Comment != "#" {
+				t.Fatalf("expected fallback values, got %#v", resolved)
+			}
+			if len(diagnostics) != 1 {
+				t.Fatalf("expected 1 diagnostic, got %#v", diagnostics)
+			}
+			if diagnostics[0]["message"] != tc.message {
+				t.Fatalf("expected message %q, got %q", tc.message, diagnostics[0]["message"])
+			}
+		})
+	}
+}
+
+type resolveCollectionsTestConfig struct {
+	Tags      []string
+	Languages map[string]string
+	Rules     []resolveCollectionsTestRule
+	Limits    resolveCollectionsTestLimits
+}
+
+type resolveCollectionsTestRule struct {
+	Name  string
+	Width uint32
+}
+
+type resolveCollectionsTestLimits struct {
+	Width uint32
+	Unit  string
+}
+
+var resolveCollectionsTestSpec = ConfigResolverSpec[resolveCollectionsTestConfig]{
+	StringSliceFields: []StringSliceConfigFieldSpec[resolveCollectionsTestConfig]{
+		{
+			Key:           "tags",
+			DefaultValue:  []string{"default"},
+			AllowedValues: []string{"default", "fast", "slow"},
+			Set: func(config *resolveCollectionsTestConfig, value []string) {
+				config.Tags = value
+			},
+		},
+	},
+	StringMapFields: []StringMapConfigFieldSpec[resolveCollectionsTestConfig]{
+		{
+			Key: "languages",
+			Set: func(config *resolveCollectionsTestConfig, value map[string]string) {
+				config.Languages = value
+			},
+		},
+	},
+	ObjectSliceFields: []ObjectSliceConfigFieldSpec[resolveCollectionsTestConfig]{
+		NewObjectSliceConfigFieldSpec(
+			"rules",
+			ConfigResolverSpec[resolveCollectionsTestRule]{
+				UInt32Fields: []UInt32ConfigFieldSpec[resolveCollectionsTestRule]{
+					{
+						Key:          "width",
+						DefaultValue: 80,
+						Get: func(config resolveCollectionsTestRule) uint32 {
+							return config.Width
+						},
+						Set: func(config *resolveCollectionsTestRule, value uint32) {
+							config.Width = value
+						},
+					},
+				},
+				StringFields: []StringConfigFieldSpec[resolveCollectionsTestRule]{
+					{
+						Key:      "name",
+						Required: true,
+						Get: func(config resolveCollectionsTestRule) string {
+							return config.Name
+						},
+						Set: func(config *resolveCollectionsTestRule, value string) {
+							config.Name = value
+						},
+					},
+				},
+				KnownKeys: []string{"name", "width"},
+				Validate: func(config resolveCollectionsTestRule) []ConfigProblem {
+					if config.Name == "" {
+						return []ConfigProblem{{Key: "name", Expected: "a non-empty name", Value: config.Name}}
+					}
+					return nil
+				},
+			},
+			func(config *resolveCollectionsTestConfig, value []resolveCollectionsTestRule) {
+				config.Rules = value
+			},
+		),
+	},
+	ObjectFields: []ObjectConfigFieldSpec[resolveCollectionsTestConfig]{
+		NewObjectConfigFieldSpec(
+			"limits",
+			ConfigResolverSpec[resolveCollectionsTestLimits]{
+				UInt32Fields: []UInt32ConfigFieldSpec[resolveCollectionsTestLimits]{
+					{
+						Key:          "width",
+						DefaultValue: 80,
+						Get: func(config resolveCollectionsTestLimits) uint32 {
+							return config.Width
+						},
+						Set: func(config *resolveCollectionsTestLimits, value uint32) {
+							config.Width = value
+						},
+					},
+				},
+				StringFields: []StringConfigFieldSpec[resolveCollectionsTestLimits]{
+					{
+						Key:           "unit",
+						DefaultValue:  "columns",
+						AllowedValues: []string{"columns", "bytes"},
+						Get: func(config resolveCollectionsTestLimits) string {
+							return config.Unit
+						},
+						Set: func(config *resolveCollectionsTestLimits, value string) {
+							config.Unit = value
+						},
+					},
+				},
+				KnownKeys: []string{"width", "unit"},
+				Validate: func(config resolveCollectionsTestLimits) []ConfigProblem {
+					if config.Width == 0 {
+						return []ConfigProblem{{Key: "width", Expected: "above 0", Value: config.Width}}
+					}
+					return nil
+				},
+			},
+			func(config *resolveCollectionsTestConfig, value resolveCollectionsTestLimits) {
+				config.Limits = value
+			},
+		),
+	},
+}
+
+var resolveCollectionsTestDefaultLimits = resolveCollectionsTestLimits{Width: 80, Unit: "columns"}
+
+func TestResolveConfigWithSpecCollectionDefaults(t *testing.T) {
+	resolved, diagnostics := ResolveConfigWithSpec(
+		ConfigKeyMap{},
+		GlobalConfiguration{},
+		resolveCollectionsTestSpec,
+	)
+
+	if len(resolved.Tags) != 1 || resolved.Tags[0] != "default" {
+		t.Fatalf("expected default tags, got %#v", resolved.Tags)
+	}
+	if resolved.Languages == nil || len(resolved.Languages) != 0 {
+		t.Fatalf("expected empty languages, got %#v", resolved.Languages)
+	}
+	if resolved.Rules == nil || len(resolved.Rules) != 0 {
+		t.Fatalf("expected empty rules, got %#v", resolved.Rules)
+	}
+	if resolved.Limits != resolveCollectionsTestDefaultLimits {
+		t.Fatalf("expected default limits, got %#v", resolved.Limits)
+	}
+	if len(diagnostics) != 0 {
+		t.Fatalf("expected no diagnostics, got %#v", diagnostics)
+	}
+}
+
+func TestResolveConfigWithSpecResolvesCollections(t *testing.T) {
+	resolved, diagnostics := ResolveConfigWithSpec(
+		ConfigKeyMap{
+			"tags":      []any{"fast", "slow"},
+			"languages": map[string]any{"JSON": "json"},
+			"rules": []any{
+				map[string]any{"name": "a"},
+				map[string]any{"name": "b", "width": float64(100)},
+			},
+			"limits": map[string]any{"unit": "bytes"},
+		},
+		GlobalConfiguration{},
+		resolveCollectionsTestSpec,
+	)
+
+	if len(diagnostics) != 0 {
+		t.Fatalf("expected no diagnostics, got %#v", diagnostics)
+	}
+	if len(resolved.Tags) != 2 || resolved.Tags[0] != "fast" || resolved.Tags[1] != "slow" {
+		t.Fatalf("unexpected tags: %#v", resolved.Tags)
+	}
+	if len(resolved.Languages) != 1 || resolved.Languages["JSON"] != "json" {
+		t.Fatalf("unexpected languages: %#v", resolved.Languages)
+	}
+	want := []resolveCollectionsTestRule{{Name: "a", Width: 80}, {Name: "b", Width: 100}}
+	if len(resolved.Rules) != len(want) || resolved.Rules[0] != want[0] || resolved.Rules[1] != want[1] {
+		t.Fatalf("unexpected rules: %#v", resolved.Rules)
+	}
+	if resolved.Limits != (resolveCollectionsTestLimits{Width: 80, Unit: "bytes"}) {
+		t.Fatalf("unexpected limits: %#v", resolved.Limits)
+	}
+}
+
+func TestResolveConfigWithSpecReportsCollectionPaths(t *testing.T) {
+	cases := []struct {
+		name     string
+		config   ConfigKeyMap
+		property string
+		message  string
+	}{
+		{
+			name:     "array type",
+			config:   ConfigKeyMap{"tags": "fast"},
+			property: "tags",
+			message:  "Expected 'tags' to be an array, but got string.",
+		},
+		{
+			name:     "array element",
+			config:   ConfigKeyMap{"tags": []any{"fast", "never"}},
+			property: "tags",
+			message:  "Expected 'tags[1]' to be one of 'default', 'fast', 'slow', but got \"never\".",
+		},
+		{
+			name:     "map type",
+			config:   ConfigKeyMap{"languages": []any{}},
+			property: "languages",
+			message:  "Expected 'languages' to be an object, but got []interface {}.",
+		},
+		{
+			name:     "map entry with a quoted key",
+			config:   ConfigKeyMap{"languages": map[string]any{"a.b": true}},
+			property: "languages",
+			message:  "Expected 'languages[\"a.b\"]' to be a string, but got bool.",
+		},
+		{
+			name:     "object element type",
+			config:   ConfigKeyMap{"rules": []any{map[string]any{"name": "a"}, "b"}},
+			property: "rules",
+			message:  "Expected 'rules[1]' to be an object, but got string.",
+		},
+		{
+			name:     "unknown nested property",
+			config:   ConfigKeyMap{"rules": []any{map[string]any{"name": "a", "extra": true}}},
+			property: "rules",
+			message:  "Unknown property 'rules[0].extra'.",
+		},
+		{
+			name:     "misspelled nested property",
+			config:   ConfigKeyMap{"rules": []any{map[string]any{"name": "a", "widht": float64(1)}}},
+			property: "rules",
+			message:  "Unknown property 'rules[0].widht'. Did you mean 'rules[0].width'?",
+		},
+		{
+			name:     "missing required property",
+			config:   ConfigKeyMap{"rules": []any{map[string]any{"width": float64(1)}}},
+			property: "rules",
+			message:  "Expected 'rules[0].name' to be set.",
+		},
+		{
+			name:     "nested value type",
+			config:   ConfigKeyMap{"rules": []any{map[string]any{"name": "a", "width": "wide"}}},
+			property: "rules",
+			message:  "Expected 'rules[0].width' to be a non-negative integer, but got string.",
+		},
+		{
+			name:     "validation problem",
+			config:   ConfigKeyMap{"rules": []any{map[string]any{"name": ""}}},
+			property: "rules",
+			message:  "Expected 'rules[0].name' to be a non-empty name, but got \"\".",
+		},
+		{
+			name:     "object type",
+			config:   ConfigKeyMap{"limits": []any{}},
+			property: "limits",
+			message:  "Expected 'limits' to be an object, but got []interface {}.",
+		},
+		{
+			name:     "unknown object property",
+			config:   ConfigKeyMap{"limits": map[string]any{"height": float64(1)}},
+			property: "limits",
+			message:  "Unknown property 'limits.height'.",
+		},
+		{
+			name:     "object property value",
+			config:   ConfigKeyMap{"limits": map[string]any{"unit": "lines"}},
+			property: "limits",
+			message:  "Expected 'limits.unit' to be one of 'columns', 'bytes', but got \"lines\".",
+		},
+		{
+			name:     "object validation problem",
+			config:   ConfigKeyMap{"limits": map[string]any{"width": float64(0)}},
+			property: "limits",
+			message:  "Expected 'limits.width' to be above 0, but got 0.",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resolved, diagnostics := ResolveConfigWithSpec(
+				tc.config,
+				GlobalConfiguration{},
+				resolveCollectionsTestSpec,
+			)
+
+			if len(diagnostics) != 1 {
+				t.Fatalf("expected 1 diagnostic, got %#v", diagnostics)
+			}
+			if diagnostics[0]["propertyName"] != tc.property {
+				t.Fatalf("expected property %q, got %q", tc.property, diagnostics[0]["propertyName"])
+			}
+			if diagnostics[0]["message"] != tc.message {
+				t.Fatalf("expected message %q, got %q", tc.message, diagnostics[0]["message"])
+			}
+			for _, rule := range resolved.Rules {
+				if rule.Name == "" || rule.Width != 80 {
+					t.Fatalf("expected invalid rules to be left out, got %#v", resolved.Rules)
+				}
+			}
+			if resolved.Limits != resolveCollectionsTestDefaultLimits {
+				t.Fatalf("expected invalid limits to fall back to defaults, got %#v", resolved.Limits)
+			}
+		})
+	}
+}
+
+func TestResolveConfigWithSpecChecksBounds(t *testing.T) {
+	cases := []struct {
+		name     string
+		config   ConfigKeyMap
+		global   GlobalConfiguration
+		want     uint32
+		messages []string
+	}{
+		{
+			name:   "within bounds",
+			config: ConfigKeyMap{"indentWidth": float64(16)},
+			want:   16,
+		},
+		{
+			name:     "above the maximum",
+			config:   ConfigKeyMap{"indentWidth": float64(500)},
+			want:     2,
+			messages: []string{"Expected 'indentWidth' to be at most 16, but got 500."},
+		},
+		{
+			name:     "global value above the maximum",
+			global:   GlobalConfiguration{"indentWidth": float64(17)},
+			want:     2,
+			messages: []string{"Expected 'indentWidth' to be at most 16, but got 17."},
+		},
+		{
+			name:     "rejected by the validator",
+			config:   ConfigKeyMap{"indentWidth": float64(0)},
+			want:     2,
+			messages: []string{"Expected 'indentWidth' to be at least 1 when 'useTabs' is false, but got 0."},
+		},
+		{
+			name:   "accepted by the validator",
+			config: ConfigKeyMap{"indentWidth": float64(0), "useTabs": true},
+			want:   0,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resolved, diagnostics := ResolveConfigWithSpec(tc.config, tc.global, resolveConfigSpecTestSpec)
+
+			if resolved.IndentWidth != tc.want {
+				t.Fatalf("expected indentWidth=%d, got %d", tc.want, resolved.IndentWidth)
+			}
+			if len(diagnostics) != len(tc.messages) {
+				t.Fatalf("expected %d diagnostics, got %#v", len(tc.messages), diagnostics)
+			}
+			for i, message := range tc.messages {
+				if diagnostics[i]["message"] != message {
+					t.Fatalf("expected message %q, got %q", message, diagnostics[i]["message"])
+				}
+				if diagnostics[i]["propertyName"] != "indentWidth" {
+					t.Fatalf("expected indentWidth diagnostic, got %#v", diagnostics[i])
+				}
+			}
+		})
+	}
+}
+
+func TestResolveConfigWithSpecChecksMaximumOfZero(t *testing.T) {
+	spec := ConfigResolverSpec[resolveConfigSpecTestConfig]{
+		UInt32Fields: []UInt32ConfigFieldSpec[resolveConfigSpecTestConfig]{
+			{
+				Key:    "indentWidth",
+				Max:    0,
+				HasMax: true,
+				Get: func(config resolveConfigSpecTestConfig) uint32 {
+					return config.IndentWidth
+				},
+				Set: func(config *resolveConfigSpecTestConfig, value uint32) {
+					config.IndentWidth = value
+				},
+			},
+		},
+	}
+
+	resolved, diagnostics := ResolveConfigWithSpec(ConfigKeyMap{"indentWidth": float64(1)}, nil, spec)
+
+	if resolved.IndentWidth != 0 {
+		t.Fatalf("expected fallback indentWidth=0, got %d", resolved.IndentWidth)
+	}
+	want := "Expected 'indentWidth' to be at most 0, but got 1."
+	if len(diagnostics) != 1 || diagnostics[0]["message"] != want {
+		t.Fatalf("expected message %q, got %#v", want, diagnostics)
+	}
+}
+
+func TestResolveConfigWithSpecChecksMinimum(t *testing.T) {
+	spec := ConfigResolverSpec[resolveConfigSpecTestConfig]{
+		UInt32Fields: []UInt32ConfigFieldSpec[resolveConfigSpecTestConfig]{
+			{
+				Key:          "indentWidth",
+				DefaultValue: 4,
+				Min:          2,
+				Get: func(config resolveConfigSpecTestConfig) uint32 {
+					return config.IndentWidth
+				},
+				Set: func(config *resolveConfigSpecTestConfig, value uint32) {
+					config.IndentWidth = value
+				},
+			},
+		},
+	}
+
+	resolved, diagnostics := ResolveConfigWithSpec(ConfigKeyMap{"indentWidth": float64(1)}, nil, spec)
+
+	if resolved.IndentWidth != 4 {
+		t.Fatalf("expected fallback indentWidth=4, got %d", resolved.IndentWidth)
+	}
+	want := "Expected 'indentWidth' to be at least 2, but got 1."
+	if len(diagnostics) != 1 || diagnostics[0]["message"] != want {
+		t.Fatalf("expected message %q, got %#v", want, diagnostics)
+	}
+}
@@ -0,0 +1,79 @@
+package dprint
+
+import (
+	"maps"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// suggestConfigKey returns the known key an unknown property was most likely
+// meant to be. Keys are compared without regard to case, underscores and
+// hyphens, so snake_case and kebab-case spellings match their camelCase keys.
+// Other spellings, such as shfmt flag names, are looked up in spellings;
+// beyond those, the closest known key within a few edits is suggested.
+func suggestConfigKey(key string, knownKeys []string, spellings map[string]string) (string, bool) {
+	normalized := normalizeConfigKey(key)
+	if normalized == "" {
+		return "", false
+	}
+
+	for _, spelling := range slices.Sorted(maps.Keys(spellings)) {
+		target := spellings[spelling]
+		if normalizeConfigKey(spelling) == normalized && slices.Contains(knownKeys, target) {
+			return target, true
+		}
+	}
+
+	suggestion := ""
+	bestDistance := 0
+	for _, known := range knownKeys {
+		normalizedKnown := normalizeConfigKey(known)
+		distance := editDistance(normalized, normalizedKnown)
+		if distance > max(1, utf8.RuneCountInString(normalizedKnown)/3) {
+			continue
+		}
+		if suggestion == "" || distance < bestDistance {
+			suggestion, bestDistance = known, distance
+		}
+	}
+	return suggestion, suggestion != ""
+}
+
+func normalizeConfigKey(key string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '_' || r == '-' {
+			return -1
+		}
+		return r
+	}, strings.ToLower(key))
+}
+
+// editDistance returns the number of single-character insertions,
+// deletions, substitutions and transpositions of adjacent characters that
+// turn a into b (the optimal string alignment distance).
+func editDistance(a, b string) int {
+	source, target := []rune(a), []rune(b)
+	rows := make([][]int, len(source)+1)
+	for i := range rows {
+		rows[i] = make([]int, len(target)+1)
+		rows[i][0] = i
+	}
+	for j := range rows[0] {
+		rows[0][j] = j
+	}
+
+	for i := 1; i <= len(source); i++ {
+		for j := 1; j <= len(target); j++ {
+			cost := 1
+			if source[i-1] == target[j-1] {
+				cost = 0
+			}
+			rows[i][j] = min(rows[i-1][j]+1, rows[i][j-1]+1, rows[i-1][j-1]+cost)
+			if i > 1 && j > 1 && source[i-1] == target[j-2] && source[i-2] == target[j-1] {
+				rows[i][j] = min(rows[i][j], rows[i-2][j-2]+1)
+			}
+		}
+	}
+	return rows[len(source)][len(target)]
+}
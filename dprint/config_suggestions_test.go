@@ -0,0 +1,25 @@
+package dprint
+
+import "testing"
+
+func TestEditDistance(t *testing.T) {
+	cases := []struct {
+		a    string
+		b    string
+		want int
+	}{
+		{a: "", b: "", want: 0},
+		{a: "", b: "abc", want: 3},
+		{a: "kitten", b: "sitting", want: 3},
+		{a: "switchcaseident", b: "switchcaseindent", want: 1},
+		{a: "usetbas", b: "usetabs", want: 1},
+		{a: "ca", b: "abc", want: 3},
+		{a: "größe", b: "grösse", want: 2},
+	}
+
+	for _, tc := range cases {
+		if got := editDistance(tc.a, tc.b); got != tc.want {
+			t.Fatalf("editDistance(%q, %q) = %d, want %d", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
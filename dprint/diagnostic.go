@@ -0,0 +1,99 @@
+package dprint
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Severity describes how serious a diagnostic is.
+type Severity string
+
+// Diagnostic severities.
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// Position is a 1-based line and column in a file. Columns count bytes. The
+// zero Position means the location is unknown.
+type Position struct {
+	Line   uint32 `json:"line"`
+	Column uint32 `json:"column"`
+}
+
+// IsValid reports whether the position is known.
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+// Diagnostic is a format error tied to a location in the file, so that hosts
+// and editor integrations do not have to parse it back out of error text.
+// End is the position just past the reported span and may equal Start.
+type Diagnostic struct {
+	FilePath string   `json:"filePath"`
+	Start    Position `json:"start"`
+	End      Position `json:"end"`
+	Severity Severity `json:"severity"`
+	Code     string   `json:"code"`
+	Message  string   `json:"message"`
+	Hint     string   `json:"hint,omitempty"`
+}
+
+// Error renders the diagnostic as "path:line:col: message [code]", followed
+// by the hint on its own line. Parts that are not known are left out.
+func (d *Diagnostic) Error() string {
+	text := d.headline()
+	if d.Hint != "" {
+		text += "\n  hint: " + d.Hint
+	}
+	return text
+}
+
+func (d *Diagnostic) headline() string {
+	var text strings.Builder
+	text.WriteString(d.FilePath)
+	if d.Start.IsValid() {
+		if text.Len() > 0 {
+			text.WriteString(":")
+		}
+		fmt.Fprintf(&text, "%d:%d", d.Start.Line, d.Start.Column)
+	}
+	if text.Len() > 0 {
+		text.WriteString(": ")
+	}
+	text.WriteString(d.Message)
+	if d.Code != "" {
+		fmt.Fprintf(&text, " [%s]", d.Code)
+	}
+	return text.String()
+}
+
+// Diagnostics reports several diagnostics as one error. Each diagnostic is
+// rendered on its own line, and hints shared by several of them are written
+// once, after all of them.
+type Diagnostics []*Diagnostic
+
+func (d Diagnostics) Error() string {
+	lines := make([]string, 0, len(d))
+	var hints []string
+	for _, diagnostic := range d {
+		lines = append(lines, diagnostic.headline())
+		if diagnostic.Hint != "" && !slices.Contains(hints, diagnostic.Hint) {
+			hints = append(hints, diagnostic.Hint)
+		}
+	}
+	for _, hint := range hints {
+		lines = append(lines, "  hint: "+hint)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Unwrap returns the individual diagnostics for errors.As.
+func (d Diagnostics) Unwrap() []error {
+	errs := make([]error, len(d))
+	for i, diagnostic := range d {
+		errs[i] = diagnostic
+	}
+	return errs
+}
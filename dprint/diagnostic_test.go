@@ -0,0 +1,92 @@
+package dprint
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestDiagnosticError(t *testing.T) {
+	cases := []struct {
+		name       string
+		diagnostic Diagnostic
+		expected   string
+	}{
+		{
+			name: "position-and-code",
+			diagnostic: Diagnostic{
+				FilePath: "a.sh",
+				Start:    Position{Line: 2, Column: 5},
+				Code:     "syntax-error",
+				Message:  "oops",
+			},
+			expected: "a.sh:2:5: oops [syntax-error]",
+		},
+		{
+			name:       "no-position",
+			diagnostic: Diagnostic{FilePath: "a.sh", Message: "oops"},
+			expected:   "a.sh: oops",
+		},
+		{
+			name:       "message-only",
+			diagnostic: Diagnostic{Message: "oops"},
+			expected:   "oops",
+		},
+		{
+			name:       "no-path",
+			diagnostic: Diagnostic{Start: Position{Line: 3, Column: 2}, Message: "oops"},
+			expected:   "3:2: oops",
+		},
+		{
+			name:       "hint",
+			diagnostic: Diagnostic{FilePath: "a.sh", Start: Position{Line: 1, Column: 1}, Message: "oops", Hint: "try again"},
+			expected:   "a.sh:1:1: oops\n  hint: try again",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.diagnostic.Error(); got != tc.expected {
+				t.Fatalf("expected %q, got %q", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestDiagnosticsError(t *testing.T) {
+	diagnostics := Diagnostics{
+		{FilePath: "a.sh", Start: Position{Line: 1, Column: 1}, Code: "x", Message: "first", Hint: "shared"},
+		{FilePath: "a.sh", Start: Position{Line: 3, Column: 2}, Code: "x", Message: "second", Hint: "shared"},
+		{FilePath: "a.sh", Code: "y", Message: "third"},
+	}
+
+	expected := "a.sh:1:1: first [x]\na.sh:3:2: second [x]\na.sh: third [y]\n  hint: shared"
+	if got := diagnostics.Error(); got != expected {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+
+	var diagnostic *Diagnostic
+	if !errors.As(fmt.Errorf("wrapped: %w", diagnostics), &diagnostic) || diagnostic != diagnostics[0] {
+		t.Fatalf("expected errors.As to find the first diagnostic, got %v", diagnostic)
+	}
+}
+
+func TestDiagnosticJSON(t *testing.T) {
+	data, err := json.Marshal(Diagnostic{
+		FilePath: "a.sh",
+		Start:    Position{Line: 1, Column: 2},
+		End:      Position{Line: 1, Column: 4},
+		Severity: SeverityError,
+		Code:     "syntax-error",
+		Message:  "oops",
+	})
+	if err != nil {
+		t.Fatalf("marshal failed: %v", err)
+	}
+
+	expected := `{"filePath":"a.sh","start":{"line":1,"column":2},"end":{"line":1,"column":4},"severity":"error","code":"syntax-error","message":"oops"}`
+	if string(data) != expected {
+		t.Fatalf("expected %s, got %s", expected, data)
+	}
+}
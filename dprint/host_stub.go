@@ -1,6 +1,6 @@
 //go:build !wasm
 
-// Package dprint provides minimal types and runtime glue for dprint Wasm plugins.
+// Package dprint provides minimal types and runtime glue for dprint Wasm and process plugins.
 package dprint
 
 func hostWriteBuffer(_ uint32) {
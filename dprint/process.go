@@ -0,0 +1,351 @@
+//go:build !wasm
+
+package dprint
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// ProcessServer runs a plugin handler as a dprint process plugin: a native
+// executable that talks to dprint through messages on its stdin and stdout.
+// Unlike the Wasm runtime, it formats several files at once, so the handler
+// must be safe for concurrent use.
+type ProcessServer[T any] struct {
+	handler SyncPluginHandler[T]
+	configs *configStore[T]
+
+	writer  *processWriter
+	lastID  atomic.Uint32
+	formats sync.WaitGroup
+	// closed is closed once the host stops sending messages.
+	closed chan struct{}
+
+	mu sync.Mutex
+	// cancellations holds the tokens of format requests in progress, by the
+	// id of their Format message.
+	cancellations map[uint32]*processCancellationToken
+	// hostFormats holds the channels waiting for the host's answer to
+	// HostFormat messages, by message id.
+	hostFormats map[uint32]chan processMessage
+	writeErr    error
+}
+
+// NewProcessServer creates a process plugin server for the provided
+// synchronous plugin handler.
+func NewProcessServer[T any](handler SyncPluginHandler[T]) *ProcessServer[T] {
+	if handler == nil {
+		panic("handler is required")
+	}
+
+	return &ProcessServer[T]{
+		handler:       handler,
+		configs:       newConfigStore(handler),
+		closed:        make(chan struct{}),
+		cancellations: make(map[uint32]*processCancellationToken),
+		hostFormats:   make(map[uint32]chan processMessage),
+	}
+}
+
+// Serve answers the messages the host writes to reader, writing responses to
+// writer, until the host closes the plugin or reader ends. Format requests are
+// answered as they finish, which may be out of order. Serve waits for them
+// before returning and may only be called once.
+func (s *ProcessServer[T]) Serve(reader io.Reader, writer io.Writer) error {
+	messages := newProcessReader(reader)
+	s.writer = newProcessWriter(writer)
+
+	// The host starts by asking for the schema version with a 0, which is
+	// answered with a 0 for success and the version.
+	request, err := messages.readUint32()
+	if err != nil {
+		return fmt.Errorf("failed to read the schema version request: %w", err)
+	}
+	if request != 0 {
+		return fmt.Errorf("expected a schema version request of 0, got %d", request)
+	}
+	if err := s.writer.writeUint32(0, ProcessPluginSchemaVersion); err != nil {
+		return err
+	}
+
+	err = s.serveMessages(messages)
+
+	close(s.closed)
+	s.formats.Wait()
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.writeErr
+}
+
+func (s *ProcessServer[T]) serveMessages(messages *processReader) error {
+	for {
+		message, err := messages.readMessage()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch message.kind {
+		case processMessageClose:
+			s.respondSuccess(message.id)
+			return nil
+		case processMessageIsAlive:
+			s.respondSuccess(message.id)
+		case processMessageGetPluginInfo:
+			s.respondJSON(message.id, s.handler.PluginInfo())
+		case processMessageGetLicenseText:
+			s.respondData(message.id, []byte(s.handler.LicenseText()))
+		case processMessageRegisterConfig:
+			config, diagnostics, err := parseProcessFormatConfig(message.globalConfig, message.pluginConfig)
+			s.configs.register(message.configID, config, diagnostics, err)
+			s.respondSuccess(message.id)
+		case processMessageReleaseConfig:
+			s.configs.release(message.configID)
+			s.respondSuccess(message.id)
+		case processMessageGetConfigDiagnostics:
+			s.respondJSON(message.id, s.configs.diagnostics(message.configID))
+		case processMessageGetFileMatchingInfo:
+			resolved, _ := s.configs.resolve(message.configID, nil)
+			s.respondJSON(message.id, resolved.FileMatching)
+		case processMessageGetResolvedConfig:
+			resolved, _ := s.configs.resolve(message.configID, nil)
+			s.respondJSON(message.id, resolved.Config)
+		case processMessageCheckConfigUpdates:
+			s.checkConfigUpdates(message)
+		case processMessageFormat:
+			token := &processCancellationToken{done: make(chan struct{})}
+			s.mu.Lock()
+			s.cancellations[message.id] = token
+			s.mu.Unlock()
+
+			s.formats.Add(1)
+			go s.format(message, token)
+		case processMessageCancelFormat:
+			s.mu.Lock()
+			token := s.cancellations[message.messageID]
+			s.mu.Unlock()
+			if token != nil {
+				token.cancel()
+			}
+		case processMessageFormatResponse, processMessageError:
+			s.mu.Lock()
+			response := s.hostFormats[message.messageID]
+			delete(s.hostFormats, message.messageID)
+			s.mu.Unlock()
+			if response != nil {
+				response <- message
+			}
+		case processMessageHostFormat:
+			s.respondError(message.id, "plugins do not format text for the host")
+		case processMessageSuccess, processMessageDataResponse:
+			// The plugin sends no messages these answer.
+		}
+	}
+}
+
+func (s *ProcessServer[T]) checkConfigUpdates(message processMessage) {
+	var request CheckConfigUpdatesMessage
+	if err := json.Unmarshal(message.data, &request); err != nil {
+		s.respondError(message.id, err.Error())
+		return
+	}
+	changes, err := s.handler.CheckConfigUpdates(request)
+	if err != nil {
+		s.respondError(message.id, err.Error())
+		return
+	}
+	if changes == nil {
+		changes = make([]ConfigChange, 0)
+	}
+	s.respondJSON(message.id, changes)
+}
+
+func (s *ProcessServer[T]) format(message processMessage, token *processCancellationToken) {
+	defer s.formats.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.cancellations, message.id)
+		s.mu.Unlock()
+	}()
+
+	result := s.formatMessage(message, token)
+	switch result.Code {
+	case FormatResultNoChange:
+		s.write(processMessageFormatResponse, processBody(nil).uint32(message.id).uint32(0))
+	case FormatResultChange:
+		s.write(processMessageFormatResponse, processBody(nil).uint32(message.id).uint32(1).sizedBytes(result.Text))
+	case FormatResultCancelled:
+		// The host has stopped waiting for the result.
+	default:
+		s.respondError(message.id, formatErrorText(result))
+	}
+}
+
+func (s *ProcessServer[T]) formatMessage(message processMessage, token *processCancellationToken) (result FormatResult) {
+	// A panic would take down every request in progress, not just this one.
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			result = FormatError(fmt.Errorf("the formatter panicked: %v", recovered))
+		}
+	}()
+
+	var overrideConfig ConfigKeyMap
+	if len(message.overrideConfig) > 0 {
+		if err := json.Unmarshal(message.overrideConfig, &overrideConfig); err != nil {
+			return FormatError(fmt.Errorf("failed to parse the override config: %w", err))
+		}
+	}
+	resolvedConfig, err := s.configs.resolve(message.configID, overrideConfig)
+	if err != nil {
+		return FormatError(err)
+	}
+
+	var formatRange *FormatRange
+	if message.rangeStart != 0 || message.rangeEnd != uint32(len(message.fileText)) {
+		formatRange = &FormatRange{Start: message.rangeStart, End: message.rangeEnd}
+	}
+
+	return s.handler.Format(
+		SyncFormatRequest[T]{
+			FilePath:  normalizeFilePath(message.filePath),
+			FileBytes: message.fileText,
+			ConfigID:  message.configID,
+			Config:    resolvedConfig.Config,
+			Range:     formatRange,
+			Token:     token,
+		},
+		func(request SyncHostFormatRequest) FormatResult {
+			return s.formatWithHost(request, token)
+		},
+	)
+}
+
+// formatWithHost asks the host to format text with another plugin and waits
+// for the answer. When the request it is made for is cancelled, the host is
+// told to stop.
+func (s *ProcessServer[T]) formatWithHost(request SyncHostFormatRequest, token *processCancellationToken) FormatResult {
+	overrideConfig := []byte{}
+	if len(request.OverrideConfig) > 0 {
+		bytes, err := json.Marshal(request.OverrideConfig)
+		if err != nil {
+			return FormatError(err)
+		}
+		overrideConfig = bytes
+	}
+
+	startRange := uint32(0)
+	endRange := uint32(len(request.FileBytes))
+	if request.Range != nil {
+		startRange = request.Range.Start
+		endRange = request.Range.End
+	}
+
+	id := s.lastID.Add(1)
+	response := make(chan processMessage, 1)
+	s.mu.Lock()
+	s.hostFormats[id] = response
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.hostFormats, id)
+		s.mu.Unlock()
+	}()
+
+	body := processBody(nil).
+		sizedBytes([]byte(request.FilePath)).
+		uint32(startRange).
+		uint32(endRange).
+		sizedBytes(overrideConfig).
+		sizedBytes(request.FileBytes)
+	if err := s.writeWithID(id, processMessageHostFormat, body); err != nil {
+		return FormatError(err)
+	}
+
+	select {
+	case message := <-response:
+		switch {
+		case message.kind == processMessageError:
+			return FormatError(errors.New(string(message.data)))
+		case message.changed:
+			return Change(message.data)
+		default:
+			return NoChange()
+		}
+	case <-token.done:
+		s.write(processMessageCancelFormat, processBody(nil).uint32(id))
+		return Cancelled()
+	case <-s.closed:
+		return FormatError(errors.New("the host stopped before answering a format request"))
+	}
+}
+
+func (s *ProcessServer[T]) respondSuccess(messageID uint32) {
+	s.write(processMessageSuccess, processBody(nil).uint32(messageID))
+}
+
+func (s *ProcessServer[T]) respondData(messageID uint32, data []byte) {
+	s.write(processMessageDataResponse, processBody(nil).uint32(messageID).sizedBytes(data))
+}
+
+func (s *ProcessServer[T]) respondJSON(messageID uint32, value any) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		s.respondError(messageID, fmt.Sprintf("failed to encode response: %v", err))
+		return
+	}
+	s.respondData(messageID, data)
+}
+
+func (s *ProcessServer[T]) respondError(messageID uint32, text string) {
+	s.write(processMessageError, processBody(nil).uint32(messageID).sizedBytes([]byte(text)))
+}
+
+func (s *ProcessServer[T]) write(kind processMessageKind, body processBody) {
+	_ = s.writeWithID(s.lastID.Add(1), kind, body)
+}
+
+// writeWithID writes a message, remembering the first failure for Serve to
+// return. Once writing has failed, the host cannot be answered any more.
+func (s *ProcessServer[T]) writeWithID(id uint32, kind processMessageKind, body processBody) error {
+	err := s.writer.writeMessage(id, kind, body)
+	if err != nil {
+		s.mu.Lock()
+		if s.writeErr == nil {
+			s.writeErr = err
+		}
+		s.mu.Unlock()
+	}
+	return err
+}
+
+// processCancellationToken is cancelled by the host's CancelFormat message
+// for the request it belongs to.
+type processCancellationToken struct {
+	once sync.Once
+	done chan struct{}
+}
+
+func (t *processCancellationToken) cancel() {
+	t.once.Do(func() {
+		close(t.done)
+	})
+}
+
+func (t *processCancellationToken) IsCancelled() bool {
+	select {
+	case <-t.done:
+		return true
+	default:
+		return false
+	}
+}
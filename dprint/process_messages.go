@@ -0,0 +1,248 @@
+//go:build !wasm
+
+package dprint
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// ProcessPluginSchemaVersion is the dprint process plugin schema version
+// supported here.
+const ProcessPluginSchemaVersion uint32 = 5
+
+// processMessageKind identifies the body of a process plugin message.
+type processMessageKind uint32
+
+// Message kinds of the process plugin protocol. Responses refer to the id of
+// the message they answer.
+const (
+	processMessageSuccess processMessageKind = iota
+	processMessageDataResponse
+	processMessageError
+	processMessageClose
+	processMessageIsAlive
+	processMessageGetPluginInfo
+	processMessageGetLicenseText
+	processMessageRegisterConfig
+	processMessageReleaseConfig
+	processMessageGetConfigDiagnostics
+	processMessageGetFileMatchingInfo
+	processMessageGetResolvedConfig
+	processMessageCheckConfigUpdates
+	processMessageFormat
+	processMessageFormatResponse
+	processMessageCancelFormat
+	processMessageHostFormat
+)
+
+// processSuccessBytes ends every message, so that a reader that got out of
+// step with the writer notices.
+var processSuccessBytes = []byte{255, 255, 255, 255}
+
+// processMessage is a decoded message. Only the fields its kind carries are
+// set.
+type processMessage struct {
+	id   uint32
+	kind processMessageKind
+
+	// messageID is the message a response or CancelFormat refers to.
+	messageID uint32
+	configID  FormatConfigID
+	// data is the body of a DataResponse, Error or CheckConfigUpdates
+	// message, or the formatted text of a FormatResponse.
+	data []byte
+	// changed reports whether a FormatResponse carries formatted text.
+	changed bool
+
+	globalConfig []byte
+	pluginConfig []byte
+
+	filePath       string
+	rangeStart     uint32
+	rangeEnd       uint32
+	overrideConfig []byte
+	fileText       []byte
+}
+
+// processReader decodes messages. Numbers are big-endian, and variable-length
+// data is preceded by its length.
+type processReader struct {
+	reader *bufio.Reader
+}
+
+func newProcessReader(reader io.Reader) *processReader {
+	return &processReader{reader: bufio.NewReader(reader)}
+}
+
+func (r *processReader) readUint32() (uint32, error) {
+	var buffer [4]byte
+	if _, err := io.ReadFull(r.reader, buffer[:]); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint32(buffer[:]), nil
+}
+
+func (r *processReader) readSizedBytes() ([]byte, error) {
+	size, err := r.readUint32()
+	if err != nil {
+		return nil, err
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r.reader, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// readMessage reads the next message. It returns io.EOF when the input ends
+// between messages.
+func (r *processReader) readMessage() (processMessage, error) {
+	var message processMessage
+	id, err := r.readUint32()
+	if err != nil {
+		return message, err
+	}
+	kind, err := r.readUint32()
+	if err != nil {
+		return message, unexpectedEOF(err)
+	}
+	message.id, message.kind = id, processMessageKind(kind)
+
+	if err := r.readBody(&message); err != nil {
+		return message, unexpectedEOF(err)
+	}
+
+	var success [4]byte
+	if _, err := io.ReadFull(r.reader, success[:]); err != nil {
+		return message, unexpectedEOF(err)
+	}
+	if !bytes.Equal(success[:], processSuccessBytes) {
+		return message, fmt.Errorf("message %d does not end with the success bytes, got %v", message.id, success)
+	}
+	return message, nil
+}
+
+func (r *processReader) readBody(message *processMessage) error {
+	var err error
+	readUint32 := func(target *uint32) {
+		if err == nil {
+			*target, err = r.readUint32()
+		}
+	}
+	readSizedBytes := func(target *[]byte) {
+		if err == nil {
+			*target, err = r.readSizedBytes()
+		}
+	}
+	var configID uint32
+	var filePath []byte
+
+	switch message.kind {
+	case processMessageSuccess, processMessageCancelFormat:
+		readUint32(&message.messageID)
+	case processMessageDataResponse, processMessageError:
+		readUint32(&message.messageID)
+		readSizedBytes(&message.data)
+	case processMessageClose, processMessageIsAlive, processMessageGetPluginInfo, processMessageGetLicenseText:
+	case processMessageRegisterConfig:
+		readUint32(&configID)
+		readSizedBytes(&message.globalConfig)
+		readSizedBytes(&message.pluginConfig)
+	case processMessageReleaseConfig, processMessageGetConfigDiagnostics,
+		processMessageGetFileMatchingInfo, processMessageGetResolvedConfig:
+		readUint32(&configID)
+	case processMessageCheckConfigUpdates:
+		readSizedBytes(&message.data)
+	case processMessageFormat, processMessageHostFormat:
+		readSizedBytes(&filePath)
+		readUint32(&message.rangeStart)
+		readUint32(&message.rangeEnd)
+		if message.kind == processMessageFormat {
+			readUint32(&configID)
+		}
+		readSizedBytes(&message.overrideConfig)
+		readSizedBytes(&message.fileText)
+	case processMessageFormatResponse:
+		readUint32(&message.messageID)
+		var changed uint32
+		readUint32(&changed)
+		switch {
+		case err != nil:
+		case changed == 1:
+			message.changed = true
+			readSizedBytes(&message.data)
+		case changed != 0:
+			return fmt.Errorf("unknown format response kind %d in message %d", changed, message.id)
+		}
+	default:
+		// The size of an unknown body is unknown too, so the stream cannot
+		// be read any further.
+		return fmt.Errorf("unknown message kind %d in message %d", message.kind, message.id)
+	}
+
+	message.configID = FormatConfigIDFromRaw(configID)
+	message.filePath = string(filePath)
+	return err
+}
+
+func unexpectedEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// processWriter encodes messages. It is safe for concurrent use; each
+// message is written and flushed as a whole.
+type processWriter struct {
+	mu     sync.Mutex
+	writer *bufio.Writer
+}
+
+func newProcessWriter(writer io.Writer) *processWriter {
+	return &processWriter{writer: bufio.NewWriter(writer)}
+}
+
+// processBody builds the body of a message.
+type processBody []byte
+
+func (b processBody) uint32(value uint32) processBody {
+	return binary.BigEndian.AppendUint32(b, value)
+}
+
+func (b processBody) sizedBytes(data []byte) processBody {
+	return append(b.uint32(uint32(len(data))), data...)
+}
+
+func (w *processWriter) writeUint32(values ...uint32) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var body processBody
+	for _, value := range values {
+		body = body.uint32(value)
+	}
+	if _, err := w.writer.Write(body); err != nil {
+		return err
+	}
+	return w.writer.Flush()
+}
+
+func (w *processWriter) writeMessage(id uint32, kind processMessageKind, body processBody) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	header := processBody(nil).uint32(id).uint32(uint32(kind))
+	for _, part := range [][]byte{header, body, processSuccessBytes} {
+		if _, err := w.writer.Write(part); err != nil {
+			return err
+		}
+	}
+	return w.writer.Flush()
+}
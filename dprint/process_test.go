@@ -0,0 +1,363 @@
+//go:build !wasm
+
+package dprint
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+type processTestHandler struct {
+	testHandler
+
+	format func(request SyncFormatRequest[testConfig], formatWithHost HostFormatFunc) FormatResult
+}
+
+func (h *processTestHandler) Format(request SyncFormatRequest[testConfig], formatWithHost HostFormatFunc) FormatResult {
+	return h.format(request, formatWithHost)
+}
+
+// testProcessHost plays dprint's side of the process plugin protocol over
+// in-memory pipes.
+type testProcessHost struct {
+	t      *testing.T
+	stdin  *io.PipeWriter
+	writer *processWriter
+	reader *processReader
+	served chan error
+}
+
+func startProcessServer(t *testing.T, handler SyncPluginHandler[testConfig]) *testProcessHost {
+	t.Helper()
+
+	stdinReader, stdinWriter := io.Pipe()
+	stdoutReader, stdoutWriter := io.Pipe()
+	host := &testProcessHost{
+		t:      t,
+		stdin:  stdinWriter,
+		writer: newProcessWriter(stdinWriter),
+		reader: newProcessReader(stdoutReader),
+		served: make(chan error, 1),
+	}
+	go func() {
+		err := NewProcessServer(handler).Serve(stdinReader, stdoutWriter)
+		_ = stdoutWriter.Close()
+		host.served <- err
+	}()
+	t.Cleanup(func() {
+		_ = stdinWriter.Close()
+	})
+
+	if err := host.writer.writeUint32(0); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []uint32{0, ProcessPluginSchemaVersion} {
+		value, err := host.reader.readUint32()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if value != expected {
+			t.Fatalf("expected %d in the schema version response, got %d", expected, value)
+		}
+	}
+	return host
+}
+
+func (h *testProcessHost) send(id uint32, kind processMessageKind, body processBody) {
+	h.t.Helper()
+	if err := h.writer.writeMessage(id, kind, body); err != nil {
+		h.t.Fatal(err)
+	}
+}
+
+func (h *testProcessHost) receive() processMessage {
+	h.t.Helper()
+	message, err := h.reader.readMessage()
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	return message
+}
+
+func (h *testProcessHost) expect(kind processMessageKind, messageID uint32) processMessage {
+	h.t.Helper()
+	message := h.receive()
+	if message.kind != kind || message.messageID != messageID {
+		h.t.Fatalf("expected message kind %d for message %d, got kind %d for message %d (%q)",
+			kind, messageID, message.kind, message.messageID, message.data)
+	}
+	return message
+}
+
+func (h *testProcessHost) expectData(messageID uint32, expected string) {
+	h.t.Helper()
+	message := h.expect(processMessageDataResponse, messageID)
+	if string(message.data) != expected {
+		h.t.Fatalf("expected data %s, got %s", expected, message.data)
+	}
+}
+
+func (h *testProcessHost) close() {
+	h.t.Helper()
+	h.send(1000, processMessageClose, nil)
+	h.expect(processMessageSuccess, 1000)
+	if err := <-h.served; err != nil {
+		h.t.Fatalf("expected Serve to succeed, got %v", err)
+	}
+}
+
+func formatBody(path string, text string, configID uint32, override string) processBody {
+	return processBody(nil).
+		sizedBytes([]byte(path)).
+		uint32(0).
+		uint32(uint32(len(text))).
+		uint32(configID).
+		sizedBytes([]byte(override)).
+		sizedBytes([]byte(text))
+}
+
+func registerBody(configID uint32, global string, plugin string) processBody {
+	return processBody(nil).uint32(configID).sizedBytes([]byte(global)).sizedBytes([]byte(plugin))
+}
+
+func TestProcessServerConfigLifecycle(t *testing.T) {
+	host := startProcessServer(t, &processTestHandler{})
+
+	host.send(1, processMessageIsAlive, nil)
+	host.expect(processMessageSuccess, 1)
+
+	host.send(2, processMessageGetLicenseText, nil)
+	host.expectData(2, "license")
+
+	host.send(3, processMessageRegisterConfig, registerBody(7, `{"lineWidth":80}`, `{"value":3,"huge":1e999}`))
+	host.expect(processMessageSuccess, 3)
+
+	host.send(4, processMessageGetResolvedConfig, processBody(nil).uint32(7))
+	host.expectData(4, `{"value":3}`)
+
+	host.send(5, processMessageGetFileMatchingInfo, processBody(nil).uint32(7))
+	host.expectData(5, `{"fileExtensions":["sh"],"fileNames":[]}`)
+
+	host.send(6, processMessageGetConfigDiagnostics, processBody(nil).uint32(7))
+	host.expectData(6, `[{"message":"Failed to decode 'huge': unsupported raw value: \"1e999\".","propertyName":"huge"}]`)
+
+	host.send(7, processMessageReleaseConfig, processBody(nil).uint32(7))
+	host.expect(processMessageSuccess, 7)
+
+	host.send(8, processMessageGetConfigDiagnostics, processBody(nil).uint32(7))
+	host.expectData(8, `[{"message":"plugin must have config set before use (id: 7)","propertyName":""}]`)
+
+	host.send(9, processMessageCheckConfigUpdates, processBody(nil).sizedBytes([]byte(`{"config":{}}`)))
+	host.expectData(9, `[]`)
+
+	host.send(10, processMessageCheckConfigUpdates, processBody(nil).sizedBytes([]byte(`{"config":`)))
+	host.expect(processMessageError, 10)
+
+	host.close()
+}
+
+func TestProcessServerFormatResults(t *testing.T) {
+	handler := &processTestHandler{
+		format: func(request SyncFormatRequest[testConfig], _ HostFormatFunc) FormatResult {
+			switch string(request.FileBytes) {
+			case "same":
+				return NoChange()
+			case "fail":
+				return FormatError(errors.New("format failed"))
+			default:
+				if strings.Contains(request.FilePath, "\\") || request.Range != nil {
+					return FormatError(errors.New("unexpected request"))
+				}
+				return Change([]byte(strings.Repeat("x", request.Config.Value)))
+			}
+		},
+	}
+	host := startProcessServer(t, handler)
+
+	host.send(1, processMessageRegisterConfig, registerBody(1, `{}`, `{"value":2}`))
+	host.expect(processMessageSuccess, 1)
+
+	host.send(2, processMessageFormat, formatBody(`C:\repo\script.sh`, "echo", 1, ""))
+	if message := host.expect(processMessageFormatResponse, 2); !message.changed || string(message.data) != "xx" {
+		t.Fatalf("expected formatted text, got %+v", message)
+	}
+
+	host.send(3, processMessageFormat, formatBody(`C:\repo\script.sh`, "echo", 1, `{"value":4}`))
+	if message := host.expect(processMessageFormatResponse, 3); string(message.data) != "xxxx" {
+		t.Fatalf("expected the override config to apply, got %+v", message)
+	}
+
+	host.send(4, processMessageFormat, formatBody("script.sh", "same", 1, ""))
+	if message := host.expect(processMessageFormatResponse, 4); message.changed {
+		t.Fatalf("expected no change, got %+v", message)
+	}
+
+	host.send(5, processMessageFormat, formatBody("script.sh", "fail", 1, ""))
+	if message := host.expect(processMessageError, 5); string(message.data) != "format failed" {
+		t.Fatalf("expected format error, got %q", message.data)
+	}
+
+	host.send(6, processMessageFormat, formatBody("script.sh", "echo", 2, ""))
+	if message := host.expect(processMessageError, 6); string(message.data) != "plugin must have config set before use (id: 2)" {
+		t.Fatalf("expected missing config error, got %q", message.data)
+	}
+
+	host.close()
+}
+
+func TestProcessServerFormatsConcurrently(t *testing.T) {
+	release := make(chan struct{})
+	handler := &processTestHandler{
+		format: func(request SyncFormatRequest[testConfig], _ HostFormatFunc) FormatResult {
+			if string(request.FileBytes) == "first" {
+				<-release
+			} else {
+				close(release)
+			}
+			return Change(request.FileBytes)
+		},
+	}
+	host := startProcessServer(t, handler)
+
+	host.send(1, processMessageRegisterConfig, registerBody(1, `{}`, `{}`))
+	host.expect(processMessageSuccess, 1)
+
+	// The first request only finishes once the second one has run.
+	host.send(2, processMessageFormat, formatBody("a.sh", "first", 1, ""))
+	host.send(3, processMessageFormat, formatBody("b.sh", "second", 1, ""))
+	host.expect(processMessageFormatResponse, 3)
+	host.expect(processMessageFormatResponse, 2)
+
+	host.close()
+}
+
+func TestProcessServerCancelFormat(t *testing.T) {
+	cancelled := make(chan struct{})
+	handler := &processTestHandler{
+		format: func(request SyncFormatRequest[testConfig], _ HostFormatFunc) FormatResult {
+			for !request.Token.IsCancelled() {
+				time.Sleep(time.Millisecond)
+			}
+			close(cancelled)
+			return Cancelled()
+		},
+	}
+	host := startProcessServer(t, handler)
+
+	host.send(1, processMessageRegisterConfig, registerBody(1, `{}`, `{}`))
+	host.expect(processMessageSuccess, 1)
+
+	host.send(2, processMessageFormat, formatBody("a.sh", "echo", 1, ""))
+	host.send(3, processMessageCancelFormat, processBody(nil).uint32(2))
+	<-cancelled
+
+	// A cancelled request is not answered.
+	host.send(4, processMessageIsAlive, nil)
+	host.expect(processMessageSuccess, 4)
+
+	host.close()
+}
+
+func TestProcessServerFormatWithHost(t *testing.T) {
+	handler := &processTestHandler{
+		format: func(request SyncFormatRequest[testConfig], formatWithHost HostFormatFunc) FormatResult {
+			result := formatWithHost(SyncHostFormatRequest{
+				FilePath:       "embedded.json",
+				FileBytes:      request.FileBytes,
+				OverrideConfig: ConfigKeyMap{"indentWidth": 2},
+			})
+			if result.Code == FormatResultError {
+				return FormatError(errors.New("host: " + result.Err.Error()))
+			}
+			return result
+		},
+	}
+	host := startProcessServer(t, handler)
+
+	host.send(1, processMessageRegisterConfig, registerBody(1, `{}`, `{}`))
+	host.expect(processMessageSuccess, 1)
+
+	host.send(2, processMessageFormat, formatBody("a.sh", "{ }", 1, ""))
+	request := host.receive()
+	if request.kind != processMessageHostFormat {
+		t.Fatalf("expected a host format request, got kind %d", request.kind)
+	}
+	if request.filePath != "embedded.json" || string(request.fileText) != "{ }" ||
+		request.rangeStart != 0 || request.rangeEnd != 3 || string(request.overrideConfig) != `{"indentWidth":2}` {
+		t.Fatalf("unexpected host format request: %+v", request)
+	}
+	host.send(3, processMessageFormatResponse, processBody(nil).uint32(request.id).uint32(1).sizedBytes([]byte("{}")))
+	if message := host.expect(processMessageFormatResponse, 2); !message.changed || string(message.data) != "{}" {
+		t.Fatalf("expected the host's formatted text, got %+v", message)
+	}
+
+	host.send(4, processMessageFormat, formatBody("a.sh", "{", 1, ""))
+	request = host.receive()
+	host.send(5, processMessageError, processBody(nil).uint32(request.id).sizedBytes([]byte("bad json")))
+	if message := host.expect(processMessageError, 4); string(message.data) != "host: bad json" {
+		t.Fatalf("expected the host's error, got %q", message.data)
+	}
+
+	host.close()
+}
+
+func TestProcessServerCancelsHostFormat(t *testing.T) {
+	handler := &processTestHandler{
+		format: func(request SyncFormatRequest[testConfig], formatWithHost HostFormatFunc) FormatResult {
+			return formatWithHost(SyncHostFormatRequest{FilePath: "embedded.json", FileBytes: request.FileBytes})
+		},
+	}
+	host := startProcessServer(t, handler)
+
+	host.send(1, processMessageRegisterConfig, registerBody(1, `{}`, `{}`))
+	host.expect(processMessageSuccess, 1)
+
+	host.send(2, processMessageFormat, formatBody("a.sh", "{ }", 1, ""))
+	request := host.receive()
+	host.send(3, processMessageCancelFormat, processBody(nil).uint32(2))
+	host.expect(processMessageCancelFormat, request.id)
+
+	host.close()
+}
+
+func TestProcessServerProtocolErrors(t *testing.T) {
+	cases := []struct {
+		name     string
+		message  []byte
+		expected string
+	}{
+		{
+			name:     "unknown-kind",
+			message:  processBody(nil).uint32(1).uint32(99),
+			expected: "unknown message kind 99 in message 1",
+		},
+		{
+			name:     "missing-success-bytes",
+			message:  processBody(nil).uint32(1).uint32(uint32(processMessageIsAlive)).uint32(0),
+			expected: "message 1 does not end with the success bytes, got [0 0 0 0]",
+		},
+		{
+			name:     "truncated",
+			message:  processBody(nil).uint32(1).uint32(uint32(processMessageReleaseConfig)),
+			expected: io.ErrUnexpectedEOF.Error(),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			host := startProcessServer(t, &processTestHandler{})
+			if _, err := host.stdin.Write(tc.message); err != nil {
+				t.Fatal(err)
+			}
+			_ = host.stdin.Close()
+
+			err := <-host.served
+			if err == nil || err.Error() != tc.expected {
+				t.Fatalf("expected error %q, got %v", tc.expected, err)
+			}
+		})
+	}
+}
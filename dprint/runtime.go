@@ -2,6 +2,7 @@ package dprint
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 	"unicode/utf8"
@@ -19,10 +20,13 @@ type Runtime[T any] struct {
 
 	sharedBytes []byte
 
-	unresolvedConfigs []unresolvedConfigEntry
+	configs *configStore[T]
 
 	overrideConfig *ConfigKeyMap
 	filePath       *string
+	// requestErr is a problem with the override config or file path of the
+	// next format request, reported as that request's format error.
+	requestErr error
 
 	formattedText    []byte
 	hasFormattedText bool
@@ -37,10 +41,10 @@ func NewRuntime[T any](handler SyncPluginHandler[T]) *Runtime[T] {
 	}
 
 	return &Runtime[T]{
-		handler:           handler,
-		host:              wasmHostBridge{},
-		sharedBytes:       make([]byte, 0),
-		unresolvedConfigs: make([]unresolvedConfigEntry, 0),
+		handler:     handler,
+		host:        wasmHostBridge{},
+		sharedBytes: make([]byte, 0),
+		configs:     newConfigStore(handler),
 	}
 }
 
@@ -51,11 +55,7 @@ func (r *Runtime[T]) DprintPluginVersion4() uint32 {
 
 // GetPluginInfo writes plugin metadata JSON to shared bytes and returns its length.
 func (r *Runtime[T]) GetPluginInfo() uint32 {
-	bytes, err := json.Marshal(r.handler.PluginInfo())
-	if err != nil {
-		panic(err)
-	}
-	return r.setSharedBytes(bytes)
+	return r.setSharedJSON(r.handler.PluginInfo())
 }
 
 // GetLicenseText writes license text to shared bytes and returns its length.
@@ -64,63 +64,41 @@ func (r *Runtime[T]) GetLicenseText() uint32 {
 }
 
 // RegisterConfig stores unresolved configuration received through shared bytes.
+// Configuration that cannot be decoded is reported through the config's
+// diagnostics.
 func (r *Runtime[T]) RegisterConfig(configID uint32) {
-	config, err := parseRawFormatConfig(r.takeSharedBytes())
-	if err != nil {
-		panic(err)
-	}
-	if config.Plugin == nil {
-		config.Plugin = make(ConfigKeyMap)
-	}
-	if config.Global == nil {
-		config.Global = make(GlobalConfiguration)
-	}
-
-	id := FormatConfigIDFromRaw(configID)
-	r.setUnresolvedConfig(id, config)
+	config, diagnostics, err := parseRawFormatConfig(r.takeSharedBytes())
+	r.configs.register(FormatConfigIDFromRaw(configID), config, diagnostics, err)
 }
 
 // ReleaseConfig removes unresolved and resolved configuration for id.
 func (r *Runtime[T]) ReleaseConfig(configID uint32) {
-	id := FormatConfigIDFromRaw(configID)
-	r.removeUnresolvedConfig(id)
+	r.configs.release(FormatConfigIDFromRaw(configID))
 }
 
 // GetConfigDiagnostics writes diagnostics JSON for id and returns its length.
 func (r *Runtime[T]) GetConfigDiagnostics(configID uint32) uint32 {
-	resolved := r.getResolvedConfigResult(FormatConfigIDFromRaw(configID))
-	bytes, err := json.Marshal(resolved.Diagnostics)
-	if err != nil {
-		panic(err)
-	}
-	return r.setSharedBytes(bytes)
+	return r.setSharedJSON(r.configs.diagnostics(FormatConfigIDFromRaw(configID)))
 }
 
 // GetResolvedConfig writes resolved config JSON for id and returns its length.
 func (r *Runtime[T]) GetResolvedConfig(configID uint32) uint32 {
-	resolved := r.getResolvedConfigResult(FormatConfigIDFromRaw(configID))
-	bytes, err := json.Marshal(resolved.Config)
-	if err != nil {
-		panic(err)
-	}
-	return r.setSharedBytes(bytes)
+	resolved, _ := r.configs.resolve(FormatConfigIDFromRaw(configID), nil)
+	return r.setSharedJSON(resolved.Config)
 }
 
 // GetConfigFileMatching writes file matching JSON for id and returns its length.
 func (r *Runtime[T]) GetConfigFileMatching(configID uint32) uint32 {
-	resolved := r.getResolvedConfigResult(FormatConfigIDFromRaw(configID))
-	bytes, err := json.Marshal(resolved.FileMatching)
-	if err != nil {
-		panic(err)
-	}
-	return r.setSharedBytes(bytes)
+	resolved, _ := r.configs.resolve(FormatConfigIDFromRaw(configID), nil)
+	return r.setSharedJSON(resolved.FileMatching)
 }
 
 // SetOverrideConfig stores one-off override config from shared bytes.
 func (r *Runtime[T]) SetOverrideConfig() {
 	var config *ConfigKeyMap
 	if err := json.Unmarshal(r.takeSharedBytes(), &config); err != nil {
-		panic(err)
+		r.requestErr = fmt.Errorf("failed to parse the override config: %w", err)
+		return
 	}
 	r.overrideConfig = config
 }
@@ -129,12 +107,18 @@ func (r *Runtime[T]) SetOverrideConfig() {
 func (r *Runtime[T]) SetFilePath() {
 	filePathBytes := r.takeSharedBytes()
 	if !utf8.Valid(filePathBytes) {
-		panic("expected file path to be utf-8")
+		r.requestErr = errors.New("expected the file path to be utf-8")
+		return
 	}
-	pathText := strings.ReplaceAll(string(filePathBytes), "\\", "/")
+	pathText := normalizeFilePath(string(filePathBytes))
 	r.filePath = &pathText
 }
 
+// normalizeFilePath uses forward slashes in paths from Windows hosts.
+func normalizeFilePath(path string) string {
+	return strings.ReplaceAll(path, "\\", "/")
+}
+
 // Format formats full file contents for the specified config id.
 func (r *Runtime[T]) Format(configID uint32) uint32 {
 	return r.formatInner(FormatConfigIDFromRaw(configID), nil)
@@ -151,7 +135,7 @@ func (r *Runtime[T]) FormatRange(configID uint32, rangeStart uint32, rangeEnd ui
 // GetFormattedText writes the most recent formatted text and returns its length.
 func (r *Runtime[T]) GetFormattedText() uint32 {
 	if !r.hasFormattedText {
-		panic("expected to have formatted text")
+		r.fail("get_formatted_text was called without a formatted result")
 	}
 
 	text := r.formattedText
@@ -164,7 +148,7 @@ func (r *Runtime[T]) GetFormattedText() uint32 {
 // GetErrorText writes the most recent format error text and returns its length.
 func (r *Runtime[T]) GetErrorText() uint32 {
 	if !r.hasErrorText {
-		panic("expected to have error text")
+		r.fail("get_error_text was called without an error result")
 	}
 
 	text := r.errorText
@@ -199,12 +183,7 @@ func (r *Runtime[T]) CheckConfigUpdates() uint32 {
 		}
 	}
 
-	bytes, err := json.Marshal(response)
-	if err != nil {
-		panic(err)
-	}
-
-	return r.setSharedBytes(bytes)
+	return r.setSharedJSON(response)
 }
 
 // GetSharedBytesPtr returns a pointer to the shared byte buffer.
@@ -225,25 +204,34 @@ func (r *Runtime[T]) ClearSharedBytes(size uint32) uint32 {
 }
 
 func (r *Runtime[T]) formatInner(configID FormatConfigID, formatRange *FormatRange) uint32 {
-	var resolvedConfig ResolveConfigurationResult[T]
+	overrideConfig := r.overrideConfig
+	r.overrideConfig = nil
+	filePath := r.filePath
+	r.filePath = nil
+	requestErr := r.requestErr
+	r.requestErr = nil
+	fileBytes := r.takeSharedBytes()
 
-	if r.overrideConfig != nil {
-		resolvedConfig = r.createResolvedConfigResult(configID, *r.overrideConfig)
-		r.overrideConfig = nil
-	} else {
-		resolvedConfig = r.getResolvedConfigResult(configID)
+	if requestErr != nil {
+		return r.setFormatResult(FormatError(requestErr))
+	}
+	if filePath == nil {
+		return r.setFormatResult(FormatError(errors.New("expected the file path to be set before formatting")))
 	}
 
-	if r.filePath == nil {
-		panic("expected the file path to be set")
+	var override ConfigKeyMap
+	if overrideConfig != nil {
+		override = *overrideConfig
+	}
+	resolvedConfig, err := r.configs.resolve(configID, override)
+	if err != nil {
+		return r.setFormatResult(FormatError(err))
 	}
-	filePath := *r.filePath
-	r.filePath = nil
 
 	result := r.handler.Format(
 		SyncFormatRequest[T]{
-			FilePath:  filePath,
-			FileBytes: r.takeSharedBytes(),
+			FilePath:  *filePath,
+			FileBytes: fileBytes,
 			ConfigID:  configID,
 			Config:    resolvedConfig.Config,
 			Range:     formatRange,
@@ -251,51 +239,58 @@ func (r *Runtime[T]) formatInner(configID FormatConfigID, formatRange *FormatRan
 		},
 		r.formatWithHost,
 	)
+	return r.setFormatResult(result)
+}
 
+// setFormatResult stores the text of result for the host to fetch and returns
+// the result code to report. Results a handler should not return are reported
+// as format errors.
+func (r *Runtime[T]) setFormatResult(result FormatResult) uint32 {
 	switch result.Code {
 	case FormatResultNoChange:
 		return uint32(FormatResultNoChange)
+	case FormatResultCancelled:
+		// The host discards results of cancelled requests, so there is
+		// nothing to report beyond leaving the text unchanged.
+		return uint32(FormatResultNoChange)
 	case FormatResultChange:
 		r.formattedText = result.Text
 		r.hasFormattedText = true
 		return uint32(FormatResultChange)
-	case FormatResultError:
-		if result.Err == nil {
-			panic("format error result requires an error message")
-		}
-		r.errorText = result.Err.Error()
+	default:
+		r.errorText = formatErrorText(result)
 		r.hasErrorText = true
 		return uint32(FormatResultError)
-	default:
-		panic(fmt.Sprintf("unknown format result code: %d", result.Code))
 	}
 }
 
-func (r *Runtime[T]) getResolvedConfigResult(configID FormatConfigID) ResolveConfigurationResult[T] {
-	return r.createResolvedConfigResult(configID, nil)
-}
-
-func (r *Runtime[T]) createResolvedConfigResult(configID FormatConfigID, overrideConfig ConfigKeyMap) ResolveConfigurationResult[T] {
-	unresolvedConfig, ok := r.getUnresolvedConfig(configID)
-	if !ok {
-		panic(fmt.Sprintf("plugin must have config set before use (id: %d)", configID.AsRaw()))
-	}
-
-	pluginConfig := cloneConfigMap(unresolvedConfig.Plugin)
-	for key, value := range overrideConfig {
-		pluginConfig[key] = value
+// formatErrorText returns the error message of an error result. Results a
+// handler should not return are described as errors as well.
+func formatErrorText(result FormatResult) string {
+	switch {
+	case result.Code != FormatResultError:
+		return fmt.Sprintf("the formatter returned an unknown result code: %d", result.Code)
+	case result.Err == nil:
+		return "the formatter reported an error without a message"
+	default:
+		return result.Err.Error()
 	}
+}
 
-	result := r.handler.ResolveConfig(pluginConfig, unresolvedConfig.Global)
-	if result.Diagnostics == nil {
-		result.Diagnostics = make([]ConfigurationDiagnostic, 0)
-	}
-	if result.FileMatching.FileExtensions == nil {
-		result.FileMatching.FileExtensions = make([]string, 0)
-	}
-	if result.FileMatching.FileNames == nil {
-		result.FileMatching.FileNames = make([]string, 0)
+// setSharedJSON writes value as JSON to shared bytes and returns its length.
+func (r *Runtime[T]) setSharedJSON(value any) uint32 {
+	bytes, err := json.Marshal(value)
+	if err != nil {
+		r.fail(fmt.Sprintf("failed to encode response: %v", err))
 	}
+	return r.setSharedBytes(bytes)
+}
 
-	return result
+// fail stops the plugin on a programming error that leaves it in no state to
+// answer the host. Plugins are built with -panic=trap, which drops the panic
+// message, so the message is first left in shared bytes, where it can be read
+// from the instance memory after the trap.
+func (r *Runtime[T]) fail(message string) {
+	r.setSharedBytes([]byte(message))
+	panic(message)
 }
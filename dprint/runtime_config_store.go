@@ -1,8 +1,50 @@
 package dprint
 
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// maxOverrideResolvedConfigs bounds how many results resolved with an
+// override config are kept. Editors tend to send the same few overrides again
+// and again, so a handful is enough.
+const maxOverrideResolvedConfigs = 8
+
+// configStore keeps the configurations registered by the host and memoizes
+// the results of resolving them. It is safe for concurrent use, so that a
+// process plugin can format several files at once.
+type configStore[T any] struct {
+	handler SyncPluginHandler[T]
+
+	mu               sync.Mutex
+	unresolved       []unresolvedConfigEntry
+	resolved         []resolvedConfigEntry[T]
+	overrideResolved []resolvedConfigEntry[T]
+}
+
+// unresolvedConfigEntry is a registered configuration. diagnostics describes
+// the values that could not be decoded and were left out of config.
 type unresolvedConfigEntry struct {
-	id     FormatConfigID
-	config RawFormatConfig
+	id          FormatConfigID
+	config      RawFormatConfig
+	diagnostics []ConfigurationDiagnostic
+}
+
+// resolvedConfigEntry is a memoized ResolveConfig result. override is the
+// JSON encoding of the override config it was resolved with, or empty.
+type resolvedConfigEntry[T any] struct {
+	id       FormatConfigID
+	override string
+	result   ResolveConfigurationResult[T]
+}
+
+func newConfigStore[T any](handler SyncPluginHandler[T]) *configStore[T] {
+	return &configStore[T]{
+		handler:    handler,
+		unresolved: make([]unresolvedConfigEntry, 0),
+	}
 }
 
 func cloneConfigMap(config ConfigKeyMap) ConfigKeyMap {
@@ -17,38 +59,169 @@ func cloneConfigMap(config ConfigKeyMap) ConfigKeyMap {
 	return newConfig
 }
 
-func (r *Runtime[T]) setUnresolvedConfig(id FormatConfigID, config RawFormatConfig) {
-	for i := range r.unresolvedConfigs {
-		if r.unresolvedConfigs[i].id == id {
-			r.unresolvedConfigs[i].config = config
+// register stores config for id, replacing any config registered before and
+// the results resolved from it. parseErr is the error decoding the config
+// failed with, which is reported as a diagnostic.
+func (s *configStore[T]) register(id FormatConfigID, config RawFormatConfig, diagnostics []ConfigurationDiagnostic, parseErr error) {
+	if parseErr != nil {
+		diagnostics = []ConfigurationDiagnostic{{
+			"propertyName": "",
+			"message":      fmt.Sprintf("Failed to parse the configuration: %v.", parseErr),
+		}}
+	}
+	if config.Plugin == nil {
+		config.Plugin = make(ConfigKeyMap)
+	}
+	if config.Global == nil {
+		config.Global = make(GlobalConfiguration)
+	}
+	entry := unresolvedConfigEntry{
+		id:          id,
+		config:      config,
+		diagnostics: diagnostics,
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.removeResolved(id)
+	for i := range s.unresolved {
+		if s.unresolved[i].id == id {
+			s.unresolved[i] = entry
 			return
 		}
 	}
-
-	r.unresolvedConfigs = append(r.unresolvedConfigs, unresolvedConfigEntry{
-		id:     id,
-		config: config,
-	})
+	s.unresolved = append(s.unresolved, entry)
 }
 
-func (r *Runtime[T]) getUnresolvedConfig(id FormatConfigID) (RawFormatConfig, bool) {
-	for i := range r.unresolvedConfigs {
-		if r.unresolvedConfigs[i].id == id {
-			return r.unresolvedConfigs[i].config, true
+// release removes the config registered for id and the results resolved from
+// it.
+func (s *configStore[T]) release(id FormatConfigID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.removeResolved(id)
+	for i := range s.unresolved {
+		if s.unresolved[i].id != id {
+			continue
 		}
+
+		lastIndex := len(s.unresolved) - 1
+		s.unresolved[i] = s.unresolved[lastIndex]
+		s.unresolved = s.unresolved[:lastIndex]
+		return
 	}
-	return RawFormatConfig{}, false
 }
 
-func (r *Runtime[T]) removeUnresolvedConfig(id FormatConfigID) {
-	for i := range r.unresolvedConfigs {
-		if r.unresolvedConfigs[i].id != id {
+// resolve resolves the config registered for id with overrideConfig applied.
+// Results are reused until the config is registered again or released; only
+// the most recently used overrides are kept, and reusing one makes it the most
+// recently used. When no config is registered for
+// id, resolve returns an empty result along with the error.
+func (s *configStore[T]) resolve(id FormatConfigID, overrideConfig ConfigKeyMap) (ResolveConfigurationResult[T], error) {
+	override := ""
+	if len(overrideConfig) > 0 {
+		// Map keys are sorted when encoded, so equal overrides share a key.
+		key, err := json.Marshal(overrideConfig)
+		if err != nil {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			return s.create(id, overrideConfig)
+		}
+		override = string(key)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries := s.resolved
+	if override != "" {
+		entries = s.overrideResolved
+	}
+	for i := range entries {
+		if entries[i].id != id || entries[i].override != override {
 			continue
 		}
+		entry := entries[i]
+		if override != "" {
+			s.overrideResolved = append(slices.Delete(s.overrideResolved, i, i+1), entry)
+		}
+		return entry.result, nil
+	}
 
-		lastIndex := len(r.unresolvedConfigs) - 1
-		r.unresolvedConfigs[i] = r.unresolvedConfigs[lastIndex]
-		r.unresolvedConfigs = r.unresolvedConfigs[:lastIndex]
-		return
+	result, err := s.create(id, overrideConfig)
+	if err != nil {
+		return result, err
+	}
+	entry := resolvedConfigEntry[T]{id: id, override: override, result: result}
+	if override == "" {
+		s.resolved = append(s.resolved, entry)
+	} else {
+		if len(s.overrideResolved) >= maxOverrideResolvedConfigs {
+			s.overrideResolved = s.overrideResolved[1:]
+		}
+		s.overrideResolved = append(s.overrideResolved, entry)
+	}
+	return result, nil
+}
+
+// diagnostics returns the diagnostics of the config registered for id, or a
+// diagnostic saying there is none.
+func (s *configStore[T]) diagnostics(id FormatConfigID) []ConfigurationDiagnostic {
+	resolved, err := s.resolve(id, nil)
+	if err != nil {
+		resolved.Diagnostics = append(resolved.Diagnostics, ConfigurationDiagnostic{
+			"propertyName": "",
+			"message":      err.Error(),
+		})
+	}
+	return resolved.Diagnostics
+}
+
+// create resolves a config without memoizing it. s.mu must be held.
+func (s *configStore[T]) create(id FormatConfigID, overrideConfig ConfigKeyMap) (ResolveConfigurationResult[T], error) {
+	var result ResolveConfigurationResult[T]
+	var err error
+	if entry, ok := s.find(id); ok {
+		pluginConfig := cloneConfigMap(entry.config.Plugin)
+		for key, value := range overrideConfig {
+			pluginConfig[key] = value
+		}
+
+		result = s.handler.ResolveConfig(pluginConfig, entry.config.Global)
+		if len(entry.diagnostics) > 0 {
+			result.Diagnostics = append(slices.Clone(entry.diagnostics), result.Diagnostics...)
+		}
+	} else {
+		err = fmt.Errorf("plugin must have config set before use (id: %d)", id.AsRaw())
+	}
+
+	if result.Diagnostics == nil {
+		result.Diagnostics = make([]ConfigurationDiagnostic, 0)
+	}
+	if result.FileMatching.FileExtensions == nil {
+		result.FileMatching.FileExtensions = make([]string, 0)
+	}
+	if result.FileMatching.FileNames == nil {
+		result.FileMatching.FileNames = make([]string, 0)
+	}
+
+	return result, err
+}
+
+func (s *configStore[T]) find(id FormatConfigID) (unresolvedConfigEntry, bool) {
+	for i := range s.unresolved {
+		if s.unresolved[i].id == id {
+			return s.unresolved[i], true
+		}
+	}
+	return unresolvedConfigEntry{}, false
+}
+
+func (s *configStore[T]) removeResolved(id FormatConfigID) {
+	removeEntry := func(entry resolvedConfigEntry[T]) bool {
+		return entry.id == id
 	}
+	s.resolved = slices.DeleteFunc(s.resolved, removeEntry)
+	s.overrideResolved = slices.DeleteFunc(s.overrideResolved, removeEntry)
 }
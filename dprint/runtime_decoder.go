@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"maps"
+	"slices"
 	"strconv"
 )
 
@@ -12,26 +14,69 @@ type rawFormatConfigEnvelope struct {
 	Global map[string]json.RawMessage `json:"global"`
 }
 
-func parseRawFormatConfig(data []byte) (RawFormatConfig, error) {
+// parseRawFormatConfig decodes the configuration registered by the host. A
+// value that cannot be decoded is left out and reported as a diagnostic for
+// its key, so that the rest of the configuration still applies. An error is
+// only returned when data is not a configuration object at all.
+func parseRawFormatConfig(data []byte) (RawFormatConfig, []ConfigurationDiagnostic, error) {
 	var envelope rawFormatConfigEnvelope
 	if err := json.Unmarshal(data, &envelope); err != nil {
-		return RawFormatConfig{}, err
+		return RawFormatConfig{}, nil, err
 	}
 
-	pluginConfig, err := decodeRawObject(envelope.Plugin)
-	if err != nil {
-		return RawFormatConfig{}, fmt.Errorf("failed to decode plugin config: %w", err)
-	}
+	var diagnostics []ConfigurationDiagnostic
+	pluginConfig := decodeConfigObject(envelope.Plugin, &diagnostics)
+	globalConfig := decodeConfigObject(envelope.Global, &diagnostics)
+
+	return RawFormatConfig{
+		Plugin: pluginConfig,
+		Global: GlobalConfiguration(globalConfig),
+	}, diagnostics, nil
+}
 
-	globalConfig, err := decodeRawObject(envelope.Global)
-	if err != nil {
-		return RawFormatConfig{}, fmt.Errorf("failed to decode global config: %w", err)
+// parseProcessFormatConfig is like parseRawFormatConfig for the process plugin
+// protocol, which sends the global and plugin configuration separately.
+func parseProcessFormatConfig(globalData []byte, pluginData []byte) (RawFormatConfig, []ConfigurationDiagnostic, error) {
+	var envelope rawFormatConfigEnvelope
+	if len(globalData) > 0 {
+		if err := json.Unmarshal(globalData, &envelope.Global); err != nil {
+			return RawFormatConfig{}, nil, fmt.Errorf("global config: %w", err)
+		}
 	}
+	if len(pluginData) > 0 {
+		if err := json.Unmarshal(pluginData, &envelope.Plugin); err != nil {
+			return RawFormatConfig{}, nil, fmt.Errorf("plugin config: %w", err)
+		}
+	}
+
+	var diagnostics []ConfigurationDiagnostic
+	pluginConfig := decodeConfigObject(envelope.Plugin, &diagnostics)
+	globalConfig := decodeConfigObject(envelope.Global, &diagnostics)
 
 	return RawFormatConfig{
 		Plugin: pluginConfig,
 		Global: GlobalConfiguration(globalConfig),
-	}, nil
+	}, diagnostics, nil
+}
+
+func decodeConfigObject(rawMap map[string]json.RawMessage, diagnostics *[]ConfigurationDiagnostic) map[string]any {
+	if rawMap == nil {
+		return nil
+	}
+
+	result := make(map[string]any, len(rawMap))
+	for _, key := range slices.Sorted(maps.Keys(rawMap)) {
+		value, err := decodeRawValue(rawMap[key])
+		if err != nil {
+			*diagnostics = append(*diagnostics, ConfigurationDiagnostic{
+				"propertyName": key,
+				"message":      fmt.Sprintf("Failed to decode '%s': %v.", key, err),
+			})
+			continue
+		}
+		result[key] = value
+	}
+	return result
 }
 
 func decodeRawObject(rawMap map[string]json.RawMessage) (map[string]any, error) {
@@ -86,7 +86,7 @@ func (r *Runtime[T]) formatWithHost(request SyncHostFormatRequest) FormatResult
 	case uint32(FormatResultError):
 		return FormatError(fmt.Errorf("%s", r.host.readErrorText(r.readBytesFromHost)))
 	default:
-		panic(fmt.Sprintf("unknown host format value: %d", resultCode))
+		return FormatError(fmt.Errorf("the host returned an unknown format result code: %d", resultCode))
 	}
 }
 
@@ -3,6 +3,7 @@ package dprint
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"testing"
 )
 
@@ -113,18 +114,29 @@ func TestConfigLifecycleAndResolvedConfigResolution(t *testing.T) {
 	}
 
 	runtime.GetResolvedConfig(1)
+	runtime.GetConfigDiagnostics(1)
+	runtime.GetConfigFileMatching(1)
+	if handler.resolveConfigCallCount != 1 {
+		t.Fatalf("expected the resolved config to be reused, got %d ResolveConfig calls", handler.resolveConfigCallCount)
+	}
+
+	runtime.sharedBytes = []byte(`{"plugin":{"value":3},"global":{}}`)
+	runtime.RegisterConfig(1)
+	runtime.GetResolvedConfig(1)
+	if err := json.Unmarshal(runtime.sharedBytes, &resolvedConfig); err != nil {
+		t.Fatal(err)
+	}
+	if resolvedConfig.Value != 3 {
+		t.Fatalf("expected re-registered config value to be 3, got %d", resolvedConfig.Value)
+	}
 	if handler.resolveConfigCallCount != 2 {
-		t.Fatalf("expected ResolveConfig to be called for each request, got %d", handler.resolveConfigCallCount)
+		t.Fatalf("expected re-registering to resolve the config again, got %d ResolveConfig calls", handler.resolveConfigCallCount)
 	}
 
 	runtime.ReleaseConfig(1)
 
-	defer func() {
-		if recover() == nil {
-			t.Fatal("expected panic when using released config")
-		}
-	}()
-	runtime.GetResolvedConfig(1)
+	runtime.GetConfigDiagnostics(1)
+	assertDiagnosticMessages(t, runtime.sharedBytes, "plugin must have config set before use (id: 1)")
 }
 
 func TestConfigLifecycleMultipleIDs(t *testing.T) {
@@ -163,12 +175,145 @@ func TestConfigLifecycleMultipleIDs(t *testing.T) {
 		t.Fatalf("expected resolved config value to remain 2 after releasing config 1, got %d", resolvedConfig.Value)
 	}
 
+	runtime.sharedBytes = []byte("script.sh")
+	runtime.SetFilePath()
+	runtime.sharedBytes = []byte("echo test")
+	if result := runtime.Format(1); result != uint32(FormatResultError) {
+		t.Fatalf("expected format result %d for a released config, got %d", FormatResultError, result)
+	}
+	runtime.GetErrorText()
+	if string(runtime.sharedBytes) != "plugin must have config set before use (id: 1)" {
+		t.Fatalf("expected missing config error, got %q", string(runtime.sharedBytes))
+	}
+}
+
+func TestRegisterConfigReportsDecodeErrorsAsDiagnostics(t *testing.T) {
+	handler := &testHandler{}
+	runtime := NewRuntime[testConfig](handler)
+
+	runtime.sharedBytes = []byte(`{"plugin":{"value":2,"huge":1e999},"global":{"lineWidth":1e999}}`)
+	runtime.RegisterConfig(1)
+
+	runtime.GetResolvedConfig(1)
+	var resolvedConfig testConfig
+	if err := json.Unmarshal(runtime.sharedBytes, &resolvedConfig); err != nil {
+		t.Fatal(err)
+	}
+	if resolvedConfig.Value != 2 {
+		t.Fatalf("expected the decodable keys to apply, got value %d", resolvedConfig.Value)
+	}
+
+	runtime.GetConfigDiagnostics(1)
+	assertDiagnosticMessages(t, runtime.sharedBytes,
+		`Failed to decode 'huge': unsupported raw value: "1e999".`,
+		`Failed to decode 'lineWidth': unsupported raw value: "1e999".`,
+	)
+
+	runtime.sharedBytes = []byte(`{"plugin":`)
+	runtime.RegisterConfig(2)
+	runtime.GetConfigDiagnostics(2)
+	assertDiagnosticMessages(t, runtime.sharedBytes, "Failed to parse the configuration: unexpected end of JSON input.")
+}
+
+func TestFormatReportsInvalidRequestsAsErrors(t *testing.T) {
+	cases := []struct {
+		name     string
+		prepare  func(runtime *Runtime[testConfig])
+		result   FormatResult
+		expected string
+	}{
+		{
+			name: "missing-file-path",
+			prepare: func(runtime *Runtime[testConfig]) {
+				runtime.sharedBytes = []byte("echo test")
+			},
+			result:   NoChange(),
+			expected: "expected the file path to be set before formatting",
+		},
+		{
+			name: "invalid-file-path",
+			prepare: func(runtime *Runtime[testConfig]) {
+				runtime.sharedBytes = []byte("script\xff.sh")
+				runtime.SetFilePath()
+				runtime.sharedBytes = []byte("echo test")
+			},
+			result:   NoChange(),
+			expected: "expected the file path to be utf-8",
+		},
+		{
+			name: "invalid-override-config",
+			prepare: func(runtime *Runtime[testConfig]) {
+				runtime.sharedBytes = []byte(`{"value":`)
+				runtime.SetOverrideConfig()
+				runtime.sharedBytes = []byte("script.sh")
+				runtime.SetFilePath()
+				runtime.sharedBytes = []byte("echo test")
+			},
+			result:   NoChange(),
+			expected: "failed to parse the override config: unexpected end of JSON input",
+		},
+		{
+			name: "error-without-message",
+			prepare: func(runtime *Runtime[testConfig]) {
+				runtime.sharedBytes = []byte("script.sh")
+				runtime.SetFilePath()
+				runtime.sharedBytes = []byte("echo test")
+			},
+			result:   FormatResult{Code: FormatResultError},
+			expected: "the formatter reported an error without a message",
+		},
+		{
+			name: "unknown-result-code",
+			prepare: func(runtime *Runtime[testConfig]) {
+				runtime.sharedBytes = []byte("script.sh")
+				runtime.SetFilePath()
+				runtime.sharedBytes = []byte("echo test")
+			},
+			result:   FormatResult{Code: 42},
+			expected: "the formatter returned an unknown result code: 42",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := &testHandler{nextFormatResult: tc.result}
+			runtime := NewRuntime[testConfig](handler)
+			runtime.sharedBytes = []byte(`{"plugin":{},"global":{}}`)
+			runtime.RegisterConfig(1)
+
+			tc.prepare(runtime)
+			if result := runtime.Format(1); result != uint32(FormatResultError) {
+				t.Fatalf("expected format result %d, got %d", FormatResultError, result)
+			}
+			runtime.GetErrorText()
+			if string(runtime.sharedBytes) != tc.expected {
+				t.Fatalf("expected error text %q, got %q", tc.expected, string(runtime.sharedBytes))
+			}
+
+			// The failed request must not leak into the next one.
+			handler.nextFormatResult = NoChange()
+			runtime.sharedBytes = []byte("script.sh")
+			runtime.SetFilePath()
+			runtime.sharedBytes = []byte("echo test")
+			if result := runtime.Format(1); result != uint32(FormatResultNoChange) {
+				t.Fatalf("expected the next request to succeed, got %d", result)
+			}
+		})
+	}
+}
+
+func TestGetFormattedTextWithoutResultTraps(t *testing.T) {
+	runtime := NewRuntime[testConfig](&testHandler{})
+
 	defer func() {
 		if recover() == nil {
-			t.Fatal("expected panic when using released config id")
+			t.Fatal("expected panic without a formatted result")
+		}
+		if string(runtime.sharedBytes) != "get_formatted_text was called without a formatted result" {
+			t.Fatalf("expected the failure to be recorded in shared bytes, got %q", string(runtime.sharedBytes))
 		}
 	}()
-	runtime.GetResolvedConfig(1)
+	runtime.GetFormattedText()
 }
 
 func TestFormatFlowAndPathNormalization(t *testing.T) {
@@ -220,6 +365,72 @@ func TestFormatFlowAndPathNormalization(t *testing.T) {
 	}
 }
 
+func TestFormatCachesOverrideResolvedConfigs(t *testing.T) {
+	handler := &testHandler{
+		nextFormatResult: NoChange(),
+	}
+	runtime := NewRuntime[testConfig](handler)
+
+	runtime.sharedBytes = []byte(`{"plugin":{"value":1},"global":{}}`)
+	runtime.RegisterConfig(1)
+
+	format := func(override string) int {
+		t.Helper()
+		if override != "" {
+			runtime.sharedBytes = []byte(override)
+			runtime.SetOverrideConfig()
+		}
+		runtime.sharedBytes = []byte("script.sh")
+		runtime.SetFilePath()
+		runtime.sharedBytes = []byte("echo test")
+		runtime.Format(1)
+		return handler.lastFormatRequest.Config.Value
+	}
+
+	if value := format(""); value != 1 {
+		t.Fatalf("expected registered value 1, got %d", value)
+	}
+	if value := format(`{"value":5}`); value != 5 {
+		t.Fatalf("expected override value 5, got %d", value)
+	}
+	if value := format(`{"value":5}`); value != 5 {
+		t.Fatalf("expected cached override value 5, got %d", value)
+	}
+	if value := format(""); value != 1 {
+		t.Fatalf("expected registered value 1 after an override, got %d", value)
+	}
+	if handler.resolveConfigCallCount != 2 {
+		t.Fatalf("expected one ResolveConfig call per distinct config, got %d", handler.resolveConfigCallCount)
+	}
+
+	for i := range maxOverrideResolvedConfigs {
+		format(fmt.Sprintf(`{"value":%d}`, 10+i))
+	}
+	if value := format(`{"value":5}`); value != 5 {
+		t.Fatalf("expected evicted override value 5, got %d", value)
+	}
+	if handler.resolveConfigCallCount != 3+maxOverrideResolvedConfigs {
+		t.Fatalf("expected the oldest override to be evicted, got %d ResolveConfig calls", handler.resolveConfigCallCount)
+	}
+
+	calls := handler.resolveConfigCallCount
+	for i := range maxOverrideResolvedConfigs {
+		format(`{"value":5}`)
+		format(fmt.Sprintf(`{"value":%d}`, 100+i))
+	}
+	if value := format(`{"value":5}`); value != 5 {
+		t.Fatalf("expected override value 5, got %d", value)
+	}
+	if handler.resolveConfigCallCount != calls+maxOverrideResolvedConfigs {
+		t.Fatalf("expected an override in use to stay cached, got %d ResolveConfig calls", handler.resolveConfigCallCount-calls)
+	}
+
+	runtime.ReleaseConfig(1)
+	if len(runtime.configs.resolved) != 0 || len(runtime.configs.overrideResolved) != 0 {
+		t.Fatal("expected releasing the config to drop its resolved results")
+	}
+}
+
 func TestCheckConfigUpdatesResponse(t *testing.T) {
 	handler := &testHandler{
 		checkConfigUpdatesChanges: []ConfigChange{
@@ -275,7 +486,7 @@ func TestCheckConfigUpdatesResponse(t *testing.T) {
 }
 
 func TestParseRawFormatConfigDecodesPrimitiveValues(t *testing.T) {
-	config, err := parseRawFormatConfig([]byte(`{
+	config, diagnostics, err := parseRawFormatConfig([]byte(`{
 		"plugin": {
 			"indentWidth": 2,
 			"useTabs": false,
@@ -288,6 +499,9 @@ func TestParseRawFormatConfigDecodesPrimitiveValues(t *testing.T) {
 	if err != nil {
 		t.Fatalf("expected parse to succeed: %v", err)
 	}
+	if len(diagnostics) != 0 {
+		t.Fatalf("expected no diagnostics, got %v", diagnostics)
+	}
 
 	if getInt(config.Plugin["indentWidth"]) != 2 {
 		t.Fatalf("expected plugin indentWidth 2, got %#v", config.Plugin["indentWidth"])
@@ -391,6 +605,23 @@ func TestFormatWithHostErrorReadsErrorBytes(t *testing.T) {
 	}
 }
 
+func TestFormatWithHostUnknownResultIsError(t *testing.T) {
+	runtime := NewRuntime[testConfig](&testHandler{})
+	runtime.host = &testHostBridge{formatResultCode: 9}
+
+	result := runtime.formatWithHost(SyncHostFormatRequest{
+		FilePath:  "script.sh",
+		FileBytes: []byte("echo test\n"),
+	})
+
+	if result.Code != FormatResultError {
+		t.Fatalf("expected error result, got %d", result.Code)
+	}
+	if result.Err == nil || result.Err.Error() != "the host returned an unknown format result code: 9" {
+		t.Fatalf("expected unknown result code error, got %v", result.Err)
+	}
+}
+
 func TestFormatPassesCancellationTokenFromHost(t *testing.T) {
 	handler := &testHandler{
 		nextFormatResult: NoChange(),
@@ -414,6 +645,48 @@ func TestFormatPassesCancellationTokenFromHost(t *testing.T) {
 	}
 }
 
+func TestFormatReportsCancelledResultAsNoChange(t *testing.T) {
+	handler := &testHandler{
+		nextFormatResult: Cancelled(),
+	}
+	runtime := NewRuntime[testConfig](handler)
+
+	runtime.sharedBytes = []byte(`{"plugin":{},"global":{}}`)
+	runtime.RegisterConfig(1)
+
+	runtime.sharedBytes = []byte("script.sh")
+	runtime.SetFilePath()
+	runtime.sharedBytes = []byte("echo test")
+
+	resultCode := runtime.Format(1)
+	if resultCode != uint32(FormatResultNoChange) {
+		t.Fatalf("expected no-change result for cancellation, got %d", resultCode)
+	}
+	if runtime.hasFormattedText || runtime.hasErrorText {
+		t.Fatal("expected cancellation to leave no formatted or error text behind")
+	}
+}
+
+func assertDiagnosticMessages(t *testing.T, data []byte, expected ...string) {
+	t.Helper()
+
+	var diagnostics []struct {
+		PropertyName string `json:"propertyName"`
+		Message      string `json:"message"`
+	}
+	if err := json.Unmarshal(data, &diagnostics); err != nil {
+		t.Fatal(err)
+	}
+	if len(diagnostics) != len(expected) {
+		t.Fatalf("expected %d diagnostics, got %+v", len(expected), diagnostics)
+	}
+	for i, diagnostic := range diagnostics {
+		if diagnostic.Message != expected[i] {
+			t.Fatalf("expected diagnostic %q, got %q", expected[i], diagnostic.Message)
+		}
+	}
+}
+
 func getInt(value any) int {
 	switch value := value.(type) {
 	case float64:
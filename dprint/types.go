@@ -1,7 +1,10 @@
-// Package dprint provides minimal types and runtime glue for dprint Wasm plugins.
+// Package dprint provides minimal types and runtime glue for dprint Wasm and process plugins.
 package dprint
 
-import "errors"
+import (
+	"encoding/json"
+	"errors"
+)
 
 // PluginSchemaVersion is the dprint Wasm plugin schema version supported here.
 const PluginSchemaVersion uint32 = 4
@@ -75,6 +78,22 @@ type ConfigChange struct {
 	Value any              `json:"value,omitempty"`
 }
 
+// MarshalJSON writes the value of every change but a removal, even when the
+// value is null.
+func (c ConfigChange) MarshalJSON() ([]byte, error) {
+	if c.Kind == ConfigChangeKindRemove {
+		return json.Marshal(struct {
+			Path []any            `json:"path"`
+			Kind ConfigChangeKind `json:"kind"`
+		}{c.Path, c.Kind})
+	}
+	return json.Marshal(struct {
+		Path  []any            `json:"path"`
+		Kind  ConfigChangeKind `json:"kind"`
+		Value any              `json:"value"`
+	}{c.Path, c.Kind, c.Value})
+}
+
 // ResolveConfigurationResult contains resolved config and metadata.
 type ResolveConfigurationResult[T any] struct {
 	FileMatching FileMatchingInfo          `json:"fileMatching"`
@@ -130,6 +149,10 @@ const (
 	FormatResultNoChange FormatResultCode = 0
 	FormatResultChange   FormatResultCode = 1
 	FormatResultError    FormatResultCode = 2
+
+	// FormatResultCancelled reports that formatting stopped because the
+	// request was cancelled. It is reported to the host as no change.
+	FormatResultCancelled FormatResultCode = 3
 )
 
 // FormatResult is the result of a format request.
@@ -152,6 +175,11 @@ func Change(text []byte) FormatResult {
 	}
 }
 
+// Cancelled returns a result indicating formatting stopped after cancellation.
+func Cancelled() FormatResult {
+	return FormatResult{Code: FormatResultCancelled}
+}
+
 // FormatError returns a result containing a formatting error.
 func FormatError(err error) FormatResult {
 	if err == nil {
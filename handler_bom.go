@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/hrko/dprint-plugin-shfmt/dprint"
+)
+
+const (
+	bomPreserve = "preserve"
+	bomRemove   = "remove"
+)
+
+var utf8BOM = []byte("\xef\xbb\xbf")
+
+// cutBOM returns fileBytes without a leading UTF-8 byte order mark, and
+// whether there was one. The mark is not shell syntax, so it is removed
+// before the variant is detected and the file is parsed.
+func cutBOM(fileBytes []byte) ([]byte, bool) {
+	return bytes.CutPrefix(fileBytes, utf8BOM)
+}
+
+// restoreBOM puts the byte order mark back in front of formatted when the
+// input had one and the bom option asks to preserve it.
+func restoreBOM(formatted []byte, hadBOM bool, option string) []byte {
+	if !hadBOM || option == bomRemove {
+		return formatted
+	}
+	return append(append([]byte(nil), utf8BOM...), formatted...)
+}
+
+// shiftBOMColumns moves the line 1 positions of the diagnostics in err past
+// the byte order mark, for files that keep one. The file is parsed without the
+// mark, so the columns would otherwise be off by its length.
+func shiftBOMColumns(err error) {
+	var diagnostics dprint.Diagnostics
+	if !errors.As(err, &diagnostics) {
+		var diagnostic *dprint.Diagnostic
+		if !errors.As(err, &diagnostic) {
+			return
+		}
+		diagnostics = dprint.Diagnostics{diagnostic}
+	}
+	for _, diagnostic := range diagnostics {
+		for _, position := range []*dprint.Position{&diagnostic.Start, &diagnostic.End} {
+			if position.Line == 1 {
+				position.Column += uint32(len(utf8BOM))
+			}
+		}
+	}
+}
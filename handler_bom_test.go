@@ -0,0 +1,153 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/hrko/dprint-plugin-shfmt/dprint"
+)
+
+func TestFormatHandlesByteOrderMark(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		path   string
+		input  string
+		bom    string
+		format *dprint.FormatRange
+		want   string
+	}{
+		{
+			name:  "preserved before shebang",
+			path:  "script",
+			input: "\ufeff#!/usr/bin/env zsh\nprint -l   ${(j:,:)names}\n",
+			bom:   bomPreserve,
+			want:  "\ufeff#!/usr/bin/env zsh\nprint -l ${(j:,:)names}\n",
+		},
+		{
+			name:  "removed before shebang",
+			path:  "script",
+			input: "\ufeff#!/bin/sh\necho   a\n",
+			bom:   bomRemove,
+			want:  "#!/bin/sh\necho a\n",
+		},
+		{
+			name:  "kept out of the first word",
+			path:  "sample.sh",
+			input: "\ufeffecho   a\n",
+			want:  "\ufeffecho a\n",
+		},
+		{
+			name:  "never added",
+			path:  "sample.sh",
+			input: "echo   a\n",
+			bom:   bomPreserve,
+			want:  "echo a\n",
+		},
+		{
+			name:   "range offsets include the mark",
+			path:   "sample.sh",
+			input:  "\ufeffecho   a\necho   b\n",
+			format: &dprint.FormatRange{Start: 14, End: 20},
+			want:   "\ufeffecho   a\necho b\n",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			h := &handler{}
+			result := h.Format(
+				dprint.SyncFormatRequest[configuration]{
+					FilePath:  tc.path,
+					FileBytes: []byte(tc.input),
+					Range:     tc.format,
+					Config:    configuration{IndentWidth: 2, BOM: tc.bom},
+				},
+				nil,
+			)
+			if result.Code == dprint.FormatResultError {
+				t.Fatalf("unexpected error: %v", result.Err)
+			}
+			got := tc.input
+			if result.Code == dprint.FormatResultChange {
+				got = string(result.Text)
+			}
+			if got != tc.want {
+				t.Fatalf("unexpected output:\nwant %q\ngot  %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestFormatCountsKeptByteOrderMarkInColumns(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		input string
+		bom   string
+		want  string
+	}{
+		{
+			name:  "preserved",
+			input: "\ufeffecho a >\nfor i in 1 2; echo $i; done\n",
+			bom:   bomPreserve,
+			want:  "sample.sh:1:11: > must be followed by a word [syntax-error]\nsample.sh:2:1: \"for foo [in words]\" must be followed by \"do\" [syntax-error]",
+		},
+		{
+			name:  "removed",
+			input: "\ufeffecho a >\nfor i in 1 2; echo $i; done\n",
+			bom:   bomRemove,
+			want:  "sample.sh:1:8: > must be followed by a word [syntax-error]\nsample.sh:2:1: \"for foo [in words]\" must be followed by \"do\" [syntax-error]",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			h := &handler{}
+			result := h.Format(
+				dprint.SyncFormatRequest[configuration]{
+					FilePath:  "sample.sh",
+					FileBytes: []byte(tc.input),
+					Config:    configuration{IndentWidth: 2, BOM: tc.bom, MaxParseErrors: 10},
+				},
+				nil,
+			)
+			if result.Code != dprint.FormatResultError {
+				t.Fatalf("expected error result, got %d", result.Code)
+			}
+			got := strings.Split(result.Err.Error(), "\n  hint:")[0]
+			if got != tc.want {
+				t.Fatalf("unexpected error:\nwant %q\ngot  %q", tc.want, got)
+			}
+
+			var diagnostic *dprint.Diagnostic
+			if !errors.As(result.Err, &diagnostic) || diagnostic.FilePath != "sample.sh" || diagnostic.End.Column != diagnostic.Start.Column+1 {
+				t.Fatalf("unexpected diagnostic: %#v", diagnostic)
+			}
+		})
+	}
+}
+
+func TestResolveBOM(t *testing.T) {
+	t.Parallel()
+
+	h := &handler{}
+	if got := h.ResolveConfig(dprint.ConfigKeyMap{}, nil).Config.BOM; got != bomPreserve {
+		t.Fatalf("expected %q by default, got %q", bomPreserve, got)
+	}
+
+	result := h.ResolveConfig(dprint.ConfigKeyMap{"bom": "add"}, nil)
+	if result.Config.BOM != bomPreserve {
+		t.Fatalf("expected fallback to %q, got %q", bomPreserve, result.Config.BOM)
+	}
+	if len(result.Diagnostics) != 1 || result.Diagnostics[0]["propertyName"] != "bom" {
+		t.Fatalf("unexpected diagnostics: %#v", result.Diagnostics)
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+
+	"github.com/hrko/dprint-plugin-shfmt/dprint"
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// errCancelled stops parsing or printing once the request has been cancelled.
+var errCancelled = errors.New("formatting was cancelled")
+
+// parseCancellable parses src like parser.Parse, checking token after every
+// top-level statement and whenever the parser reads more input, so that
+// parsing stops at the next statement once the request is cancelled.
+func parseCancellable(
+	parser *syntax.Parser,
+	src []byte,
+	name string,
+	token dprint.CancellationToken,
+) (*syntax.File, error) {
+	stmts, err := parseStmts(parser, cancellableReader{reader: bytes.NewReader(src), token: token}, token)
+	if err != nil {
+		return nil, err
+	}
+	prog := &syntax.File{Name: name, Stmts: stmts}
+
+	// Parser.Stmts drops the comments after the last statement, which Parse
+	// keeps in File.Last, so they are parsed from the rest of src.
+	start, line := uint(0), uint(1)
+	if len(prog.Stmts) > 0 {
+		line = stmtLineSpan(prog.Stmts[len(prog.Stmts)-1]).end + 1
+		start = min(lineStartOffset(lineOffsets(src), line), uint(len(src)))
+	}
+	rest, err := parser.Parse(bytes.NewReader(src[start:]), name)
+	if err != nil {
+		return nil, err
+	}
+	for _, comment := range rest.Last {
+		hash := comment.Hash
+		comment.Hash = syntax.NewPos(hash.Offset()+start, hash.Line()+line-1, hash.Col())
+		prog.Last = append(prog.Last, comment)
+	}
+	return prog, nil
+}
+
+// parseStmts parses the top-level statements read from r, returning
+// errCancelled after the first statement parsed once token is cancelled.
+func parseStmts(parser *syntax.Parser, r io.Reader, token dprint.CancellationToken) ([]*syntax.Stmt, error) {
+	var stmts []*syntax.Stmt
+	cancelled := false
+	err := parser.Stmts(r, func(stmt *syntax.Stmt) bool {
+		stmts = append(stmts, stmt)
+		cancelled = token.IsCancelled()
+		return !cancelled
+	})
+	if cancelled {
+		return nil, errCancelled
+	}
+	return stmts, err
+}
+
+// printCancellable prints prog like printer.Print, checking token before every
+// run of top-level statements not separated by a blank line. The runs are
+// printed on their own, since the printer lines up the comments of adjacent
+// statements, and joined with the blank lines between them. A run does not end
+// after a heredoc, whose closing line the printer spaces on its own.
+func printCancellable(
+	printer *syntax.Printer,
+	w io.Writer,
+	prog *syntax.File,
+	minify bool,
+	token dprint.CancellationToken,
+) error {
+	w = cancellableWriter{writer: w, token: token}
+	if minify {
+		// Minified output has no blank lines to split it at.
+		if token.IsCancelled() {
+			return errCancelled
+		}
+		return printer.Print(w, prog)
+	}
+
+	var run []*syntax.Stmt
+	end, heredoc := uint(0), false
+	printRun := func(last []syntax.Comment) error {
+		if token.IsCancelled() {
+			return errCancelled
+		}
+		return printer.Print(w, &syntax.File{Name: prog.Name, Stmts: run, Last: last})
+	}
+	for _, stmt := range prog.Stmts {
+		span := stmtLineSpan(stmt)
+		if len(run) > 0 && span.start > end+1 && !heredoc {
+			if err := printRun(nil); err != nil {
+				return err
+			}
+			if _, err := io.WriteString(w, "\n"); err != nil {
+				return err
+			}
+			run = nil
+		}
+		run = append(run, stmt)
+		end, heredoc = span.end, hasHeredoc(stmt)
+	}
+	if len(prog.Last) > 0 && len(run) > 0 && prog.Last[0].Pos().Line() > end+1 && !heredoc {
+		if err := printRun(nil); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, "\n"); err != nil {
+			return err
+		}
+		run = nil
+	}
+	return printRun(prog.Last)
+}
+
+func hasHeredoc(stmt *syntax.Stmt) bool {
+	found := false
+	syntax.Walk(stmt, func(node syntax.Node) bool {
+		if redirect, ok := node.(*syntax.Redirect); ok && (redirect.Op == syntax.Hdoc || redirect.Op == syntax.DashHdoc) {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// cancellableReader checks the cancellation token each time the parser pulls
+// another chunk of input, which it does about once per kilobyte, so that a
+// single long statement does not have to be read to its end.
+type cancellableReader struct {
+	reader io.Reader
+	token  dprint.CancellationToken
+}
+
+func (r cancellableReader) Read(p []byte) (int, error) {
+	if r.token.IsCancelled() {
+		return 0, errCancelled
+	}
+	return r.reader.Read(p)
+}
+
+// cancellableWriter checks the cancellation token each time the printer
+// flushes its buffer, which it does about once every four kilobytes of output
+// and at the end of each run of statements.
+type cancellableWriter struct {
+	writer io.Writer
+	token  dprint.CancellationToken
+}
+
+func (w cancellableWriter) Write(p []byte) (int, error) {
+	if w.token.IsCancelled() {
+		return 0, errCancelled
+	}
+	return w.writer.Write(p)
+}
+
+func cancellationToken[T any](request dprint.SyncFormatRequest[T]) dprint.CancellationToken {
+	if request.Token == nil {
+		return dprint.NullCancellationToken{}
+	}
+	return request.Token
+}
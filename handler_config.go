@@ -2,20 +2,48 @@ package main
 
 import "github.com/hrko/dprint-plugin-shfmt/dprint"
 
-//go:generate go run github.com/hrko/dprint-plugin-shfmt/dprint/cmd/gen-config-resolver -type configuration -out handler_config_generated.go -extra-known-keys locked
+//go:generate go run github.com/hrko/dprint-plugin-shfmt/dprint/cmd/gen-config-resolver -type configuration -out handler_config_generated.go -extra-known-keys locked -validate validateConfiguration -aliases configurationAliases
 //go:generate go run github.com/hrko/dprint-plugin-shfmt/dprint/cmd/gen-json-schema -type configuration -out schema.json -schema-id https://raw.githubusercontent.com/hrko/dprint-plugin-shfmt/main/schema.json -include-locked -locked-description "Whether the configuration is not allowed to be overridden or extended."
 
 type configuration struct {
-	IndentWidth      uint32 `description:"Number of spaces per indentation level when not using tabs."                                        dprint:"default=2,global"     json:"indentWidth"`
-	UseTabs          bool   `description:"Whether to use tabs for indentation."                                                               dprint:"default=false,global" json:"useTabs"`
-	BinaryNextLine   bool   `description:"Whether binary operators should be placed at the start of the next line when line wrapping occurs." dprint:"default=false"        json:"binaryNextLine"`
-	SwitchCaseIndent bool   `description:"Whether switch case bodies should be indented."                                                     dprint:"default=false"        json:"switchCaseIndent"`
-	SpaceRedirects   bool   `description:"Whether to insert a space after redirection operators."                                             dprint:"default=false"        json:"spaceRedirects"`
-	FuncNextLine     bool   `description:"Whether to place function opening braces on the next line."                                         dprint:"default=false"        json:"funcNextLine"`
-	Minify           bool   `description:"Whether to minify shell scripts when printing."                                                     dprint:"default=false"        json:"minify"`
+	IndentWidth      uint32 `description:"Number of spaces per indentation level when not using tabs."                                                                                                                                                                            dprint:"default=2,global,max=16,spellings=i"                                                         json:"indentWidth"`
+	LineWidth        uint32 `description:"Column at which long commands are wrapped. Zero disables wrapping."                                                                                                                                                                     dprint:"default=0,global,spellings=max_line_length"                                                  json:"lineWidth"`
+	UseTabs          bool   `description:"Whether to use tabs for indentation."                                                                                                                                                                                                   dprint:"default=false,global"                                                                        json:"useTabs"`
+	BinaryNextLine   bool   `description:"Whether binary operators should be placed at the start of the next line when line wrapping occurs."                                                                                                                                     dprint:"default=false,spellings=bn"                                                                  json:"binaryNextLine"`
+	SwitchCaseIndent bool   `description:"Whether switch case bodies should be indented."                                                                                                                                                                                         dprint:"default=false,spellings=ci"                                                                  json:"switchCaseIndent"`
+	SpaceRedirects   bool   `description:"Whether to insert a space after redirection operators."                                                                                                                                                                                 dprint:"default=false,spellings=sr"                                                                  json:"spaceRedirects"`
+	FuncNextLine     bool   `description:"Whether to place function opening braces on the next line."                                                                                                                                                                             dprint:"default=false,spellings=fn"                                                                  json:"funcNextLine"`
+	Minify           bool   `description:"Whether to minify shell scripts when printing."                                                                                                                                                                                         dprint:"default=false,spellings=mn"                                                                  json:"minify"`
+	Simplify         bool   `description:"Whether to simplify shell scripts before printing, like shfmt -s."                                                                                                                                                                      dprint:"default=false,spellings=s"                                                                   json:"simplify"`
+	Verify           bool   `description:"Whether to check that the formatted output parses to the same syntax tree as the input."                                                                                                                                                dprint:"default=false"                                                                               json:"verify"`
+	CheckStability   bool   `description:"Whether to format the output a second time and report an error if it changes again."                                                                                                                                                    dprint:"default=false"                                                                               json:"checkStability"`
+	MaxParseErrors   uint32 `description:"Maximum number of syntax errors reported for a file that does not parse."                                                                                                                                                               dprint:"default=10,min=1"                                                                            json:"maxParseErrors"`
+	NewLineKind      string `description:"Line ending used in the output. \"auto\" keeps the line ending most lines of the file use, and \"system\" uses the line ending of the operating system the plugin runs on. Heredoc bodies keep their line endings."                     dprint:"default=auto,global,enum=auto|lf|crlf|system,spellings=end_of_line"                          json:"newLineKind"`
+	BOM              string `description:"What to do with a UTF-8 byte order mark at the start of a file. \"preserve\" keeps it and \"remove\" strips it. Files without one are never given one."                                                                                 dprint:"default=preserve,enum=preserve|remove"                                                       json:"bom"`
+	Variant          string `description:"Shell language variant used to parse files. \"auto\" detects it from a ShellCheck shell= directive, the shebang, a vim or Emacs modeline, then the fileNames and fileExtensions mappings and the file extension, falling back to bash." dprint:"default=auto,enum=auto|posix|bash|mksh|bats|zsh,spellings=ln|language_dialect|shell_variant" json:"variant"`
+
+	Overrides        []variantOverride `description:"Per-glob settings applied to matching files. When several entries match a file, the last one wins. Patterns without a slash match the file name; other patterns match the end of the file path." dprint:"validate=validateVariantOverride" json:"overrides"`
+	FileNames        map[string]string `description:"Maps additional file names to the shell variant they are parsed as. Common dotfiles such as .bashrc, .zshrc and .profile, and PKGBUILD and APKBUILD are included by default."                    dprint:"enum=posix|bash|mksh|bats|zsh"    json:"fileNames"`
+	FileExtensions   map[string]string `description:"Maps additional file extensions to the shell variant they are parsed as."                                                                                                                        dprint:"enum=posix|bash|mksh|bats|zsh"    json:"fileExtensions"`
+	HeredocLanguages map[string]string `description:"Maps heredoc delimiters or delimiter glob patterns to the file extension used to format quoted heredoc bodies through the host, for example {\"JSON\": \"json\", \"*_YAML\": \"yaml\"}."                                                   json:"heredocLanguages"`
+
+	IgnoreDirective      string `description:"Comment text that keeps the next statement exactly as written. Text after the directive is treated as an explanation." dprint:"default=dprint-ignore,pattern=^[^\\r\\n]*\\S[^\\r\\n]*$"       json:"ignoreDirective"`
+	IgnoreStartDirective string `description:"Comment text that starts a region kept exactly as written."                                                            dprint:"default=dprint-ignore-start,pattern=^[^\\r\\n]*\\S[^\\r\\n]*$" json:"ignoreStartDirective"`
+	IgnoreEndDirective   string `description:"Comment text that ends a region started with the ignore start directive."                                              dprint:"default=dprint-ignore-end,pattern=^[^\\r\\n]*\\S[^\\r\\n]*$"   json:"ignoreEndDirective"`
+	IgnoreFileDirective  string `description:"Comment text that leaves the whole file unformatted when it appears in the leading comment block."                     dprint:"default=dprint-ignore-file,pattern=^[^\\r\\n]*\\S[^\\r\\n]*$"  json:"ignoreFileDirective"`
 }
 
-var fileExtensions = []string{"sh", "bash", "zsh", "mksh", "bats"}
+// configurationAliases are the keys shfmt reads from EditorConfig files, which
+// are accepted so that settings can be copied over, and rewritten to ours by
+// dprint config update.
+var configurationAliases = []dprint.ConfigAlias{
+	{Alias: "indent_size", Key: "indentWidth"},
+	{Alias: "indent_style", Key: "useTabs", Values: map[string]any{"tab": true, "space": false}},
+	{Alias: "binary_next_line", Key: "binaryNextLine"},
+	{Alias: "switch_case_indent", Key: "switchCaseIndent"},
+	{Alias: "space_redirects", Key: "spaceRedirects"},
+	{Alias: "function_next_line", Key: "funcNextLine"},
+}
 
 func (h *handler) ResolveConfig(
 	config dprint.ConfigKeyMap,
@@ -26,13 +54,36 @@ func (h *handler) ResolveConfig(
 		global,
 		generatedConfigurationResolverSpec,
 	)
+	resolved.FileNames = resolveFileNames(resolved.FileNames, &diagnostics)
+	resolved.FileExtensions = resolveFileExtensions(resolved.FileExtensions, &diagnostics)
+	resolved.HeredocLanguages = resolveHeredocLanguages(resolved.HeredocLanguages, &diagnostics)
+	trimIgnoreDirectives(&resolved)
 
 	return dprint.ResolveConfigurationResult[configuration]{
-		FileMatching: dprint.FileMatchingInfo{
-			FileExtensions: append([]string(nil), fileExtensions...),
-			FileNames:      []string{},
-		},
-		Diagnostics: diagnostics,
-		Config:      resolved,
+		FileMatching: fileMatchingInfo(resolved),
+		Diagnostics:  diagnostics,
+		Config:       resolved,
+	}
+}
+
+// validateConfiguration checks the rules that involve more than one option.
+// An indent width of zero makes shfmt indent with tabs, and minified output
+// has no line for a function's opening brace to move to.
+func validateConfiguration(config configuration) []dprint.ConfigProblem {
+	var problems []dprint.ConfigProblem
+	if config.IndentWidth == 0 && !config.UseTabs {
+		problems = append(problems, dprint.ConfigProblem{
+			Key:      "indentWidth",
+			Expected: "at least 1 when 'useTabs' is false",
+			Value:    config.IndentWidth,
+		})
+	}
+	if config.Minify && config.FuncNextLine {
+		problems = append(problems, dprint.ConfigProblem{
+			Key:      "funcNextLine",
+			Expected: "false when 'minify' is true",
+			Value:    config.FuncNextLine,
+		})
 	}
+	return problems
 }
@@ -2,13 +2,19 @@
 
 package main
 
-import "github.com/hrko/dprint-plugin-shfmt/dprint"
+import (
+	"regexp"
+
+	"github.com/hrko/dprint-plugin-shfmt/dprint"
+)
 
 var generatedConfigurationResolverSpec = dprint.ConfigResolverSpec[configuration]{
 	UInt32Fields: []dprint.UInt32ConfigFieldSpec[configuration]{
 		{
 			Key:                 "indentWidth",
 			DefaultValue:        2,
+			Max:                 16,
+			HasMax:              true,
 			AllowGlobalOverride: true,
 			Get: func(config configuration) uint32 {
 				return config.IndentWidth
@@ -17,6 +23,29 @@ var generatedConfigurationResolverSpec = dprint.ConfigResolverSpec[configuration
 				config.IndentWidth = value
 			},
 		},
+		{
+			Key:                 "lineWidth",
+			DefaultValue:        0,
+			AllowGlobalOverride: true,
+			Get: func(config configuration) uint32 {
+				return config.LineWidth
+			},
+			Set: func(config *configuration, value uint32) {
+				config.LineWidth = value
+			},
+		},
+		{
+			Key:                 "maxParseErrors",
+			DefaultValue:        10,
+			Min:                 1,
+			AllowGlobalOverride: false,
+			Get: func(config configuration) uint32 {
+				return config.MaxParseErrors
+			},
+			Set: func(config *configuration, value uint32) {
+				config.MaxParseErrors = value
+			},
+		},
 	},
 	BoolFields: []dprint.BoolConfigFieldSpec[configuration]{
 		{
@@ -85,15 +114,228 @@ var generatedConfigurationResolverSpec = dprint.ConfigResolverSpec[configuration
 				config.Minify = value
 			},
 		},
+		{
+			Key:                 "simplify",
+			DefaultValue:        false,
+			AllowGlobalOverride: false,
+			Get: func(config configuration) bool {
+				return config.Simplify
+			},
+			Set: func(config *configuration, value bool) {
+				config.Simplify = value
+			},
+		},
+		{
+			Key:                 "verify",
+			DefaultValue:        false,
+			AllowGlobalOverride: false,
+			Get: func(config configuration) bool {
+				return config.Verify
+			},
+			Set: func(config *configuration, value bool) {
+				config.Verify = value
+			},
+		},
+		{
+			Key:                 "checkStability",
+			DefaultValue:        false,
+			AllowGlobalOverride: false,
+			Get: func(config configuration) bool {
+				return config.CheckStability
+			},
+			Set: func(config *configuration, value bool) {
+				config.CheckStability = value
+			},
+		},
+	},
+	StringFields: []dprint.StringConfigFieldSpec[configuration]{
+		{
+			Key:                 "newLineKind",
+			DefaultValue:        "auto",
+			AllowedValues:       []string{"auto", "lf", "crlf", "system"},
+			AllowGlobalOverride: true,
+			Get: func(config configuration) string {
+				return config.NewLineKind
+			},
+			Set: func(config *configuration, value string) {
+				config.NewLineKind = value
+			},
+		},
+		{
+			Key:                 "bom",
+			DefaultValue:        "preserve",
+			AllowedValues:       []string{"preserve", "remove"},
+			AllowGlobalOverride: false,
+			Get: func(config configuration) string {
+				return config.BOM
+			},
+			Set: func(config *configuration, value string) {
+				config.BOM = value
+			},
+		},
+		{
+			Key:                 "variant",
+			DefaultValue:        "auto",
+			AllowedValues:       []string{"auto", "posix", "bash", "mksh", "bats", "zsh"},
+			AllowGlobalOverride: false,
+			Get: func(config configuration) string {
+				return config.Variant
+			},
+			Set: func(config *configuration, value string) {
+				config.Variant = value
+			},
+		},
+		{
+			Key:                 "ignoreDirective",
+			DefaultValue:        "dprint-ignore",
+			Pattern:             regexp.MustCompile(`^[^\r\n]*\S[^\r\n]*$`),
+			AllowGlobalOverride: false,
+			Get: func(config configuration) string {
+				return config.IgnoreDirective
+			},
+			Set: func(config *configuration, value string) {
+				config.IgnoreDirective = value
+			},
+		},
+		{
+			Key:                 "ignoreStartDirective",
+			DefaultValue:        "dprint-ignore-start",
+			Pattern:             regexp.MustCompile(`^[^\r\n]*\S[^\r\n]*$`),
+			AllowGlobalOverride: false,
+			Get: func(config configuration) string {
+				return config.IgnoreStartDirective
+			},
+			Set: func(config *configuration, value string) {
+				config.IgnoreStartDirective = value
+			},
+		},
+		{
+			Key:                 "ignoreEndDirective",
+			DefaultValue:        "dprint-ignore-end",
+			Pattern:             regexp.MustCompile(`^[^\r\n]*\S[^\r\n]*$`),
+			AllowGlobalOverride: false,
+			Get: func(config configuration) string {
+				return config.IgnoreEndDirective
+			},
+			Set: func(config *configuration, value string) {
+				config.IgnoreEndDirective = value
+			},
+		},
+		{
+			Key:                 "ignoreFileDirective",
+			DefaultValue:        "dprint-ignore-file",
+			Pattern:             regexp.MustCompile(`^[^\r\n]*\S[^\r\n]*$`),
+			AllowGlobalOverride: false,
+			Get: func(config configuration) string {
+				return config.IgnoreFileDirective
+			},
+			Set: func(config *configuration, value string) {
+				config.IgnoreFileDirective = value
+			},
+		},
+	},
+	StringMapFields: []dprint.StringMapConfigFieldSpec[configuration]{
+		{
+			Key:           "fileNames",
+			AllowedValues: []string{"posix", "bash", "mksh", "bats", "zsh"},
+			Set: func(config *configuration, value map[string]string) {
+				config.FileNames = value
+			},
+		},
+		{
+			Key:           "fileExtensions",
+			AllowedValues: []string{"posix", "bash", "mksh", "bats", "zsh"},
+			Set: func(config *configuration, value map[string]string) {
+				config.FileExtensions = value
+			},
+		},
+		{
+			Key: "heredocLanguages",
+			Set: func(config *configuration, value map[string]string) {
+				config.HeredocLanguages = value
+			},
+		},
+	},
+	ObjectSliceFields: []dprint.ObjectSliceConfigFieldSpec[configuration]{
+		dprint.NewObjectSliceConfigFieldSpec(
+			"overrides",
+			dprint.ConfigResolverSpec[variantOverride]{
+				StringFields: []dprint.StringConfigFieldSpec[variantOverride]{
+					{
+						Key:                 "files",
+						Required:            true,
+						AllowGlobalOverride: false,
+						Get: func(config variantOverride) string {
+							return config.Files
+						},
+						Set: func(config *variantOverride, value string) {
+							config.Files = value
+						},
+					},
+					{
+						Key:                 "variant",
+						Required:            true,
+						AllowedValues:       []string{"auto", "posix", "bash", "mksh", "bats", "zsh"},
+						AllowGlobalOverride: false,
+						Get: func(config variantOverride) string {
+							return config.Variant
+						},
+						Set: func(config *variantOverride, value string) {
+							config.Variant = value
+						},
+					},
+				},
+				KnownKeys: []string{
+					"files",
+					"variant",
+				},
+				Validate: validateVariantOverride,
+			},
+			func(config *configuration, value []variantOverride) {
+				config.Overrides = value
+			},
+		),
 	},
 	KnownKeys: []string{
 		"indentWidth",
+		"lineWidth",
 		"useTabs",
 		"binaryNextLine",
 		"switchCaseIndent",
 		"spaceRedirects",
 		"funcNextLine",
 		"minify",
+		"simplify",
+		"verify",
+		"checkStability",
+		"maxParseErrors",
+		"newLineKind",
+		"bom",
+		"variant",
+		"overrides",
+		"fileNames",
+		"fileExtensions",
+		"heredocLanguages",
+		"ignoreDirective",
+		"ignoreStartDirective",
+		"ignoreEndDirective",
+		"ignoreFileDirective",
 		"locked",
 	},
+	Spellings: map[string]string{
+		"i":                "indentWidth",
+		"max_line_length":  "lineWidth",
+		"bn":               "binaryNextLine",
+		"ci":               "switchCaseIndent",
+		"sr":               "spaceRedirects",
+		"fn":               "funcNextLine",
+		"mn":               "minify",
+		"s":                "simplify",
+		"end_of_line":      "newLineKind",
+		"ln":               "variant",
+		"language_dialect": "variant",
+		"shell_variant":    "variant",
+	},
+	Aliases:  configurationAliases,
+	Validate: validateConfiguration,
 }
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/hrko/dprint-plugin-shfmt/dprint"
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// Codes of the diagnostics reported for files that do not parse.
+const (
+	codeSyntaxError       = "syntax-error"
+	codeUnsupportedSyntax = "unsupported-syntax"
+	codeTooManyErrors     = "too-many-errors"
+)
+
+// Codes of the diagnostics reported for files that parse but cannot be
+// formatted.
+const (
+	codeZshUnterminated       = "zsh-unterminated"
+	codeZshReservedWord       = "zsh-reserved-word"
+	codeZshRestoreFailed      = "zsh-restore-failed"
+	codeHeredocFormatFailed   = "heredoc-format-failed"
+	codeUnmatchedIgnore       = "unmatched-ignore-directive"
+	codeIgnoredRangesConflict = "ignored-ranges-conflict"
+	codeVerifyFailed          = "verify-failed"
+)
+
+// syntaxDiagnostics converts the errors returned by parseErrors into
+// diagnostics. src is the text the positions refer to. The hint names the
+// variant the file was parsed as and how it was chosen.
+func syntaxDiagnostics(errs []error, filePath string, src []byte, variant syntax.LangVariant, source string) error {
+	hint := fmt.Sprintf("parsed as %s (%s)", variantName(variant), source)
+	if variant == langZsh {
+		hint += "; zsh files are parsed as bash with zsh-only syntax protected, so some zsh constructs are not supported"
+	}
+
+	diagnostics := make(dprint.Diagnostics, 0, len(errs))
+	for _, err := range errs {
+		var diagnostic *dprint.Diagnostic
+		var parseErr syntax.ParseError
+		var langErr syntax.LangError
+		switch {
+		case errors.As(err, &diagnostic):
+		case errors.As(err, &parseErr):
+			diagnostic = &dprint.Diagnostic{Code: codeSyntaxError, Message: parseErr.Text, Hint: hint}
+			diagnostic.Start, diagnostic.End = tokenSpan(src, parseErr.Pos)
+		case errors.As(err, &langErr):
+			// The error text has the position in front; the rest is the
+			// explanation of which variants support the feature.
+			prefix := langErr.Pos.String() + ": "
+			if langErr.Filename != "" {
+				prefix = langErr.Filename + ":" + prefix
+			}
+			message := strings.TrimPrefix(langErr.Error(), prefix)
+			diagnostic = &dprint.Diagnostic{Code: codeUnsupportedSyntax, Message: message, Hint: hint}
+			diagnostic.Start, diagnostic.End = tokenSpan(src, langErr.Pos)
+		default:
+			diagnostic = &dprint.Diagnostic{Code: codeSyntaxError, Message: err.Error()}
+		}
+		diagnostic.FilePath = filePath
+		diagnostic.Severity = dprint.SeverityError
+		diagnostics = append(diagnostics, diagnostic)
+	}
+
+	if len(diagnostics) == 1 {
+		return diagnostics[0]
+	}
+	return diagnostics
+}
+
+// tokenSpan returns the position of pos and of the end of the word starting
+// there, so that editors can underline it.
+func tokenSpan(src []byte, pos syntax.Pos) (dprint.Position, dprint.Position) {
+	start := dprint.Position{Line: uint32(pos.Line()), Column: uint32(pos.Col())}
+	end := start
+	for offset := int(pos.Offset()); offset < len(src); offset++ {
+		switch src[offset] {
+		case ' ', '\t', '\r', '\n':
+			return start, end
+		}
+		end.Column++
+	}
+	return start, end
+}
+
+// diagnosticAt returns an error diagnostic spanning the bytes of src from
+// start to end.
+func diagnosticAt(filePath string, src []byte, start int, end int, code string, format string, args ...any) *dprint.Diagnostic {
+	return &dprint.Diagnostic{
+		FilePath: filePath,
+		Start:    offsetPosition(src, start),
+		End:      offsetPosition(src, end),
+		Severity: dprint.SeverityError,
+		Code:     code,
+		Message:  fmt.Sprintf(format, args...),
+	}
+}
+
+// nodeDiagnostic returns an error diagnostic spanning node.
+func nodeDiagnostic(filePath string, node syntax.Node, code string, format string, args ...any) *dprint.Diagnostic {
+	return &dprint.Diagnostic{
+		FilePath: filePath,
+		Start:    syntaxPosition(node.Pos()),
+		End:      syntaxPosition(node.End()),
+		Severity: dprint.SeverityError,
+		Code:     code,
+		Message:  fmt.Sprintf(format, args...),
+	}
+}
+
+func offsetPosition(src []byte, offset int) dprint.Position {
+	lineStart := bytes.LastIndexByte(src[:offset], '\n') + 1
+	return dprint.Position{
+		Line:   uint32(1 + bytes.Count(src[:offset], []byte("\n"))),
+		Column: uint32(offset - lineStart + 1),
+	}
+}
+
+func syntaxPosition(pos syntax.Pos) dprint.Position {
+	return dprint.Position{Line: uint32(pos.Line()), Column: uint32(pos.Col())}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/hrko/dprint-plugin-shfmt/dprint"
+)
+
+func TestFormatReportsDiagnostics(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		path   string
+		input  string
+		config configuration
+		want   dprint.Diagnostic
+	}{
+		{
+			name:   "syntax error spans the offending token",
+			path:   "sample.sh",
+			input:  "echo a\nfoo && fi\n",
+			config: configuration{MaxParseErrors: 10},
+			want: dprint.Diagnostic{
+				FilePath: "sample.sh",
+				Start:    dprint.Position{Line: 2, Column: 5},
+				End:      dprint.Position{Line: 2, Column: 7},
+				Severity: dprint.SeverityError,
+				Code:     codeSyntaxError,
+				Message:  "&& must be followed by a statement",
+				Hint:     "parsed as posix (detected from the file extension)",
+			},
+		},
+		{
+			name:   "unsupported syntax names the variant source",
+			path:   "sample",
+			input:  "#!/bin/sh\na=(1 2)\n",
+			config: configuration{MaxParseErrors: 10},
+			want: dprint.Diagnostic{
+				FilePath: "sample",
+				Start:    dprint.Position{Line: 2, Column: 3},
+				End:      dprint.Position{Line: 2, Column: 5},
+				Severity: dprint.SeverityError,
+				Code:     codeUnsupportedSyntax,
+				Message:  "arrays are a bash/mksh feature; tried parsing as posix",
+				Hint:     "parsed as posix (detected from the shebang)",
+			},
+		},
+		{
+			name:   "variant option",
+			path:   "sample.sh",
+			input:  "if true; then\n",
+			config: configuration{Variant: "bash", MaxParseErrors: 10},
+			want: dprint.Diagnostic{
+				FilePath: "sample.sh",
+				Start:    dprint.Position{Line: 1, Column: 1},
+				End:      dprint.Position{Line: 1, Column: 3},
+				Severity: dprint.SeverityError,
+				Code:     codeSyntaxError,
+				Message:  "if statement must end with \"fi\"",
+				Hint:     "parsed as bash (set by the variant option)",
+			},
+		},
+		{
+			name:   "unterminated zsh syntax spans the rest of the line",
+			path:   "sample.zsh",
+			input:  "echo a\nprint ${(j:,:)arr\n",
+			config: configuration{MaxParseErrors: 10},
+			want: dprint.Diagnostic{
+				FilePath: "sample.zsh",
+				Start:    dprint.Position{Line: 2, Column: 7},
+				End:      dprint.Position{Line: 2, Column: 18},
+				Severity: dprint.SeverityError,
+				Code:     codeZshUnterminated,
+				Message:  "unterminated zsh parameter expansion",
+			},
+		},
+		{
+			name:  "ignore directive in zsh points at the original source",
+			path:  "sample.zsh",
+			input: "echo ${(U)a} # dprint-ignore-end\n",
+			config: configuration{
+				IgnoreStartDirective: "dprint-ignore-start",
+				IgnoreEndDirective:   "dprint-ignore-end",
+			},
+			want: dprint.Diagnostic{
+				FilePath: "sample.zsh",
+				Start:    dprint.Position{Line: 1, Column: 14},
+				End:      dprint.Position{Line: 1, Column: 33},
+				Severity: dprint.SeverityError,
+				Code:     codeUnmatchedIgnore,
+				Message:  "'dprint-ignore-end' has no matching 'dprint-ignore-start'",
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			h := &handler{}
+			result := h.Format(
+				dprint.SyncFormatRequest[configuration]{
+					FilePath:  tc.path,
+					FileBytes: []byte(tc.input),
+					Config:    tc.config,
+				},
+				nil,
+			)
+			if result.Code != dprint.FormatResultError {
+				t.Fatalf("expected error result, got %d", result.Code)
+			}
+			var diagnostic *dprint.Diagnostic
+			if !errors.As(result.Err, &diagnostic) {
+				t.Fatalf("expected a diagnostic, got %T: %v", result.Err, result.Err)
+			}
+			if *diagnostic != tc.want {
+				t.Fatalf("unexpected diagnostic:\nwant %+v\ngot  %+v", tc.want, *diagnostic)
+			}
+		})
+	}
+}
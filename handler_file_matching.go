@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/hrko/dprint-plugin-shfmt/dprint"
+	"mvdan.cc/sh/v3/syntax"
+)
+
+const (
+	fileNamesKey      = "fileNames"
+	fileExtensionsKey = "fileExtensions"
+)
+
+// fileExtensions are always handled. Their variants are picked by
+// variantFromFilePath.
+var fileExtensions = []string{"sh", "bash", "zsh", "mksh", "bats"}
+
+// defaultFileNames are extensionless shell files handled without any
+// configuration. Entries in the fileNames option are added to these and take
+// precedence over them.
+//
+// Autoconf inputs such as configure.ac and aclocal.m4 are left out: they are
+// m4 macro calls like AC_INIT([name], [1.0]) with shell mixed in, which the
+// shell parser rejects. The configure scripts generated from them are shell,
+// but are build output that should not be reformatted.
+var defaultFileNames = map[string]string{
+	".bashrc":       "bash",
+	".bash_profile": "bash",
+	".bash_login":   "bash",
+	".bash_logout":  "bash",
+	".bash_aliases": "bash",
+	".zshrc":        "zsh",
+	".zshenv":       "zsh",
+	".zprofile":     "zsh",
+	".zlogin":       "zsh",
+	".zlogout":      "zsh",
+	".mkshrc":       "mksh",
+	".profile":      "posix",
+	".envrc":        "bash",
+	"PKGBUILD":      "bash",
+	"APKBUILD":      "posix",
+}
+
+func fileMatchingInfo(config configuration) dprint.FileMatchingInfo {
+	extensions := append([]string(nil), fileExtensions...)
+	for _, extension := range sortedKeys(config.FileExtensions) {
+		if !slices.Contains(extensions, extension) {
+			extensions = append(extensions, extension)
+		}
+	}
+
+	return dprint.FileMatchingInfo{
+		FileExtensions: extensions,
+		FileNames:      sortedKeys(config.FileNames),
+	}
+}
+
+// variantFromFileName looks the file name up in the fileNames option, then
+// its extension in the fileExtensions option.
+func variantFromFileName(config configuration, filePath string) (syntax.LangVariant, bool) {
+	if name, ok := config.FileNames[filepath.Base(filePath)]; ok {
+		return variantFromShellName(name)
+	}
+
+	extension := strings.TrimPrefix(strings.ToLower(filepath.Ext(filePath)), ".")
+	if name, ok := config.FileExtensions[extension]; ok && extension != "" {
+		return variantFromShellName(name)
+	}
+	return syntax.LangBash, false
+}
+
+// resolveFileNames adds the configured file names to the defaults. The spec
+// has already checked the variants, but not the names.
+func resolveFileNames(configured map[string]string, diagnostics *[]dprint.ConfigurationDiagnostic) map[string]string {
+	fileNames := make(map[string]string, len(defaultFileNames)+len(configured))
+	for name, variant := range defaultFileNames {
+		fileNames[name] = variant
+	}
+
+	for _, name := range sortedKeys(configured) {
+		if strings.Contains(name, "/") || strings.TrimPrefix(name, ".") == "" {
+			*diagnostics = append(*diagnostics, dprint.ConfigurationDiagnostic{
+				"propertyName": fileNamesKey,
+				"message":      fmt.Sprintf("Expected '%s' entry '%s' to be a file name without a directory.", fileNamesKey, name),
+			})
+			continue
+		}
+		fileNames[name] = configured[name]
+	}
+	return fileNames
+}
+
+// resolveFileExtensions normalizes the configured extensions to lowercase
+// without a leading dot.
+func resolveFileExtensions(configured map[string]string, diagnostics *[]dprint.ConfigurationDiagnostic) map[string]string {
+	extensions := map[string]string{}
+	for _, extension := range sortedKeys(configured) {
+		normalized := strings.ToLower(strings.TrimPrefix(extension, "."))
+		if normalized == "" {
+			*diagnostics = append(*diagnostics, dprint.ConfigurationDiagnostic{
+				"propertyName": fileExtensionsKey,
+				"message":      fmt.Sprintf("Expected '%s' entry '%s' to be a non-empty file extension.", fileExtensionsKey, extension),
+			})
+			continue
+		}
+		extensions[normalized] = configured[extension]
+	}
+	return extensions
+}
+
+func sortedKeys(values map[string]string) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
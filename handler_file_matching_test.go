@@ -0,0 +1,89 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/hrko/dprint-plugin-shfmt/dprint"
+	"mvdan.cc/sh/v3/syntax"
+)
+
+func TestResolveConfigFileMatching(t *testing.T) {
+	t.Parallel()
+
+	h := &handler{}
+	result := h.ResolveConfig(
+		dprint.ConfigKeyMap{
+			"fileNames": map[string]any{
+				"Jenkinsfile.sh": "bash",
+				".profile":       "bash",
+				"dir/run":        "bash",
+			},
+			"fileExtensions": map[string]any{
+				".ebuild": "bash",
+				"SH":      "posix",
+				"ksh":     "ksh",
+			},
+		},
+		dprint.GlobalConfiguration{},
+	)
+
+	if len(result.Diagnostics) != 2 {
+		t.Fatalf("expected 2 diagnostics, got %#v", result.Diagnostics)
+	}
+
+	wantExtensions := []string{"sh", "bash", "zsh", "mksh", "bats", "ebuild"}
+	if !slices.Equal(result.FileMatching.FileExtensions, wantExtensions) {
+		t.Fatalf("unexpected file extensions: %#v", result.FileMatching.FileExtensions)
+	}
+	for _, name := range []string{".bashrc", ".zshrc", ".profile", "PKGBUILD", "APKBUILD", "Jenkinsfile.sh"} {
+		if !slices.Contains(result.FileMatching.FileNames, name) {
+			t.Fatalf("expected file names to contain %q, got %#v", name, result.FileMatching.FileNames)
+		}
+	}
+	if result.Config.FileNames[".profile"] != "bash" {
+		t.Fatalf("expected configured entry to replace the preset, got %q", result.Config.FileNames[".profile"])
+	}
+	if result.Config.FileExtensions["sh"] != "posix" {
+		t.Fatalf("expected extension to be normalized, got %#v", result.Config.FileExtensions)
+	}
+}
+
+func TestDetectVariantFromFileNames(t *testing.T) {
+	t.Parallel()
+
+	h := &handler{}
+	config := h.ResolveConfig(
+		dprint.ConfigKeyMap{
+			"fileNames":      map[string]any{"build": "mksh"},
+			"fileExtensions": map[string]any{"sh": "bash", "ebuild": "bash"},
+		},
+		dprint.GlobalConfiguration{},
+	).Config
+
+	tests := []struct {
+		name        string
+		filePath    string
+		fileBytes   []byte
+		wantVariant syntax.LangVariant
+	}{
+		{name: "PKGBUILD preset", filePath: "/repo/PKGBUILD", wantVariant: syntax.LangBash},
+		{name: "profile preset", filePath: "/home/user/.profile", wantVariant: syntax.LangPOSIX},
+		{name: "zshrc preset", filePath: "/home/user/.zshrc", wantVariant: langZsh},
+		{name: "configured file name", filePath: "/repo/build", wantVariant: syntax.LangMirBSDKorn},
+		{name: "configured extension", filePath: "/repo/app-1.0.ebuild", wantVariant: syntax.LangBash},
+		{name: "configured extension replaces built-in", filePath: "/repo/run.sh", wantVariant: syntax.LangBash},
+		{name: "shebang wins over file name", filePath: "/repo/PKGBUILD", fileBytes: []byte("#!/bin/sh\n"), wantVariant: syntax.LangPOSIX},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			gotVariant, _ := detectVariant(config, tc.filePath, tc.fileBytes)
+			if gotVariant != tc.wantVariant {
+				t.Fatalf("variant mismatch: want %v, got %v", tc.wantVariant, gotVariant)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"errors"
 
 	"github.com/hrko/dprint-plugin-shfmt/dprint"
 	"mvdan.cc/sh/v3/syntax"
@@ -9,15 +10,78 @@ import (
 
 func (h *handler) Format(
 	request dprint.SyncFormatRequest[configuration],
-	_ dprint.HostFormatFunc,
+	formatWithHost dprint.HostFormatFunc,
 ) dprint.FormatResult {
+	result := h.format(request, formatWithHost)
+	if result.Code == dprint.FormatResultError {
+		_, hadBOM := cutBOM(request.FileBytes)
+		if hadBOM && request.Config.BOM != bomRemove {
+			shiftBOMColumns(result.Err)
+		}
+	}
+	return result
+}
+
+// format formats request. Diagnostic positions refer to the file without its
+// byte order mark.
+func (h *handler) format(
+	request dprint.SyncFormatRequest[configuration],
+	formatWithHost dprint.HostFormatFunc,
+) dprint.FormatResult {
+	token := cancellationToken(request)
+
+	src, hadBOM := cutBOM(request.FileBytes)
+	if ignoresFile(request.Config, src) {
+		return dprint.NoChange()
+	}
+
+	variant, variantSource := resolveVariant(request.Config, request.FilePath, src)
+	detected := variant
+	var zsh *zshProtection
+	if variant == langZsh {
+		var err error
+		zsh, err = protectZsh(src, request.FilePath)
+		if err != nil {
+			return dprint.FormatError(err)
+		}
+		src = zsh.protected
+		variant = syntax.LangBash
+	}
+
 	parser := syntax.NewParser(
-		syntax.Variant(detectVariant(request.FilePath, request.FileBytes)),
+		syntax.Variant(variant),
 		syntax.KeepComments(true),
 	)
-	prog, err := parser.Parse(bytes.NewReader(request.FileBytes), request.FilePath)
+	prog, err := parseCancellable(parser, src, request.FilePath, token)
+	if errors.Is(err, errCancelled) || token.IsCancelled() {
+		return dprint.Cancelled()
+	}
 	if err != nil {
-		return dprint.FormatError(err)
+		errs := parseErrors(variant, src, request.FilePath, err, request.Config.MaxParseErrors)
+		if zsh != nil {
+			for i, err := range errs {
+				errs[i] = zsh.remapError(err)
+			}
+			src = zsh.src
+		}
+		return dprint.FormatError(syntaxDiagnostics(errs, request.FilePath, src, detected, variantSource))
+	}
+
+	ignored, err := ignoredRanges(prog, request.Config)
+	if err != nil {
+		return dprint.FormatError(zsh.remapError(err))
+	}
+
+	if request.Config.Simplify {
+		syntax.Simplify(prog)
+	}
+
+	err = formatHeredocs(prog, request.FilePath, request.Config.HeredocLanguages, formatWithHost, token)
+	if errors.Is(err, errCancelled) {
+		return dprint.Cancelled()
+	}
+	if err != nil {
+		return dprint.FormatError(zsh.remapError(err))
 	}
 
 	printer := syntax.NewPrinter(
@@ -30,11 +94,77 @@ func (h *handler) Format(
 	)
 
 	var buffer bytes.Buffer
-	if err := printer.Print(&buffer, prog); err != nil {
+	if err := printCancellable(printer, &buffer, prog, request.Config.Minify, token); err != nil {
+		if errors.Is(err, errCancelled) {
+			return dprint.Cancelled()
+		}
 		return dprint.FormatError(err)
 	}
 
-	formatted := buffer.Bytes()
+	if token.IsCancelled() {
+		return dprint.Cancelled()
+	}
+	formatted, err := wrapLongLines(parser, printer, buffer.Bytes(), zsh.placeholders(), request.Config)
+	if err != nil {
+		return dprint.FormatError(err)
+	}
+	if token.IsCancelled() {
+		return dprint.Cancelled()
+	}
+
+	if request.Config.Verify {
+		if err := verifyFormatted(parser, prog, formatted); err != nil {
+			return dprint.FormatError(zsh.remapError(err))
+		}
+	}
+
+	if len(ignored) > 0 {
+		formatted, err = keepIgnoredRanges(parser, request.FilePath, src, ignored, formatted, request.Config)
+		if err != nil {
+			return dprint.FormatError(zsh.remapError(err))
+		}
+	}
+
+	formatted, err = applyNewLine(parser, prog, src, formatted, newLineFor(request.Config.NewLineKind, request.FileBytes))
+	if err != nil {
+		return dprint.FormatError(err)
+	}
+
+	if isPartialRange(request.Range, len(request.FileBytes)) {
+		formatRange := *request.Range
+		if hadBOM {
+			formatRange.Start = max(formatRange.Start, uint32(len(utf8BOM))) - uint32(len(utf8BOM))
+			formatRange.End = max(formatRange.End, uint32(len(utf8BOM))) - uint32(len(utf8BOM))
+		}
+		if zsh != nil {
+			formatRange.Start = uint32(zsh.protectedOffset(int(formatRange.Start)))
+			formatRange.End = uint32(zsh.protectedOffset(int(formatRange.End)))
+		}
+		formatted, err = spliceFormattedRange(parser, src, prog, formatted, formatRange)
+		if err != nil {
+			return dprint.FormatError(err)
+		}
+	}
+
+	if zsh != nil {
+		formatted, err = zsh.restore(formatted)
+		if err != nil {
+			return dprint.FormatError(err)
+		}
+	}
+
+	formatted = restoreBOM(formatted, hadBOM, request.Config.BOM)
+
+	if request.Config.CheckStability && !isPartialRange(request.Range, len(request.FileBytes)) {
+		err = h.checkStability(request, formatted, formatWithHost)
+		if errors.Is(err, errCancelled) {
+			return dprint.Cancelled()
+		}
+		if err != nil {
+			return dprint.FormatError(err)
+		}
+	}
+
 	if bytes.Equal(request.FileBytes, formatted) {
 		return dprint.NoChange()
 	}
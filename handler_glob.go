@@ -0,0 +1,65 @@
+package main
+
+import (
+	"path"
+	"strings"
+)
+
+// matchFileGlob reports whether filePath matches pattern. Patterns use
+// path.Match syntax per segment, with "**" matching any number of segments.
+//
+// The host passes absolute paths while patterns are written relative to the
+// project, so a pattern without a slash matches the file name and any other
+// relative pattern matches the trailing segments of the path.
+func matchFileGlob(pattern string, filePath string) bool {
+	pattern = strings.TrimPrefix(pattern, "./")
+	if !strings.Contains(pattern, "/") {
+		matched, _ := path.Match(pattern, path.Base(filePath))
+		return matched
+	}
+
+	patternSegments := strings.Split(strings.TrimPrefix(pattern, "/"), "/")
+	pathSegments := strings.Split(strings.TrimPrefix(filePath, "/"), "/")
+	if strings.HasPrefix(pattern, "/") {
+		return matchGlobSegments(patternSegments, pathSegments)
+	}
+
+	for start := range pathSegments {
+		if matchGlobSegments(patternSegments, pathSegments[start:]) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchGlobSegments(pattern []string, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for skip := 0; skip <= len(segments); skip++ {
+				if matchGlobSegments(pattern[1:], segments[skip:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(segments) == 0 {
+			return false
+		}
+		if matched, _ := path.Match(pattern[0], segments[0]); !matched {
+			return false
+		}
+		pattern, segments = pattern[1:], segments[1:]
+	}
+	return len(segments) == 0
+}
+
+// validFileGlob reports whether every segment of pattern is well formed.
+func validFileGlob(pattern string) bool {
+	for _, segment := range strings.Split(pattern, "/") {
+		if _, err := path.Match(segment, ""); err != nil {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/hrko/dprint-plugin-shfmt/dprint"
+	"mvdan.cc/sh/v3/syntax"
+)
+
+const heredocLanguagesKey = "heredocLanguages"
+
+// resolveHeredocLanguages checks the configured delimiter patterns and
+// normalizes the extensions they map to.
+func resolveHeredocLanguages(configured map[string]string, diagnostics *[]dprint.ConfigurationDiagnostic) map[string]string {
+	languages := map[string]string{}
+
+	for _, delimiter := range sortedKeys(configured) {
+		if _, err := path.Match(delimiter, ""); err != nil {
+			*diagnostics = append(*diagnostics, dprint.ConfigurationDiagnostic{
+				"propertyName": heredocLanguagesKey,
+				"message":      fmt.Sprintf("Invalid heredoc delimiter pattern '%s' in '%s'.", delimiter, heredocLanguagesKey),
+			})
+			continue
+		}
+
+		extension := strings.TrimPrefix(strings.TrimSpace(configured[delimiter]), ".")
+		if extension == "" {
+			*diagnostics = append(*diagnostics, dprint.ConfigurationDiagnostic{
+				"propertyName": heredocLanguagesKey,
+				"message": fmt.Sprintf(
+					"Expected '%s' entry '%s' to be a non-empty file extension, but got %#v.",
+					heredocLanguagesKey,
+					delimiter,
+					configured[delimiter],
+				),
+			})
+			continue
+		}
+
+		languages[delimiter] = extension
+	}
+
+	return languages
+}
+
+// formatHeredocs formats the bodies of quoted heredocs whose delimiter is
+// mapped to a file extension, using the plugin registered with the host for
+// that extension. Bodies are replaced in place within prog.
+//
+// Only quoted delimiters such as <<'JSON' or <<"JSON" qualify, since their
+// bodies are taken literally and contain no expansions to preserve.
+func formatHeredocs(
+	prog *syntax.File,
+	filePath string,
+	languages map[string]string,
+	formatWithHost dprint.HostFormatFunc,
+	token dprint.CancellationToken,
+) error {
+	if len(languages) == 0 || formatWithHost == nil {
+		return nil
+	}
+
+	patterns := make([]string, 0, len(languages))
+	for pattern := range languages {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+
+	var redirects []*syntax.Redirect
+	syntax.Walk(prog, func(node syntax.Node) bool {
+		if redirect, ok := node.(*syntax.Redirect); ok && redirect.Hdoc != nil {
+			redirects = append(redirects, redirect)
+		}
+		return true
+	})
+
+	for _, redirect := range redirects {
+		if token.IsCancelled() {
+			return errCancelled
+		}
+
+		delimiter, quoted := heredocDelimiter(redirect.Word)
+		if !quoted {
+			continue
+		}
+		extension, ok := heredocExtension(languages, patterns, delimiter)
+		if !ok {
+			continue
+		}
+		if len(redirect.Hdoc.Parts) != 1 {
+			continue
+		}
+		body, ok := redirect.Hdoc.Parts[0].(*syntax.Lit)
+		if !ok {
+			continue
+		}
+
+		formatted, changed, err := formatHeredocBody(
+			body.Value,
+			delimiter,
+			redirect.Op == syntax.DashHdoc,
+			fmt.Sprintf("%s.%s", filePath, extension),
+			formatWithHost,
+		)
+		if errors.Is(err, errCancelled) {
+			return err
+		}
+		if err != nil {
+			return nodeDiagnostic(
+				filePath,
+				redirect.Hdoc,
+				codeHeredocFormatFailed,
+				"failed to format heredoc '%s' as '%s': %v",
+				delimiter,
+				extension,
+				err,
+			)
+		}
+		if changed {
+			body.Value = formatted
+		}
+	}
+
+	return nil
+}
+
+// heredocDelimiter returns the delimiter with its quotes removed, and whether
+// it was quoted at all.
+func heredocDelimiter(word *syntax.Word) (string, bool) {
+	var delimiter strings.Builder
+	quoted := false
+
+	for _, part := range word.Parts {
+		switch part := part.(type) {
+		case *syntax.Lit:
+			if strings.Contains(part.Value, `\`) {
+				quoted = true
+			}
+			delimiter.WriteString(strings.ReplaceAll(part.Value, `\`, ""))
+		case *syntax.SglQuoted:
+			quoted = true
+			delimiter.WriteString(part.Value)
+		case *syntax.DblQuoted:
+			quoted = true
+			for _, inner := range part.Parts {
+				lit, ok := inner.(*syntax.Lit)
+				if !ok {
+					return "", false
+				}
+				delimiter.WriteString(lit.Value)
+			}
+		default:
+			return "", false
+		}
+	}
+
+	return delimiter.String(), quoted
+}
+
+// heredocExtension looks up the extension for delimiter. An exact key wins
+// over glob patterns, which are tried in lexical order.
+func heredocExtension(languages map[string]string, patterns []string, delimiter string) (string, bool) {
+	if extension, ok := languages[delimiter]; ok {
+		return extension, true
+	}
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, delimiter); matched {
+			return languages[pattern], true
+		}
+	}
+	return "", false
+}
+
+// formatHeredocBody formats a heredoc body without its shared indentation and
+// puts the indentation back afterwards. For <<- heredocs all leading tabs are
+// stripped before formatting, matching what the shell passes to the command.
+// Output containing a line the shell would take as the closing delimiter is
+// rejected, since the rest of it would run as commands. When the host cancels
+// the request, errCancelled is returned.
+func formatHeredocBody(
+	value string,
+	delimiter string,
+	dash bool,
+	virtualPath string,
+	formatWithHost dprint.HostFormatFunc,
+) (string, bool, error) {
+	// The body of a <<- heredoc ends with the tabs preceding the closing
+	// delimiter, which are kept as they are.
+	content, trailer := value, ""
+	if lastNewline := strings.LastIndexByte(value, '\n'); lastNewline >= 0 {
+		content, trailer = value[:lastNewline+1], value[lastNewline+1:]
+	}
+
+	lines := strings.SplitAfter(content, "\n")
+	indent := commonIndent(lines, dash)
+	var stripped strings.Builder
+	for _, line := range lines {
+		if dash {
+			stripped.WriteString(strings.TrimLeft(line, "\t"))
+		} else {
+			stripped.WriteString(strings.TrimPrefix(line, indent))
+		}
+	}
+
+	result := formatWithHost(dprint.SyncHostFormatRequest{
+		FilePath:  virtualPath,
+		FileBytes: []byte(stripped.String()),
+	})
+	switch result.Code {
+	case dprint.FormatResultChange:
+	case dprint.FormatResultError:
+		return "", false, result.Err
+	case dprint.FormatResultCancelled:
+		return "", false, errCancelled
+	default:
+		return "", false, nil
+	}
+
+	formatted := string(result.Text)
+	if formatted != "" && !strings.HasSuffix(formatted, "\n") {
+		formatted += "\n"
+	}
+
+	var reindented strings.Builder
+	for _, line := range strings.SplitAfter(formatted, "\n") {
+		if strings.TrimSpace(line) != "" {
+			reindented.WriteString(indent)
+		}
+		reindented.WriteString(line)
+	}
+
+	for _, line := range strings.Split(reindented.String(), "\n") {
+		if dash {
+			line = strings.TrimLeft(line, "\t")
+		}
+		if line == delimiter {
+			return "", false, fmt.Errorf("the formatted body contains the closing delimiter '%s' on a line of its own", delimiter)
+		}
+	}
+	reindented.WriteString(trailer)
+
+	return reindented.String(), true, nil
+}
+
+// commonIndent returns the leading whitespace shared by all non-blank lines.
+// Only tabs are considered for <<- heredocs.
+func commonIndent(lines []string, dash bool) string {
+	indent, found := "", false
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		cutset := " \t"
+		if dash {
+			cutset = "\t"
+		}
+		lineIndent := line[:len(line)-len(strings.TrimLeft(line, cutset))]
+		if !found {
+			indent, found = lineIndent, true
+			continue
+		}
+
+		common := 0
+		for common < len(indent) && common < len(lineIndent) && indent[common] == lineIndent[common] {
+			common++
+		}
+		indent = indent[:common]
+	}
+	return indent
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/hrko/dprint-plugin-shfmt/dprint"
+)
+
+type fakeHost struct {
+	requests []dprint.SyncHostFormatRequest
+	err      error
+}
+
+func (h *fakeHost) format(request dprint.SyncHostFormatRequest) dprint.FormatResult {
+	h.requests = append(h.requests, request)
+	if h.err != nil {
+		return dprint.FormatError(h.err)
+	}
+
+	formatted := strings.ToUpper(string(request.FileBytes))
+	if formatted == string(request.FileBytes) {
+		return dprint.NoChange()
+	}
+	return dprint.Change([]byte(formatted))
+}
+
+func TestFormatHeredocsThroughHost(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		input     string
+		languages map[string]string
+		want      string
+		wantPaths []string
+	}{
+		{
+			name:      "single-quoted delimiter",
+			input:     "cat <<'JSON'\n{\"a\": 1}\nJSON\n",
+			languages: map[string]string{"JSON": "json"},
+			want:      "cat <<'JSON'\n{\"A\": 1}\nJSON\n",
+			wantPaths: []string{"sample.sh.json"},
+		},
+		{
+			name:      "double-quoted delimiter matching a pattern",
+			input:     "cat <<\"EOF_YAML\"\na: b\nEOF_YAML\n",
+			languages: map[string]string{"*_YAML": "yaml", "JSON": "json"},
+			want:      "cat <<\"EOF_YAML\"\nA: B\nEOF_YAML\n",
+			wantPaths: []string{"sample.sh.yaml"},
+		},
+		{
+			name:      "escaped delimiter",
+			input:     "psql <<\\SQL\nselect 1;\nSQL\n",
+			languages: map[string]string{"SQL": "sql"},
+			want:      "psql <<\\SQL\nSELECT 1;\nSQL\n",
+			wantPaths: []string{"sample.sh.sql"},
+		},
+		{
+			name:      "unquoted delimiter is left alone",
+			input:     "cat <<JSON\n{\"a\": \"$b\"}\nJSON\n",
+			languages: map[string]string{"JSON": "json"},
+			want:      "cat <<JSON\n{\"a\": \"$b\"}\nJSON\n",
+		},
+		{
+			name:      "unmapped delimiter is left alone",
+			input:     "cat <<'EOF'\nabc\nEOF\n",
+			languages: map[string]string{"JSON": "json"},
+			want:      "cat <<'EOF'\nabc\nEOF\n",
+		},
+		{
+			name:      "shared indentation is kept",
+			input:     "cat <<'SQL'\n  select\n    1;\nSQL\n",
+			languages: map[string]string{"SQL": "sql"},
+			want:      "cat <<'SQL'\n  SELECT\n    1;\nSQL\n",
+			wantPaths: []string{"sample.sh.sql"},
+		},
+		{
+			name:      "tabs stripped from dash heredoc",
+			input:     "cat <<-'SQL'\n\t\tselect\n\t\t  1;\n\tSQL\n",
+			languages: map[string]string{"SQL": "sql"},
+			want:      "cat <<-'SQL'\n\t\tSELECT\n\t\t  1;\n\tSQL\n",
+			wantPaths: []string{"sample.sh.sql"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			host := &fakeHost{}
+			h := &handler{}
+			result := h.Format(
+				dprint.SyncFormatRequest[configuration]{
+					FilePath:  "sample.sh",
+					FileBytes: []byte(tc.input),
+					Config: configuration{
+						IndentWidth:      2,
+						HeredocLanguages: tc.languages,
+					},
+				},
+				host.format,
+			)
+
+			if result.Code == dprint.FormatResultError {
+				t.Fatalf("unexpected error: %v", result.Err)
+			}
+			got := tc.input
+			if result.Code == dprint.FormatResultChange {
+				got = string(result.Text)
+			}
+			if got != tc.want {
+				t.Fatalf("unexpected output:\nwant %q\ngot  %q", tc.want, got)
+			}
+
+			if len(host.requests) != len(tc.wantPaths) {
+				t.Fatalf("expected %d host requests, got %d", len(tc.wantPaths), len(host.requests))
+			}
+			for i, request := range host.requests {
+				if request.FilePath != tc.wantPaths[i] {
+					t.Fatalf("expected host request for %q, got %q", tc.wantPaths[i], request.FilePath)
+				}
+			}
+		})
+	}
+}
+
+func TestFormatHeredocsReportsHostErrors(t *testing.T) {
+	t.Parallel()
+
+	h := &handler{}
+	result := h.Format(
+		dprint.SyncFormatRequest[configuration]{
+			FilePath:  "sample.sh",
+			FileBytes: []byte("cat <<'JSON'\n{\nJSON\n"),
+			Config: configuration{
+				IndentWidth:      2,
+				HeredocLanguages: map[string]string{"JSON": "json"},
+			},
+		},
+		(&fakeHost{err: errors.New("unexpected end of input")}).format,
+	)
+
+	if result.Code != dprint.FormatResultError {
+		t.Fatalf("expected error result, got %d", result.Code)
+	}
+	want := "sample.sh:2:1: failed to format heredoc 'JSON' as 'json': unexpected end of input [heredoc-format-failed]"
+	if result.Err == nil || result.Err.Error() != want {
+		t.Fatalf("unexpected error: %v", result.Err)
+	}
+}
+
+func TestFormatHeredocsStopsWhenHostCancels(t *testing.T) {
+	t.Parallel()
+
+	h := &handler{}
+	result := h.Format(
+		dprint.SyncFormatRequest[configuration]{
+			FilePath:  "sample.sh",
+			FileBytes: []byte("cat <<'JSON'\n{}\nJSON\n"),
+			Config: configuration{
+				IndentWidth:      2,
+				HeredocLanguages: map[string]string{"JSON": "json"},
+			},
+		},
+		func(dprint.SyncHostFormatRequest) dprint.FormatResult {
+			return dprint.Cancelled()
+		},
+	)
+
+	if result.Code != dprint.FormatResultCancelled {
+		t.Fatalf("expected cancelled result, got %d: %v", result.Code, result.Err)
+	}
+}
+
+func TestFormatHeredocsRejectsBodiesEndingTheHeredoc(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		input    string
+		response string
+		want     string
+	}{
+		{
+			name:     "delimiter line",
+			input:    "cat <<'EOF'\nhello\nEOF\n",
+			response: "hello\nEOF\nrm -rf /tmp/x\n",
+			want:     "sample.sh:2:1: failed to format heredoc 'EOF' as 'txt': the formatted body contains the closing delimiter 'EOF' on a line of its own [heredoc-format-failed]",
+		},
+		{
+			name:     "delimiter line after tabs in a dash heredoc",
+			input:    "cat <<-'EOF'\n\thello\n\tEOF\n",
+			response: "hello\n\t\tEOF\nrm -rf /tmp/x\n",
+			want:     "sample.sh:2:1: failed to format heredoc 'EOF' as 'txt': the formatted body contains the closing delimiter 'EOF' on a line of its own [heredoc-format-failed]",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			h := &handler{}
+			result := h.Format(
+				dprint.SyncFormatRequest[configuration]{
+					FilePath:  "sample.sh",
+					FileBytes: []byte(tc.input),
+					Config: configuration{
+						IndentWidth:      2,
+						HeredocLanguages: map[string]string{"EOF": "txt"},
+					},
+				},
+				func(dprint.SyncHostFormatRequest) dprint.FormatResult {
+					return dprint.Change([]byte(tc.response))
+				},
+			)
+			if result.Code != dprint.FormatResultError {
+				t.Fatalf("expected error result, got %d (text: %q)", result.Code, result.Text)
+			}
+			if result.Err.Error() != tc.want {
+				t.Fatalf("unexpected error:\nwant %s\ngot  %s", tc.want, result.Err)
+			}
+		})
+	}
+}
+
+func TestResolveHeredocLanguages(t *testing.T) {
+	t.Parallel()
+
+	h := &handler{}
+	result := h.ResolveConfig(
+		dprint.ConfigKeyMap{
+			"heredocLanguages": map[string]any{
+				"JSON":    ".json",
+				"*_YAML":  "yaml",
+				"[":       "txt",
+				"NUMERIC": int64(1),
+			},
+		},
+		dprint.GlobalConfiguration{},
+	)
+
+	want := map[string]string{"JSON": "json", "*_YAML": "yaml"}
+	if len(result.Config.HeredocLanguages) != len(want) {
+		t.Fatalf("unexpected languages: %#v", result.Config.HeredocLanguages)
+	}
+	for delimiter, extension := range want {
+		if result.Config.HeredocLanguages[delimiter] != extension {
+			t.Fatalf("expected %q to map to %q, got %#v", delimiter, extension, result.Config.HeredocLanguages)
+		}
+	}
+	if len(result.Diagnostics) != 2 {
+		t.Fatalf("expected 2 diagnostics, got %#v", result.Diagnostics)
+	}
+
+	result = h.ResolveConfig(
+		dprint.ConfigKeyMap{"heredocLanguages": "json"},
+		dprint.GlobalConfiguration{},
+	)
+	if len(result.Diagnostics) != 1 || result.Diagnostics[0]["propertyName"] != "heredocLanguages" {
+		t.Fatalf("expected type diagnostic, got %#v", result.Diagnostics)
+	}
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// ignoredRange is source kept verbatim, identified by the offset of the
+// comment that asked for it so that it can be found again in the output.
+type ignoredRange struct {
+	directive uint
+	span      lineSpan
+}
+
+// trimIgnoreDirectives drops the whitespace around the configured directives,
+// which never takes part in matching a comment.
+func trimIgnoreDirectives(config *configuration) {
+	config.IgnoreDirective = strings.TrimSpace(config.IgnoreDirective)
+	config.IgnoreStartDirective = strings.TrimSpace(config.IgnoreStartDirective)
+	config.IgnoreEndDirective = strings.TrimSpace(config.IgnoreEndDirective)
+	config.IgnoreFileDirective = strings.TrimSpace(config.IgnoreFileDirective)
+}
+
+// isDirective reports whether a comment consists of directive, optionally
+// followed by an explanation.
+func isDirective(text string, directive string) bool {
+	if directive == "" {
+		return false
+	}
+	rest, ok := strings.CutPrefix(strings.TrimSpace(text), directive)
+	return ok && (rest == "" || rest[0] == ' ' || rest[0] == '\t')
+}
+
+// ignoresFile reports whether the leading comment block of the file carries
+// the ignore-file directive.
+func ignoresFile(config configuration, fileBytes []byte) bool {
+	for _, comment := range leadingComments(fileBytes) {
+		if isDirective(comment, config.IgnoreFileDirective) {
+			return true
+		}
+	}
+	return false
+}
+
+// ignoredRanges returns the statements following an ignore directive and the
+// regions between start and end directives, ordered by directive position.
+// Unmatched start and end directives are reported as diagnostics.
+func ignoredRanges(prog *syntax.File, config configuration) ([]ignoredRange, error) {
+	var ranges []ignoredRange
+	var comments []*syntax.Comment
+	addLast := func(last []syntax.Comment) {
+		for i := range last {
+			comments = append(comments, &last[i])
+		}
+	}
+
+	// The comments before a closing fi, done, esac, } or ) are kept in the
+	// Last fields of the clause, which syntax.Walk does not visit for every
+	// node, so they are read from there and duplicates dropped below.
+	syntax.Walk(prog, func(node syntax.Node) bool {
+		switch node := node.(type) {
+		case *syntax.IfClause:
+			addLast(node.Last)
+		case *syntax.WhileClause:
+			addLast(node.DoLast)
+		case *syntax.ForClause:
+			addLast(node.DoLast)
+		case *syntax.CaseClause:
+			addLast(node.Last)
+		case *syntax.Block:
+			addLast(node.Last)
+		case *syntax.Subshell:
+			addLast(node.Last)
+		case *syntax.Stmt:
+			for i := range node.Comments {
+				comment := &node.Comments[i]
+				if comment.Pos().Line() < node.Pos().Line() && isDirective(comment.Text, config.IgnoreDirective) {
+					ranges = append(ranges, ignoredRange{
+						directive: comment.Pos().Offset(),
+						span:      lineSpan{start: node.Pos().Line(), end: stmtLineSpan(node).end},
+					})
+				}
+			}
+		case *syntax.Comment:
+			comments = append(comments, node)
+		}
+		return true
+	})
+
+	sort.Slice(comments, func(i, j int) bool {
+		return comments[i].Pos().Offset() < comments[j].Pos().Offset()
+	})
+	comments = slices.CompactFunc(comments, func(a, b *syntax.Comment) bool {
+		return a.Pos() == b.Pos()
+	})
+	var open *syntax.Comment
+	for _, comment := range comments {
+		switch {
+		case isDirective(comment.Text, config.IgnoreStartDirective):
+			if open != nil {
+				return nil, nodeDiagnostic(
+					prog.Name,
+					comment,
+					codeUnmatchedIgnore,
+					"'%s' is already inside an ignored region started at %s",
+					config.IgnoreStartDirective,
+					open.Pos(),
+				)
+			}
+			open = comment
+		case isDirective(comment.Text, config.IgnoreEndDirective):
+			if open == nil {
+				return nil, nodeDiagnostic(
+					prog.Name,
+					comment,
+					codeUnmatchedIgnore,
+					"'%s' has no matching '%s'",
+					config.IgnoreEndDirective,
+					config.IgnoreStartDirective,
+				)
+			}
+			ranges = append(ranges, ignoredRange{
+				directive: open.Pos().Offset(),
+				span:      lineSpan{start: open.Pos().Line(), end: comment.Pos().Line()},
+			})
+			open = nil
+		}
+	}
+	if open != nil {
+		return nil, nodeDiagnostic(
+			prog.Name,
+			open,
+			codeUnmatchedIgnore,
+			"'%s' has no matching '%s'",
+			config.IgnoreStartDirective,
+			config.IgnoreEndDirective,
+		)
+	}
+
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i].directive < ranges[j].directive
+	})
+	return ranges, nil
+}
+
+// keepIgnoredRanges puts the source lines of every ignored range back into
+// formatted, in place of their printed counterparts.
+func keepIgnoredRanges(
+	parser *syntax.Parser,
+	filePath string,
+	src []byte,
+	srcRanges []ignoredRange,
+	formatted []byte,
+	config configuration,
+) ([]byte, error) {
+	formattedProg, err := parser.Parse(bytes.NewReader(formatted), "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse formatted output to keep ignored ranges: %w", err)
+	}
+	formattedRanges, err := ignoredRanges(formattedProg, config)
+	if err != nil {
+		// Positions in the formatted output mean nothing to the user, so the
+		// diagnostic is only kept as text.
+		return nil, fmt.Errorf("failed to find ignored ranges in formatted output: %v", err)
+	}
+	if len(formattedRanges) != len(srcRanges) {
+		directive := int(srcRanges[0].directive)
+		return nil, diagnosticAt(
+			filePath,
+			src,
+			directive,
+			directive,
+			codeIgnoredRangesConflict,
+			"cannot keep ignored ranges: the formatted output has %d where the input has %d; ignore comments are dropped when minifying",
+			len(formattedRanges),
+			len(srcRanges),
+		)
+	}
+
+	// Ranges nested in another one are covered by it. The rest must not
+	// overlap, so that each can be replaced on its own.
+	var outermost []int
+	for i, srcRange := range srcRanges {
+		nested := false
+		for j, other := range srcRanges {
+			if j != i && other.span.start <= srcRange.span.start && srcRange.span.end <= other.span.end &&
+				(other.span != srcRange.span || j < i) {
+				nested = true
+				break
+			}
+		}
+		if nested {
+			continue
+		}
+		if len(outermost) > 0 && srcRange.span.start <= srcRanges[outermost[len(outermost)-1]].span.end {
+			directive := int(srcRange.directive)
+			return nil, diagnosticAt(
+				filePath,
+				src,
+				directive,
+				directive,
+				codeIgnoredRangesConflict,
+				"ignored ranges overlapping at line %d must be nested",
+				srcRange.span.start,
+			)
+		}
+		outermost = append(outermost, i)
+	}
+
+	// Replace from the end, so that the offsets of earlier lines stay valid.
+	srcLines, formattedLines := lineOffsets(src), lineOffsets(formatted)
+	for k := len(outermost) - 1; k >= 0; k-- {
+		i := outermost[k]
+		formatted = replaceLines(formatted, formattedLines, formattedRanges[i].span, src, srcLines, srcRanges[i].span)
+	}
+	return formatted, nil
+}
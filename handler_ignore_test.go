@@ -0,0 +1,209 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/hrko/dprint-plugin-shfmt/dprint"
+)
+
+func TestFormatKeepsIgnoredSource(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		input  string
+		want   string
+		config func(*configuration)
+	}{
+		{
+			name:  "next statement",
+			input: "echo   a\n# dprint-ignore\nprintf '%-10s %s\\n'   name   value\necho   b\n",
+			want:  "echo a\n# dprint-ignore\nprintf '%-10s %s\\n'   name   value\necho b\n",
+		},
+		{
+			name:  "statement with explanation and heredoc",
+			input: "# dprint-ignore keep the table aligned\ncat  <<EOF\n  a   b\nEOF\necho   c\n",
+			want:  "# dprint-ignore keep the table aligned\ncat  <<EOF\n  a   b\nEOF\necho c\n",
+		},
+		{
+			name:  "statement within function",
+			input: "f(){\n# dprint-ignore\nif true;then echo   a;fi\necho   b\n}\n",
+			want:  "f() {\n  # dprint-ignore\nif true;then echo   a;fi\n  echo b\n}\n",
+		},
+		{
+			name:  "region of case arms",
+			input: "case $1 in\n# dprint-ignore-start\n  start)   run   ;;\n  stop)    halt  ;;\n# dprint-ignore-end\n*)  usage;;\nesac\n",
+			want:  "case $1 in\n# dprint-ignore-start\n  start)   run   ;;\n  stop)    halt  ;;\n# dprint-ignore-end\n*) usage ;;\nesac\n",
+		},
+		{
+			name:  "region ending before fi",
+			input: "if true;then\n# dprint-ignore-start\n  echo   a\n# dprint-ignore-end\nfi\n",
+			want:  "if true; then\n# dprint-ignore-start\n  echo   a\n# dprint-ignore-end\nfi\n",
+		},
+		{
+			name:  "region ending before done of while",
+			input: "while true;do\n# dprint-ignore-start\n  echo   a\n# dprint-ignore-end\ndone\n",
+			want:  "while true; do\n# dprint-ignore-start\n  echo   a\n# dprint-ignore-end\ndone\n",
+		},
+		{
+			name:  "region ending before done of for",
+			input: "for x in a;do\n# dprint-ignore-start\n  echo   a\n# dprint-ignore-end\ndone\n",
+			want:  "for x in a; do\n# dprint-ignore-start\n  echo   a\n# dprint-ignore-end\ndone\n",
+		},
+		{
+			name:  "region ending before esac",
+			input: "case $1 in\n*)  usage;;\n# dprint-ignore-start\n  start)   run   ;;\n# dprint-ignore-end\nesac\n",
+			want:  "case $1 in\n*) usage ;;\n# dprint-ignore-start\n  start)   run   ;;\n# dprint-ignore-end\nesac\n",
+		},
+		{
+			name:  "region ending before closing brace",
+			input: "{\necho   b\n# dprint-ignore-start\n  echo   a\n# dprint-ignore-end\n}\n",
+			want:  "{\n  echo b\n# dprint-ignore-start\n  echo   a\n# dprint-ignore-end\n}\n",
+		},
+		{
+			name:  "region ending before closing parenthesis",
+			input: "(\necho   b\n# dprint-ignore-start\n  echo   a\n# dprint-ignore-end\n)\n",
+			want:  "(\n  echo b\n# dprint-ignore-start\n  echo   a\n# dprint-ignore-end\n)\n",
+		},
+		{
+			name:  "statement ignore nested in region",
+			input: "# dprint-ignore-start\necho   a\n# dprint-ignore\necho   b\n# dprint-ignore-end\necho   c\n",
+			want:  "# dprint-ignore-start\necho   a\n# dprint-ignore\necho   b\n# dprint-ignore-end\necho c\n",
+		},
+		{
+			name:  "whole file",
+			input: "#!/bin/sh\n# dprint-ignore-file\necho   a\n",
+			want:  "#!/bin/sh\n# dprint-ignore-file\necho   a\n",
+		},
+		{
+			name:  "ignore-file directive after code has no effect",
+			input: "echo   a\n# dprint-ignore-file\n",
+			want:  "echo a\n# dprint-ignore-file\n",
+		},
+		{
+			name:  "custom markers",
+			input: "# fmt: off\necho   a\n# fmt: on\n# keep\necho   b\necho   c\n",
+			want:  "# fmt: off\necho   a\n# fmt: on\n# keep\necho   b\necho c\n",
+			config: func(config *configuration) {
+				config.IgnoreDirective = "keep"
+				config.IgnoreStartDirective = "fmt: off"
+				config.IgnoreEndDirective = "fmt: on"
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			config := defaultIgnoreConfigForTest()
+			if tc.config != nil {
+				tc.config(&config)
+			}
+
+			h := &handler{}
+			result := h.Format(
+				dprint.SyncFormatRequest[configuration]{
+					FilePath:  "sample.bash",
+					FileBytes: []byte(tc.input),
+					Config:    config,
+				},
+				nil,
+			)
+			if result.Code == dprint.FormatResultError {
+				t.Fatalf("unexpected error: %v", result.Err)
+			}
+			got := tc.input
+			if result.Code == dprint.FormatResultChange {
+				got = string(result.Text)
+			}
+			if got != tc.want {
+				t.Fatalf("unexpected output:\nwant %q\ngot  %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestFormatReportsInvalidIgnoreRegions(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		input  string
+		minify bool
+		want   string
+	}{
+		{
+			name:  "unterminated region",
+			input: "echo a\n# dprint-ignore-start\necho b\n",
+			want:  "sample.bash:2:1: 'dprint-ignore-start' has no matching 'dprint-ignore-end' [unmatched-ignore-directive]",
+		},
+		{
+			name:  "end without start",
+			input: "echo a\n# dprint-ignore-end\n",
+			want:  "sample.bash:2:1: 'dprint-ignore-end' has no matching 'dprint-ignore-start' [unmatched-ignore-directive]",
+		},
+		{
+			name:   "minify drops the comments",
+			input:  "# dprint-ignore\necho   a\n",
+			minify: true,
+			want:   "sample.bash:1:1: cannot keep ignored ranges: the formatted output has 0 where the input has 1; ignore comments are dropped when minifying [ignored-ranges-conflict]",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			config := defaultIgnoreConfigForTest()
+			config.Minify = tc.minify
+
+			h := &handler{}
+			result := h.Format(
+				dprint.SyncFormatRequest[configuration]{
+					FilePath:  "sample.bash",
+					FileBytes: []byte(tc.input),
+					Config:    config,
+				},
+				nil,
+			)
+			if result.Code != dprint.FormatResultError {
+				t.Fatalf("expected error result, got %d", result.Code)
+			}
+			if result.Err.Error() != tc.want {
+				t.Fatalf("unexpected error:\nwant %s\ngot  %s", tc.want, result.Err)
+			}
+		})
+	}
+}
+
+func TestResolveIgnoreDirectives(t *testing.T) {
+	t.Parallel()
+
+	h := &handler{}
+	result := h.ResolveConfig(
+		dprint.ConfigKeyMap{
+			"ignoreDirective":    "  keep  ",
+			"ignoreEndDirective": "",
+		},
+		dprint.GlobalConfiguration{},
+	)
+
+	if result.Config.IgnoreDirective != "keep" {
+		t.Fatalf("unexpected ignore directive: %q", result.Config.IgnoreDirective)
+	}
+	if result.Config.IgnoreStartDirective != "dprint-ignore-start" || result.Config.IgnoreEndDirective != "dprint-ignore-end" {
+		t.Fatalf("unexpected region directives: %q, %q", result.Config.IgnoreStartDirective, result.Config.IgnoreEndDirective)
+	}
+	if result.Config.IgnoreFileDirective != "dprint-ignore-file" {
+		t.Fatalf("unexpected file directive: %q", result.Config.IgnoreFileDirective)
+	}
+	if len(result.Diagnostics) != 1 || result.Diagnostics[0]["propertyName"] != "ignoreEndDirective" {
+		t.Fatalf("unexpected diagnostics: %#v", result.Diagnostics)
+	}
+}
+
+func defaultIgnoreConfigForTest() configuration {
+	h := &handler{}
+	return h.ResolveConfig(dprint.ConfigKeyMap{}, dprint.GlobalConfiguration{}).Config
+}
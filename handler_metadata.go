@@ -40,8 +40,8 @@ func (h *handler) LicenseText() string {
 	return embeddedLicenseText
 }
 
-func (h *handler) CheckConfigUpdates(_ dprint.CheckConfigUpdatesMessage) ([]dprint.ConfigChange, error) {
-	return []dprint.ConfigChange{}, nil
+func (h *handler) CheckConfigUpdates(message dprint.CheckConfigUpdatesMessage) ([]dprint.ConfigChange, error) {
+	return dprint.ConfigAliasChanges(message.Config, configurationAliases), nil
 }
 
 func configSchemaURLForTag(tag string) string {
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	goruntime "runtime"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+const (
+	newLineKindAuto   = "auto"
+	newLineKindLF     = "lf"
+	newLineKindCRLF   = "crlf"
+	newLineKindSystem = "system"
+)
+
+// newLineFor returns the line ending the output should use. auto picks the
+// ending most lines of fileBytes use, preferring \n on a tie.
+func newLineFor(kind string, fileBytes []byte) string {
+	switch kind {
+	case newLineKindCRLF:
+		return "\r\n"
+	case newLineKindSystem:
+		if goruntime.GOOS == "windows" {
+			return "\r\n"
+		}
+		return "\n"
+	case newLineKindAuto:
+		crlf := bytes.Count(fileBytes, []byte("\r\n"))
+		if crlf > bytes.Count(fileBytes, []byte("\n"))-crlf {
+			return "\r\n"
+		}
+		return "\n"
+	default:
+		return "\n"
+	}
+}
+
+// applyNewLine ends every line of formatted with newLine, except the lines of
+// heredoc bodies and the lines ending inside multi-line quoted strings, which
+// keep the endings they had in src, since changing them would change the data
+// the shell sees. The parser drops carriage returns, so these lines are matched
+// with their counterparts in prog.
+func applyNewLine(
+	parser *syntax.Parser,
+	prog *syntax.File,
+	src []byte,
+	formatted []byte,
+	newLine string,
+) ([]byte, error) {
+	if newLine == "\n" && bytes.IndexByte(formatted, '\r') == -1 && !verbatimLinesUseCRLF(prog, src) {
+		return formatted, nil
+	}
+
+	formattedProg, err := parser.Parse(bytes.NewReader(formatted), "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse formatted output to apply line endings: %w", err)
+	}
+	srcBodies, formattedBodies := heredocBodies(prog), heredocBodies(formattedProg)
+	if len(srcBodies) != len(formattedBodies) {
+		return nil, fmt.Errorf("formatted output has %d heredocs where the input has %d", len(formattedBodies), len(srcBodies))
+	}
+	srcQuotes, formattedQuotes := quotedLines(prog), quotedLines(formattedProg)
+	if len(srcQuotes) != len(formattedQuotes) {
+		return nil, fmt.Errorf("formatted output has %d multi-line strings where the input has %d", len(formattedQuotes), len(srcQuotes))
+	}
+
+	// Map each formatted body line to the ending of the source line at the
+	// same position. A body reformatted through the host may have more lines
+	// than in src, so those take the ending of the last source line. Quoted
+	// strings are printed as they are, so their lines match one to one.
+	srcLines := lineOffsets(src)
+	keptEndings := map[uint]string{}
+	keep := func(srcSpans []lineSpan, formattedSpans []lineSpan) {
+		for i, srcSpan := range srcSpans {
+			formattedSpan := formattedSpans[i]
+			for line := formattedSpan.start; line <= formattedSpan.end; line++ {
+				srcLine := min(srcSpan.start+(line-formattedSpan.start), srcSpan.end)
+				keptEndings[line] = lineEnding(src, srcLines, srcLine)
+			}
+		}
+	}
+	keep(srcBodies, formattedBodies)
+	keep(srcQuotes, formattedQuotes)
+
+	result := make([]byte, 0, len(formatted)+bytes.Count(formatted, []byte("\n")))
+	var line uint
+	for len(formatted) > 0 {
+		line++
+		text, rest, found := bytes.Cut(formatted, []byte("\n"))
+		formatted = rest
+		if !found {
+			result = append(result, text...)
+			break
+		}
+
+		result = append(result, bytes.TrimSuffix(text, []byte("\r"))...)
+		if ending, ok := keptEndings[line]; ok {
+			result = append(result, ending...)
+		} else {
+			result = append(result, newLine...)
+		}
+	}
+	return result, nil
+}
+
+// heredocBodies returns the lines of each non-empty heredoc body in prog, in
+// source order. The closing delimiter is not part of the body.
+func heredocBodies(prog *syntax.File) []lineSpan {
+	var bodies []lineSpan
+	syntax.Walk(prog, func(node syntax.Node) bool {
+		redirect, ok := node.(*syntax.Redirect)
+		if !ok || redirect.Hdoc == nil || (redirect.Op != syntax.Hdoc && redirect.Op != syntax.DashHdoc) {
+			return true
+		}
+		start, end := redirect.Hdoc.Pos().Line(), redirect.Hdoc.End().Line()
+		if end > start {
+			bodies = append(bodies, lineSpan{start: start, end: end - 1})
+		}
+		return true
+	})
+	return bodies
+}
+
+// quotedLines returns, in source order, the lines whose line break is part of
+// a quoted string in prog: those of single-quoted strings, and of the literal
+// text of double-quoted strings. Line breaks inside a command substitution in
+// double quotes are code, which the printer may lay out differently.
+func quotedLines(prog *syntax.File) []lineSpan {
+	var spans []lineSpan
+	add := func(node syntax.Node) {
+		start, end := node.Pos().Line(), node.End().Line()
+		if end > start {
+			spans = append(spans, lineSpan{start: start, end: end - 1})
+		}
+	}
+	syntax.Walk(prog, func(node syntax.Node) bool {
+		switch node := node.(type) {
+		case *syntax.SglQuoted:
+			add(node)
+		case *syntax.DblQuoted:
+			for _, part := range node.Parts {
+				if lit, ok := part.(*syntax.Lit); ok {
+					add(lit)
+				}
+			}
+		}
+		return true
+	})
+	return spans
+}
+
+// verbatimLinesUseCRLF reports whether a heredoc body or quoted string line in
+// prog ends with \r\n in src.
+func verbatimLinesUseCRLF(prog *syntax.File, src []byte) bool {
+	if bytes.IndexByte(src, '\r') == -1 {
+		return false
+	}
+	srcLines := lineOffsets(src)
+	for _, span := range append(heredocBodies(prog), quotedLines(prog)...) {
+		for line := span.start; line <= span.end; line++ {
+			if lineEnding(src, srcLines, line) == "\r\n" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// lineEnding returns the ending of the 1-based line of text.
+func lineEnding(text []byte, offsets []uint, line uint) string {
+	end := min(lineStartOffset(offsets, line+1), uint(len(text)))
+	if bytes.HasSuffix(text[:end], []byte("\r\n")) {
+		return "\r\n"
+	}
+	return "\n"
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/hrko/dprint-plugin-shfmt/dprint"
+)
+
+func TestFormatAppliesNewLineKind(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		kind  string
+		input string
+		want  string
+	}{
+		{
+			name:  "auto keeps crlf",
+			kind:  newLineKindAuto,
+			input: "if true;then\r\necho   a\r\nfi\r\n",
+			want:  "if true; then\r\n  echo a\r\nfi\r\n",
+		},
+		{
+			name:  "auto keeps lf",
+			kind:  newLineKindAuto,
+			input: "echo   a\n",
+			want:  "echo a\n",
+		},
+		{
+			name:  "auto follows the dominant ending",
+			kind:  newLineKindAuto,
+			input: "echo   a\r\necho   b\r\necho   c\n",
+			want:  "echo a\r\necho b\r\necho c\r\n",
+		},
+		{
+			name:  "lf converts crlf",
+			kind:  newLineKindLF,
+			input: "echo   a\r\necho   b\r\n",
+			want:  "echo a\necho b\n",
+		},
+		{
+			name:  "crlf converts lf",
+			kind:  newLineKindCRLF,
+			input: "echo   a\n# done\n",
+			want:  "echo a\r\n# done\r\n",
+		},
+		{
+			name:  "heredoc body keeps crlf",
+			kind:  newLineKindLF,
+			input: "cat  <<EOF\r\nline 1\r\nline 2\nEOF\r\necho   a\r\n",
+			want:  "cat <<EOF\nline 1\r\nline 2\nEOF\necho a\n",
+		},
+		{
+			name:  "heredoc body keeps lf",
+			kind:  newLineKindCRLF,
+			input: "cat <<'EOF' ; cat <<B\na\nEOF\nb\r\nB\n",
+			want:  "cat <<'EOF'\r\na\nEOF\r\ncat <<B\r\nb\r\nB\r\n",
+		},
+		{
+			name:  "quoted strings keep lf",
+			kind:  newLineKindCRLF,
+			input: "echo   'a\nb' \"c\nd $(\necho e\n) f\ng\"\n",
+			want:  "echo 'a\nb' \"c\nd $(\r\n  echo e\r\n) f\ng\"\r\n",
+		},
+		{
+			name:  "quoted strings keep crlf",
+			kind:  newLineKindLF,
+			input: "x=$'a\r\nb'\r\necho   \"c\r\nd\"\r\n",
+			want:  "x=$'a\r\nb'\necho \"c\r\nd\"\n",
+		},
+		{
+			name:  "missing final newline stays missing",
+			kind:  newLineKindCRLF,
+			input: "echo   a\necho   b",
+			want:  "echo a\r\necho b\r\n",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			h := &handler{}
+			result := h.Format(
+				dprint.SyncFormatRequest[configuration]{
+					FilePath:  "sample.sh",
+					FileBytes: []byte(tc.input),
+					Config:    configuration{IndentWidth: 2, NewLineKind: tc.kind},
+				},
+				nil,
+			)
+			if result.Code == dprint.FormatResultError {
+				t.Fatalf("unexpected error: %v", result.Err)
+			}
+			got := tc.input
+			if result.Code == dprint.FormatResultChange {
+				got = string(result.Text)
+			}
+			if got != tc.want {
+				t.Fatalf("unexpected output:\nwant %q\ngot  %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestResolveNewLineKind(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name            string
+		config          dprint.ConfigKeyMap
+		global          dprint.GlobalConfiguration
+		want            string
+		wantDiagnostics int
+	}{
+		{name: "default", want: newLineKindAuto},
+		{name: "global", global: dprint.GlobalConfiguration{"newLineKind": "crlf"}, want: newLineKindCRLF},
+		{
+			name:   "plugin overrides global",
+			config: dprint.ConfigKeyMap{"newLineKind": "lf"},
+			global: dprint.GlobalConfiguration{"newLineKind": "crlf"},
+			want:   newLineKindLF,
+		},
+		{
+			name:            "invalid plugin value keeps global",
+			config:          dprint.ConfigKeyMap{"newLineKind": "cr"},
+			global:          dprint.GlobalConfiguration{"newLineKind": "system"},
+			want:            newLineKindSystem,
+			wantDiagnostics: 1,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			h := &handler{}
+			result := h.ResolveConfig(tc.config, tc.global)
+			if result.Config.NewLineKind != tc.want {
+				t.Fatalf("expected %q, got %q", tc.want, result.Config.NewLineKind)
+			}
+			if len(result.Diagnostics) != tc.wantDiagnostics {
+				t.Fatalf("unexpected diagnostics: %#v", result.Diagnostics)
+			}
+		})
+	}
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/hrko/dprint-plugin-shfmt/dprint"
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// maxRecoveredErrors bounds how many missing tokens the parser fills in
+// while collecting errors. It is well above any useful limit, so that the
+// errors kept are the first ones by position rather than by parse order.
+const maxRecoveredErrors = 1000
+
+// parseErrors returns the syntax errors in src, given first, the error the
+// parser stopped at. The input is parsed again in the parser's error-recovery
+// mode, which fills in missing tokens such as a closing "fi" or quote and
+// carries on until it finds an error it cannot recover from. Errors are sorted
+// by position and at most limit are returned.
+func parseErrors(variant syntax.LangVariant, src []byte, name string, first error, limit uint32) []error {
+	limit = max(limit, 1)
+	if _, ok := errorPos(first); !ok || limit == 1 {
+		return []error{first}
+	}
+
+	parser := syntax.NewParser(syntax.Variant(variant), syntax.RecoverErrors(maxRecoveredErrors))
+	prog, err := parser.Parse(bytes.NewReader(src), name)
+
+	var errs []error
+	if prog != nil {
+		errs = recoveredErrors(prog, name)
+	}
+	if _, ok := errorPos(err); ok {
+		errs = append(errs, err)
+	}
+	if len(errs) <= 1 {
+		// The parser's own message is more precise about what it reached.
+		return []error{first}
+	}
+
+	sort.SliceStable(errs, func(i, j int) bool {
+		posI, _ := errorPos(errs[i])
+		posJ, _ := errorPos(errs[j])
+		return posI.Offset() < posJ.Offset()
+	})
+	if len(errs) > int(limit) {
+		errs = append(errs[:limit:limit], &dprint.Diagnostic{
+			FilePath: name,
+			Severity: dprint.SeverityError,
+			Code:     codeTooManyErrors,
+			Message:  fmt.Sprintf("stopped after %d syntax errors", limit),
+		})
+	}
+	return errs
+}
+
+func errorPos(err error) (syntax.Pos, bool) {
+	var parseErr syntax.ParseError
+	if errors.As(err, &parseErr) {
+		return parseErr.Pos, true
+	}
+	var langErr syntax.LangError
+	if errors.As(err, &langErr) {
+		return langErr.Pos, true
+	}
+	return syntax.Pos{}, false
+}
+
+// recoveredErrors describes each token the parser filled in while recovering
+// from errors in prog. The filled-in tokens have no position of their own, so
+// each error is reported at the token that required the missing one. Errors
+// are worded like the parser's own, except that an unclosed bracket or quote
+// is not described by what was reached instead.
+func recoveredErrors(prog *syntax.File, name string) []error {
+	var errs []error
+	add := func(pos syntax.Pos, format string, args ...any) {
+		if pos.IsValid() && !pos.IsRecovered() {
+			errs = append(errs, syntax.ParseError{Filename: name, Pos: pos, Text: fmt.Sprintf(format, args...)})
+		}
+	}
+	unclosed := func(pos syntax.Pos, left string, right string) {
+		add(pos, "%s must be closed with %s", left, right)
+	}
+	missingStmts := func(stmts []*syntax.Stmt, pos syntax.Pos, keyword string) {
+		if len(stmts) == 1 && stmts[0].Position.IsRecovered() {
+			add(pos, "%s must be followed by a statement list", readableToken(keyword))
+		}
+	}
+	missingWord := func(word syntax.Node, pos syntax.Pos, op string) {
+		if word, ok := word.(*syntax.Word); ok && word.Pos().IsRecovered() {
+			add(pos, "%s must be followed by a word", op)
+		}
+	}
+
+	// The clauses of an if statement share its "fi", and the "elif" and
+	// "else" clauses are nested in the ones before them.
+	elseKeywords := map[*syntax.IfClause]string{}
+
+	syntax.Walk(prog, func(node syntax.Node) bool {
+		switch node := node.(type) {
+		case *syntax.Subshell:
+			if node.Rparen.IsRecovered() {
+				unclosed(node.Lparen, "(", ")")
+			}
+		case *syntax.Block:
+			if node.Rbrace.IsRecovered() {
+				unclosed(node.Lbrace, "{", "}")
+			}
+		case *syntax.SglQuoted:
+			if node.Right.IsRecovered() {
+				left := "'"
+				if node.Dollar {
+					left = "$'"
+				}
+				unclosed(node.Left, left, "'")
+			}
+		case *syntax.DblQuoted:
+			if node.Right.IsRecovered() {
+				left := `"`
+				if node.Dollar {
+					left = `$"`
+				}
+				unclosed(node.Left, left, `"`)
+			}
+		case *syntax.CmdSubst:
+			if node.Right.IsRecovered() {
+				if node.Backquotes {
+					unclosed(node.Left, "`", "`")
+				} else {
+					unclosed(node.Left, "$(", ")")
+				}
+			}
+		case *syntax.ProcSubst:
+			if node.Rparen.IsRecovered() {
+				unclosed(node.OpPos, node.Op.String(), ")")
+			}
+		case *syntax.ParamExp:
+			if node.Rbrace.IsRecovered() {
+				unclosed(node.Dollar, "${", "}")
+			}
+		case *syntax.ArrayExpr:
+			if node.Rparen.IsRecovered() {
+				unclosed(node.Lparen, "(", ")")
+			}
+		case *syntax.ArithmExp:
+			if node.Right.IsRecovered() {
+				unclosed(node.Left, "$((", "))")
+			}
+		case *syntax.ArithmCmd:
+			if node.Right.IsRecovered() {
+				unclosed(node.Left, "((", "))")
+			}
+		case *syntax.ParenArithm:
+			if node.Rparen.IsRecovered() {
+				unclosed(node.Lparen, "(", ")")
+			}
+		case *syntax.ParenTest:
+			if node.Rparen.IsRecovered() {
+				unclosed(node.Lparen, "(", ")")
+			}
+		case *syntax.IfClause:
+			keyword, nested := elseKeywords[node]
+			if !nested {
+				keyword = "if"
+			}
+			if node.Else != nil {
+				elseKeywords[node.Else] = "elif"
+				if !node.Else.ThenPos.IsValid() && !node.Else.ThenPos.IsRecovered() {
+					elseKeywords[node.Else] = "else"
+				}
+			}
+			if keyword == "else" {
+				missingStmts(node.Then, node.Position, "else")
+				break
+			}
+			missingStmts(node.Cond, node.Position, keyword)
+			if node.ThenPos.IsRecovered() {
+				add(node.Position, `"%s <cond>" must be followed by "then"`, keyword)
+			} else {
+				missingStmts(node.Then, node.ThenPos, "then")
+			}
+			if !nested && node.FiPos.IsRecovered() {
+				add(node.Position, `if statement must end with "fi"`)
+			}
+		case *syntax.WhileClause:
+			keyword := "while"
+			if node.Until {
+				keyword = "until"
+			}
+			missingStmts(node.Cond, node.WhilePos, keyword)
+			if node.DoPos.IsRecovered() {
+				add(node.WhilePos, `"%s <cond>" must be followed by "do"`, keyword)
+			} else {
+				missingStmts(node.Do, node.DoPos, "do")
+			}
+			if node.DonePos.IsRecovered() {
+				add(node.WhilePos, `%s statement must end with "done"`, keyword)
+			}
+		case *syntax.ForClause:
+			keyword, start, end := "for", "do", "done"
+			if node.Select {
+				keyword = "select"
+			}
+			if node.Braces {
+				start, end = "{", "}"
+			}
+			if node.DoPos.IsRecovered() {
+				add(node.ForPos, `"%s foo [in words]" must be followed by %q`, keyword, start)
+			} else {
+				missingStmts(node.Do, node.DoPos, start)
+			}
+			if node.DonePos.IsRecovered() {
+				add(node.ForPos, "%s statement must end with %q", keyword, end)
+			}
+		case *syntax.CaseClause:
+			end := "esac"
+			if node.Braces {
+				end = "}"
+			}
+			if node.In.IsRecovered() {
+				add(node.Case, `"case x" must be followed by "in"`)
+			}
+			if node.Esac.IsRecovered() {
+				add(node.Case, "case statement must end with %q", end)
+			}
+		case *syntax.BinaryCmd:
+			if node.Y != nil && node.Y.Position.IsRecovered() {
+				add(node.OpPos, "%s must be followed by a statement", node.Op)
+			}
+		case *syntax.Redirect:
+			if node.Word != nil {
+				missingWord(node.Word, node.OpPos, node.Op.String())
+			}
+		case *syntax.BinaryTest:
+			missingWord(node.Y, node.OpPos, node.Op.String())
+		case *syntax.UnaryTest:
+			missingWord(node.X, node.OpPos, node.Op.String())
+		}
+		return true
+	})
+	return errs
+}
+
+// readableToken quotes reserved words the way the parser does in its errors,
+// leaving operators such as { alone.
+func readableToken(token string) string {
+	if token != "" && token[0] >= 'a' && token[0] <= 'z' {
+		return fmt.Sprintf("%q", token)
+	}
+	return token
+}
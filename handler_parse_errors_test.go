@@ -0,0 +1,97 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/hrko/dprint-plugin-shfmt/dprint"
+)
+
+func TestFormatReportsAllParseErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		path  string
+		input string
+		limit uint32
+		want  string
+	}{
+		{
+			name:  "single error",
+			path:  "sample.sh",
+			input: "if true; then\n  echo a\n",
+			limit: 10,
+			want: "sample.sh:1:1: if statement must end with \"fi\" [syntax-error]\n" +
+				"  hint: parsed as posix (detected from the file extension)",
+		},
+		{
+			name:  "errors sorted by position",
+			path:  "sample.sh",
+			input: "if true; then\n  echo a\n\nwhile x; do\n  echo b\n\nfoo |\n",
+			limit: 10,
+			want: "sample.sh:1:1: if statement must end with \"fi\" [syntax-error]\n" +
+				"sample.sh:4:1: while statement must end with \"done\" [syntax-error]\n" +
+				"sample.sh:7:5: | must be followed by a statement [syntax-error]\n" +
+				"  hint: parsed as posix (detected from the file extension)",
+		},
+		{
+			name:  "missing words and keywords",
+			path:  "sample.sh",
+			input: "echo a >\nfor i in 1 2; echo $i; done\n",
+			limit: 10,
+			want: "sample.sh:1:8: > must be followed by a word [syntax-error]\n" +
+				"sample.sh:2:1: \"for foo [in words]\" must be followed by \"do\" [syntax-error]\n" +
+				"  hint: parsed as posix (detected from the file extension)",
+		},
+		{
+			name:  "unclosed brackets",
+			path:  "sample.sh",
+			input: "f() {\n  echo \"$(ls\n",
+			limit: 10,
+			want: "sample.sh:1:5: { must be closed with } [syntax-error]\n" +
+				"sample.sh:2:8: \" must be closed with \" [syntax-error]\n" +
+				"sample.sh:2:9: $( must be closed with ) [syntax-error]\n" +
+				"  hint: parsed as posix (detected from the file extension)",
+		},
+		{
+			name:  "capped",
+			path:  "sample.sh",
+			input: "if true; then\n  echo a\n\nwhile x; do\n  echo b\n\nfoo |\n",
+			limit: 2,
+			want: "sample.sh:1:1: if statement must end with \"fi\" [syntax-error]\n" +
+				"sample.sh:4:1: while statement must end with \"done\" [syntax-error]\n" +
+				"sample.sh: stopped after 2 syntax errors [too-many-errors]\n" +
+				"  hint: parsed as posix (detected from the file extension)",
+		},
+		{
+			name:  "one error when limit is one",
+			path:  "sample.sh",
+			input: "if true; then\n  echo a\n\nfoo |\n",
+			limit: 1,
+			want: "sample.sh:4:5: | must be followed by a statement [syntax-error]\n" +
+				"  hint: parsed as posix (detected from the file extension)",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			h := &handler{}
+			result := h.Format(
+				dprint.SyncFormatRequest[configuration]{
+					FilePath:  tc.path,
+					FileBytes: []byte(tc.input),
+					Config:    configuration{IndentWidth: 2, MaxParseErrors: tc.limit},
+				},
+				nil,
+			)
+			if result.Code != dprint.FormatResultError {
+				t.Fatalf("expected error result, got %d", result.Code)
+			}
+			if result.Err.Error() != tc.want {
+				t.Fatalf("unexpected error:\nwant %s\ngot  %s", tc.want, result.Err)
+			}
+		})
+	}
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/hrko/dprint-plugin-shfmt/dprint"
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// lineSpan is an inclusive, 1-based range of source lines.
+type lineSpan struct {
+	start uint
+	end   uint
+}
+
+func isPartialRange(formatRange *dprint.FormatRange, size int) bool {
+	if formatRange == nil {
+		return false
+	}
+	return formatRange.Start > 0 || int(formatRange.End) < size
+}
+
+// spliceFormattedRange replaces the lines of the statements overlapping
+// formatRange with their counterparts in the fully formatted output. Every
+// byte outside of the selected statements is kept as it was in src, so src is
+// returned unchanged when no statement overlaps the range. An empty range
+// selects the statement at its position, and the range is clamped to src.
+//
+// Selection starts at the top-level statements and descends into a function
+// body when the range lies entirely within it, so that formatting a few lines
+// of a long function does not rewrite the whole function.
+func spliceFormattedRange(
+	parser *syntax.Parser,
+	src []byte,
+	srcFile *syntax.File,
+	formatted []byte,
+	formatRange dprint.FormatRange,
+) ([]byte, error) {
+	formattedFile, err := parser.Parse(bytes.NewReader(formatted), "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse formatted output for range formatting: %w", err)
+	}
+
+	srcLines := lineOffsets(src)
+	rangeStart := min(uint(formatRange.Start), uint(len(src)))
+	rangeEnd := min(uint(formatRange.End), uint(len(src)))
+	if rangeStart == uint(len(src)) {
+		return src, nil
+	}
+	rangeEnd = max(rangeEnd, rangeStart+1)
+
+	srcStmts, formattedStmts := srcFile.Stmts, formattedFile.Stmts
+	var enclosingSrc, enclosingFormatted *syntax.Stmt
+	var enclosingBody lineSpan
+	for {
+		if len(srcStmts) != len(formattedStmts) {
+			return nil, fmt.Errorf("formatted output has %d statements where the input has %d", len(formattedStmts), len(srcStmts))
+		}
+
+		first, last, ok := selectOverlappingStmts(srcStmts, srcLines, rangeStart, rangeEnd)
+		if !ok {
+			return src, nil
+		}
+
+		srcSpan := stmtsLineSpan(srcStmts[first : last+1])
+		if enclosingSrc != nil && (srcSpan.start <= enclosingBody.start || srcSpan.end >= enclosingBody.end) {
+			// The selection shares a line with the enclosing braces, so only
+			// the enclosing statement as a whole can be replaced.
+			break
+		}
+
+		if first == last {
+			srcBody, formattedBody, ok := funcBodies(srcStmts[first], formattedStmts[first])
+			if ok {
+				bodySpan := lineSpan{start: srcBody.Lbrace.Line(), end: srcBody.Rbrace.Line()}
+				if lineStartOffset(srcLines, bodySpan.start+1) <= rangeStart &&
+					rangeEnd <= lineStartOffset(srcLines, bodySpan.end) {
+					enclosingSrc, enclosingFormatted = srcStmts[first], formattedStmts[first]
+					enclosingBody = bodySpan
+					srcStmts, formattedStmts = srcBody.Stmts, formattedBody.Stmts
+					continue
+				}
+			}
+		}
+
+		formattedSpan := stmtsLineSpan(formattedStmts[first : last+1])
+		return replaceLines(src, srcLines, srcSpan, formatted, lineOffsets(formatted), formattedSpan), nil
+	}
+
+	return replaceLines(
+		src,
+		srcLines,
+		stmtLineSpan(enclosingSrc),
+		formatted,
+		lineOffsets(formatted),
+		stmtLineSpan(enclosingFormatted),
+	), nil
+}
+
+// selectOverlappingStmts returns the indexes of the first and last statement
+// in stmts whose lines overlap [rangeStart, rangeEnd). Statements sharing a
+// line with the selection are included too, since replacement is line based.
+func selectOverlappingStmts(stmts []*syntax.Stmt, lines []uint, rangeStart uint, rangeEnd uint) (int, int, bool) {
+	first, last := -1, -1
+	for i, stmt := range stmts {
+		span := stmtLineSpan(stmt)
+		if lineStartOffset(lines, span.start) < rangeEnd && rangeStart < lineStartOffset(lines, span.end+1) {
+			if first == -1 {
+				first = i
+			}
+			last = i
+		}
+	}
+	if first == -1 {
+		return 0, 0, false
+	}
+
+	for first > 0 && stmtLineSpan(stmts[first-1]).end >= stmtLineSpan(stmts[first]).start {
+		first--
+	}
+	for last < len(stmts)-1 && stmtLineSpan(stmts[last+1]).start <= stmtLineSpan(stmts[last]).end {
+		last++
+	}
+	return first, last, true
+}
+
+func funcBodies(srcStmt *syntax.Stmt, formattedStmt *syntax.Stmt) (*syntax.Block, *syntax.Block, bool) {
+	srcDecl, ok := srcStmt.Cmd.(*syntax.FuncDecl)
+	if !ok {
+		return nil, nil, false
+	}
+	formattedDecl, ok := formattedStmt.Cmd.(*syntax.FuncDecl)
+	if !ok {
+		return nil, nil, false
+	}
+
+	srcBody, ok := srcDecl.Body.Cmd.(*syntax.Block)
+	if !ok {
+		return nil, nil, false
+	}
+	formattedBody, ok := formattedDecl.Body.Cmd.(*syntax.Block)
+	if !ok {
+		return nil, nil, false
+	}
+	return srcBody, formattedBody, true
+}
+
+func stmtsLineSpan(stmts []*syntax.Stmt) lineSpan {
+	span := stmtLineSpan(stmts[0])
+	for _, stmt := range stmts[1:] {
+		next := stmtLineSpan(stmt)
+		span.start = min(span.start, next.start)
+		span.end = max(span.end, next.end)
+	}
+	return span
+}
+
+// stmtLineSpan returns the lines occupied by stmt, including its leading and
+// trailing comments and the bodies of any heredocs it starts.
+func stmtLineSpan(stmt *syntax.Stmt) lineSpan {
+	span := lineSpan{start: stmt.Pos().Line(), end: stmt.End().Line()}
+	for _, comment := range stmt.Comments {
+		span.start = min(span.start, comment.Pos().Line())
+		span.end = max(span.end, comment.End().Line())
+	}
+
+	heredocEnd := span.end
+	syntax.Walk(stmt, func(node syntax.Node) bool {
+		redirect, ok := node.(*syntax.Redirect)
+		if !ok || (redirect.Op != syntax.Hdoc && redirect.Op != syntax.DashHdoc) {
+			return true
+		}
+		if redirect.Hdoc != nil {
+			heredocEnd = max(heredocEnd, redirect.Hdoc.End().Line())
+		} else {
+			// An empty body only consists of the closing delimiter line.
+			heredocEnd++
+		}
+		return true
+	})
+	span.end = max(span.end, heredocEnd)
+
+	return span
+}
+
+// lineOffsets returns the byte offset at which each line of text starts.
+func lineOffsets(text []byte) []uint {
+	offsets := []uint{0}
+	for i, b := range text {
+		if b == '\n' && i+1 < len(text) {
+			offsets = append(offsets, uint(i+1))
+		}
+	}
+	return offsets
+}
+
+// lineStartOffset returns the offset of the 1-based line, or the end of the
+// text when line is past the last line.
+func lineStartOffset(offsets []uint, line uint) uint {
+	if line == 0 {
+		return 0
+	}
+	if int(line) > len(offsets) {
+		return ^uint(0)
+	}
+	return offsets[line-1]
+}
+
+func replaceLines(
+	src []byte,
+	srcLines []uint,
+	srcSpan lineSpan,
+	formatted []byte,
+	formattedLines []uint,
+	formattedSpan lineSpan,
+) []byte {
+	srcStart := min(lineStartOffset(srcLines, srcSpan.start), uint(len(src)))
+	srcEnd := min(lineStartOffset(srcLines, srcSpan.end+1), uint(len(src)))
+	formattedStart := min(lineStartOffset(formattedLines, formattedSpan.start), uint(len(formatted)))
+	formattedEnd := min(lineStartOffset(formattedLines, formattedSpan.end+1), uint(len(formatted)))
+
+	replacement := formatted[formattedStart:formattedEnd]
+	if srcEnd == uint(len(src)) && !bytes.HasSuffix(src, []byte("\n")) {
+		// Keep a missing final newline missing.
+		replacement = bytes.TrimSuffix(bytes.TrimSuffix(replacement, []byte("\n")), []byte("\r"))
+	}
+
+	result := make([]byte, 0, len(src)-int(srcEnd-srcStart)+len(replacement))
+	result = append(result, src[:srcStart]...)
+	result = append(result, replacement...)
+	result = append(result, src[srcEnd:]...)
+	return result
+}
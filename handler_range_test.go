@@ -0,0 +1,175 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hrko/dprint-plugin-shfmt/dprint"
+)
+
+func TestFormatRange(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		input     string
+		selection string
+		want      string
+	}{
+		{
+			name:      "only overlapping top-level statement",
+			input:     "if true;then\necho a\nfi\nif true;then\necho b\nfi\n",
+			selection: "echo b",
+			want:      "if true;then\necho a\nfi\nif true; then\n  echo b\nfi\n",
+		},
+		{
+			name:      "statements within function body",
+			input:     "f(){\necho   a\necho   b\n}\necho   c\n",
+			selection: "echo   b",
+			want:      "f(){\necho   a\n  echo b\n}\necho   c\n",
+		},
+		{
+			name:      "nested function body",
+			input:     "outer(){\ninner(){\necho   a\necho   b\n}\n}\n",
+			selection: "echo   a",
+			want:      "outer(){\ninner(){\n    echo a\necho   b\n}\n}\n",
+		},
+		{
+			name:      "statement sharing a line with braces falls back to function",
+			input:     "f(){ echo   a\necho   b;}\necho   c\n",
+			selection: "echo   b",
+			want:      "f() {\n  echo a\n  echo b\n}\necho   c\n",
+		},
+		{
+			name:      "statements sharing a line are selected together",
+			input:     "echo   a;echo   b\necho   c\n",
+			selection: "echo   b",
+			want:      "echo a\necho b\necho   c\n",
+		},
+		{
+			name:      "leading comment and heredoc body",
+			input:     "echo   a\n# note\ncat  <<EOF\n  body\nEOF\necho   b\n",
+			selection: "cat",
+			want:      "echo   a\n# note\ncat <<EOF\n  body\nEOF\necho   b\n",
+		},
+		{
+			name:      "missing final newline is kept",
+			input:     "echo   a\necho   b",
+			selection: "echo   b",
+			want:      "echo   a\necho b",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			start := strings.Index(tc.input, tc.selection)
+			if start == -1 {
+				t.Fatalf("selection %q not found in input", tc.selection)
+			}
+
+			result := formatRangeForTest(tc.input, uint32(start), uint32(start+len(tc.selection)))
+			if result.Code != dprint.FormatResultChange {
+				t.Fatalf("expected change result, got %d (err: %v)", result.Code, result.Err)
+			}
+			if string(result.Text) != tc.want {
+				t.Fatalf("unexpected output:\nwant %q\ngot  %q", tc.want, string(result.Text))
+			}
+		})
+	}
+}
+
+func TestFormatRangeWithoutOverlappingStatement(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		input string
+		start int
+		end   int
+	}{
+		{
+			name:  "empty range on a blank line",
+			input: "echo   a\n\necho   b\n",
+			start: len("echo   a\n"),
+			end:   len("echo   a\n"),
+		},
+		{
+			name:  "empty range after the last statement",
+			input: "echo   a\necho   b\n\n\n",
+			start: len("echo   a\necho   b\n\n"),
+			end:   len("echo   a\necho   b\n\n"),
+		},
+		{
+			name:  "empty range at the end of the file",
+			input: "echo   a\necho   b\n",
+			start: len("echo   a\necho   b\n"),
+			end:   len("echo   a\necho   b\n"),
+		},
+		{
+			name:  "range past the end of the file",
+			input: "echo   a\necho   b\n",
+			start: len("echo   a\necho   b\n") + 5,
+			end:   len("echo   a\necho   b\n") + 10,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			result := formatRangeForTest(tc.input, uint32(tc.start), uint32(tc.end))
+			if result.Code != dprint.FormatResultNoChange {
+				t.Fatalf("expected no-change result, got %d (text: %q)", result.Code, string(result.Text))
+			}
+		})
+	}
+}
+
+func TestFormatRangeEmptyWithinStatement(t *testing.T) {
+	t.Parallel()
+
+	input := "echo   a\necho   b\n"
+	start := uint32(strings.Index(input, "b"))
+
+	result := formatRangeForTest(input, start, start)
+	if result.Code != dprint.FormatResultChange {
+		t.Fatalf("expected change result, got %d (err: %v)", result.Code, result.Err)
+	}
+	if string(result.Text) != "echo   a\necho b\n" {
+		t.Fatalf("unexpected output: %q", string(result.Text))
+	}
+}
+
+func TestFormatRangeCoveringWholeFile(t *testing.T) {
+	t.Parallel()
+
+	input := "echo   a\necho   b\n"
+
+	result := formatRangeForTest(input, 0, uint32(len(input)))
+	if result.Code != dprint.FormatResultChange {
+		t.Fatalf("expected change result, got %d", result.Code)
+	}
+	if string(result.Text) != "echo a\necho b\n" {
+		t.Fatalf("unexpected output: %q", string(result.Text))
+	}
+}
+
+func formatRangeForTest(input string, start uint32, end uint32) dprint.FormatResult {
+	h := &handler{}
+	return h.Format(
+		dprint.SyncFormatRequest[configuration]{
+			FilePath:  "sample.bash",
+			FileBytes: []byte(input),
+			Config: configuration{
+				IndentWidth: 2,
+			},
+			Range: &dprint.FormatRange{
+				Start: start,
+				End:   end,
+			},
+		},
+		nil,
+	)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/hrko/dprint-plugin-shfmt/dprint"
+)
+
+// checkStability formats formatted a second time and reports the drift when
+// the second pass changes it, since dprint check would otherwise flip-flop
+// between the two outputs.
+//
+// Format runs it last, on the output it is about to return, when the
+// checkStability option is set and the whole file is being formatted. The
+// second pass uses the same configuration and host, so heredoc bodies are
+// formatted again too. A change is reported as a unified diff from the first
+// pass to the second, and an error in the second pass is wrapped as it is.
+func (h *handler) checkStability(
+	request dprint.SyncFormatRequest[configuration],
+	formatted []byte,
+	formatWithHost dprint.HostFormatFunc,
+) error {
+	second := request
+	second.FileBytes = formatted
+	second.Range = nil
+	second.Config.CheckStability = false
+
+	result := h.format(second, formatWithHost)
+	switch result.Code {
+	case dprint.FormatResultCancelled:
+		return errCancelled
+	case dprint.FormatResultError:
+		return fmt.Errorf("formatting is not stable: the formatted output cannot be formatted again: %w", result.Err)
+	case dprint.FormatResultChange:
+		return fmt.Errorf(
+			"formatting is not stable: formatting the output again changes it:\n%s",
+			unifiedDiff(
+				request.FilePath+" (first pass)",
+				request.FilePath+" (second pass)",
+				string(formatted),
+				string(result.Text),
+			),
+		)
+	default:
+		return nil
+	}
+}
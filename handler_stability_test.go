@@ -0,0 +1,74 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hrko/dprint-plugin-shfmt/dprint"
+)
+
+func TestFormatChecksStability(t *testing.T) {
+	t.Parallel()
+
+	// The host appends a line on every pass, so the output never settles.
+	growingHost := func(request dprint.SyncHostFormatRequest) dprint.FormatResult {
+		return dprint.Change(append(request.FileBytes, "}\n"...))
+	}
+
+	request := dprint.SyncFormatRequest[configuration]{
+		FilePath:  "sample.sh",
+		FileBytes: []byte("cat <<'JSON'\n{\nJSON\n"),
+		Config: configuration{
+			IndentWidth:      2,
+			HeredocLanguages: map[string]string{"JSON": "json"},
+		},
+	}
+
+	h := &handler{}
+	result := h.Format(request, growingHost)
+	if result.Code != dprint.FormatResultChange {
+		t.Fatalf("expected change result without the check, got %d (err: %v)", result.Code, result.Err)
+	}
+
+	request.Config.CheckStability = true
+	result = h.Format(request, growingHost)
+	if result.Code != dprint.FormatResultError {
+		t.Fatalf("expected error result, got %d", result.Code)
+	}
+	want := strings.Join([]string{
+		"formatting is not stable: formatting the output again changes it:",
+		"--- sample.sh (first pass)",
+		"+++ sample.sh (second pass)",
+		"@@ -1,4 +1,5 @@",
+		" cat <<'JSON'",
+		" {",
+		" }",
+		"+}",
+		" JSON",
+		"",
+	}, "\n")
+	if result.Err.Error() != want {
+		t.Fatalf("unexpected error:\nwant %s\ngot  %s", want, result.Err)
+	}
+}
+
+func TestFormatPassesStabilityCheck(t *testing.T) {
+	t.Parallel()
+
+	h := &handler{}
+	result := h.Format(
+		dprint.SyncFormatRequest[configuration]{
+			FilePath:  "sample.sh",
+			FileBytes: []byte("case $a in\nx) echo x;; # note\nesac\n"),
+			Config: configuration{
+				IndentWidth:    2,
+				CheckStability: true,
+			},
+		},
+		nil,
+	)
+
+	if result.Code != dprint.FormatResultChange {
+		t.Fatalf("expected change result, got %d (err: %v)", result.Code, result.Err)
+	}
+}
@@ -2,20 +2,82 @@ package main
 
 import (
 	"bytes"
+	"fmt"
 	"path/filepath"
+	"regexp"
 	"strings"
 
+	"github.com/hrko/dprint-plugin-shfmt/dprint"
 	"mvdan.cc/sh/v3/syntax"
 )
 
-func detectVariant(filePath string, fileBytes []byte) syntax.LangVariant {
+const variantAuto = "auto"
+
+// langZsh selects the zsh mode. The parser has no zsh variant, so zsh files
+// are parsed as Bash once their zsh-only syntax has been protected.
+const langZsh = syntax.LangAuto + 1
+
+// variantOverride selects the variant for files matching a glob.
+type variantOverride struct {
+	Files   string `description:"Glob pattern selecting the files this override applies to, for example \"scripts/legacy/**\"." dprint:"required"                                    json:"files"`
+	Variant string `description:"Shell language variant used for matching files."                                               dprint:"required,enum=auto|posix|bash|mksh|bats|zsh" json:"variant"`
+}
+
+// resolveVariant picks the language variant for a file. The last override
+// whose glob matches the path wins over the variant option, and "auto" falls
+// back to detection from the file contents and path. It also describes how the
+// variant was chosen, for error messages.
+func resolveVariant(config configuration, filePath string, fileBytes []byte) (syntax.LangVariant, string) {
+	name, source := config.Variant, "set by the variant option"
+	for _, override := range config.Overrides {
+		if matchFileGlob(override.Files, filePath) {
+			name, source = override.Variant, fmt.Sprintf("set by the override for '%s'", override.Files)
+		}
+	}
+
+	if variant, ok := variantFromShellName(name); ok {
+		return variant, source
+	}
+	return detectVariant(config, filePath, fileBytes)
+}
+
+// validateVariantOverride checks what the override spec cannot: that files
+// is a usable glob pattern.
+func validateVariantOverride(override variantOverride) []dprint.ConfigProblem {
+	if strings.TrimSpace(override.Files) == "" || !validFileGlob(override.Files) {
+		return []dprint.ConfigProblem{{Key: "files", Expected: "a non-empty glob pattern", Value: override.Files}}
+	}
+	return nil
+}
+
+// detectVariant infers the variant from the file. A ShellCheck shell
+// directive is the most explicit statement of intent, followed by the
+// shebang, an editor modeline and finally the file name and extension.
+func detectVariant(config configuration, filePath string, fileBytes []byte) (syntax.LangVariant, string) {
+	if variant, ok := variantFromShellCheckDirective(fileBytes); ok {
+		return variant, "detected from a ShellCheck directive"
+	}
 	if variant, ok := variantFromShebang(fileBytes); ok {
-		return variant
+		return variant, "detected from the shebang"
+	}
+	if variant, ok := variantFromModeline(fileBytes); ok {
+		return variant, "detected from a modeline"
+	}
+	if variant, ok := variantFromFileName(config, filePath); ok {
+		return variant, "detected from the file name"
 	}
 	if variant, ok := variantFromFilePath(filePath); ok {
-		return variant
+		return variant, "detected from the file extension"
+	}
+	return syntax.LangBash, "the default"
+}
+
+// variantName returns the name the variant option uses for variant.
+func variantName(variant syntax.LangVariant) string {
+	if variant == langZsh {
+		return "zsh"
 	}
-	return syntax.LangBash
+	return variant.String()
 }
 
 func variantFromFilePath(filePath string) (syntax.LangVariant, bool) {
@@ -23,8 +85,12 @@ func variantFromFilePath(filePath string) (syntax.LangVariant, bool) {
 	switch extension {
 	case "sh":
 		return syntax.LangPOSIX, true
-	case "bash", "zsh", "bats":
+	case "bash":
 		return syntax.LangBash, true
+	case "zsh":
+		return langZsh, true
+	case "bats":
+		return syntax.LangBats, true
 	case "mksh":
 		return syntax.LangMirBSDKorn, true
 	default:
@@ -59,14 +125,168 @@ func variantFromShebang(fileBytes []byte) (syntax.LangVariant, bool) {
 		}
 	}
 
-	switch interpreter {
-	case "sh", "dash", "ash":
+	return variantFromShellName(interpreter)
+}
+
+// variantFromShellName maps a shell name as written in shebangs, directives
+// and modelines to a variant.
+func variantFromShellName(name string) (syntax.LangVariant, bool) {
+	switch strings.ToLower(name) {
+	case "sh", "dash", "ash", "posix":
 		return syntax.LangPOSIX, true
-	case "bash", "zsh", "bats":
+	case "bash":
 		return syntax.LangBash, true
+	case "zsh":
+		return langZsh, true
+	case "bats":
+		return syntax.LangBats, true
 	case "mksh":
 		return syntax.LangMirBSDKorn, true
 	default:
 		return syntax.LangBash, false
 	}
 }
+
+// variantFromShellCheckDirective reads a "# shellcheck shell=NAME" directive
+// from the leading comment block.
+func variantFromShellCheckDirective(fileBytes []byte) (syntax.LangVariant, bool) {
+	for _, comment := range leadingComments(fileBytes) {
+		fields := strings.Fields(comment)
+		if len(fields) == 0 || fields[0] != "shellcheck" {
+			continue
+		}
+		for _, field := range fields[1:] {
+			if name, ok := strings.CutPrefix(field, "shell="); ok {
+				return variantFromShellName(name)
+			}
+		}
+	}
+	return syntax.LangBash, false
+}
+
+// variantFromModeline reads a vim or Emacs modeline from the leading comment
+// block, or a vim modeline from the last lines of the file as vim itself
+// does.
+func variantFromModeline(fileBytes []byte) (syntax.LangVariant, bool) {
+	for _, comment := range leadingComments(fileBytes) {
+		if variant, ok := variantFromEmacsModeline(comment); ok {
+			return variant, true
+		}
+		if variant, ok := variantFromVimModeline(comment); ok {
+			return variant, true
+		}
+	}
+	for _, comment := range trailingComments(fileBytes, trailingModelineLines) {
+		if variant, ok := variantFromVimModeline(comment); ok {
+			return variant, true
+		}
+	}
+	return syntax.LangBash, false
+}
+
+// trailingModelineLines matches the default of vim's 'modelines' option.
+const trailingModelineLines = 5
+
+var vimModelinePattern = regexp.MustCompile(`(?:^|\s)(?:vi|vim|Vim|ex)(?:[<=>]?\d+)?:\s*(.*)$`)
+
+// variantFromVimModeline parses "vim: ft=bash" and "vim: set filetype=sh :".
+func variantFromVimModeline(comment string) (syntax.LangVariant, bool) {
+	match := vimModelinePattern.FindStringSubmatch(comment)
+	if match == nil {
+		return syntax.LangBash, false
+	}
+
+	options := match[1]
+	if rest, ok := strings.CutPrefix(options, "set "); ok {
+		options, _, _ = strings.Cut(rest, ":")
+	} else if rest, ok := strings.CutPrefix(options, "se "); ok {
+		options, _, _ = strings.Cut(rest, ":")
+	}
+
+	fields := strings.FieldsFunc(options, func(r rune) bool {
+		return r == ' ' || r == '\t' || r == ':'
+	})
+	for _, field := range fields {
+		key, value, ok := strings.Cut(field, "=")
+		if ok && (key == "ft" || key == "filetype") {
+			return variantFromShellName(value)
+		}
+	}
+	return syntax.LangBash, false
+}
+
+// variantFromEmacsModeline parses "-*- mode: sh; sh-shell: bash -*-" and the
+// short "-*- sh -*-" form. The sh-shell variable is more specific than the
+// major mode, which is the same for every shell.
+func variantFromEmacsModeline(comment string) (syntax.LangVariant, bool) {
+	_, rest, ok := strings.Cut(comment, "-*-")
+	if !ok {
+		return syntax.LangBash, false
+	}
+	body, _, ok := strings.Cut(rest, "-*-")
+	if !ok {
+		return syntax.LangBash, false
+	}
+
+	if !strings.Contains(body, ":") {
+		return variantFromEmacsMode(strings.TrimSpace(body))
+	}
+
+	mode := ""
+	for _, variable := range strings.Split(body, ";") {
+		key, value, ok := strings.Cut(variable, ":")
+		if !ok {
+			continue
+		}
+		key, value = strings.ToLower(strings.TrimSpace(key)), strings.TrimSpace(value)
+		switch key {
+		case "sh-shell":
+			return variantFromShellName(value)
+		case "mode":
+			mode = value
+		}
+	}
+	return variantFromEmacsMode(mode)
+}
+
+func variantFromEmacsMode(mode string) (syntax.LangVariant, bool) {
+	mode = strings.TrimSuffix(strings.ToLower(mode), "-mode")
+	mode = strings.TrimSuffix(mode, "-ts")
+	return variantFromShellName(mode)
+}
+
+// leadingComments returns the text of the comments at the top of the file,
+// after the shebang and before the first line of code.
+func leadingComments(fileBytes []byte) []string {
+	var comments []string
+	for i, line := range strings.Split(string(fileBytes), "\n") {
+		line = strings.TrimSpace(line)
+		if i == 0 && strings.HasPrefix(line, "#!") {
+			continue
+		}
+		if line == "" {
+			continue
+		}
+		text, ok := strings.CutPrefix(line, "#")
+		if !ok {
+			break
+		}
+		comments = append(comments, strings.TrimSpace(text))
+	}
+	return comments
+}
+
+// trailingComments returns the text of the comments among the last count
+// lines of the file.
+func trailingComments(fileBytes []byte, count int) []string {
+	lines := strings.Split(strings.TrimRight(string(fileBytes), "\r\n"), "\n")
+	lines = lines[max(len(lines)-count, 0):]
+
+	var comments []string
+	for _, line := range lines {
+		if text, ok := strings.CutPrefix(strings.TrimSpace(line), "#"); ok {
+			comments = append(comments, strings.TrimSpace(text))
+		}
+	}
+	return comments
+}
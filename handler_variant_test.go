@@ -1,8 +1,10 @@
 package main
 
 import (
+	"slices"
 	"testing"
 
+	"github.com/hrko/dprint-plugin-shfmt/dprint"
 	"mvdan.cc/sh/v3/syntax"
 )
 
@@ -17,8 +19,8 @@ func TestVariantFromFilePath(t *testing.T) {
 	}{
 		{name: "sh extension", path: "script.sh", wantVariant: syntax.LangPOSIX, wantOK: true},
 		{name: "bash extension", path: "script.bash", wantVariant: syntax.LangBash, wantOK: true},
-		{name: "zsh extension uppercase", path: "script.ZSH", wantVariant: syntax.LangBash, wantOK: true},
-		{name: "bats extension", path: "test.bats", wantVariant: syntax.LangBash, wantOK: true},
+		{name: "zsh extension uppercase", path: "script.ZSH", wantVariant: langZsh, wantOK: true},
+		{name: "bats extension", path: "test.bats", wantVariant: syntax.LangBats, wantOK: true},
 		{name: "mksh extension", path: "script.mksh", wantVariant: syntax.LangMirBSDKorn, wantOK: true},
 		{name: "mksh extension uppercase", path: "script.MKSH", wantVariant: syntax.LangMirBSDKorn, wantOK: true},
 		{name: "ksh extension unsupported", path: "script.ksh", wantVariant: syntax.LangBash, wantOK: false},
@@ -57,8 +59,8 @@ func TestVariantFromShebang(t *testing.T) {
 		{name: "dash shebang", fileBytes: []byte("#!/usr/bin/dash\n"), wantVariant: syntax.LangPOSIX, wantOK: true},
 		{name: "ash shebang", fileBytes: []byte("#!/bin/ash\n"), wantVariant: syntax.LangPOSIX, wantOK: true},
 		{name: "bash shebang", fileBytes: []byte("#!/bin/bash -e\n"), wantVariant: syntax.LangBash, wantOK: true},
-		{name: "zsh shebang", fileBytes: []byte("#!/bin/zsh\n"), wantVariant: syntax.LangBash, wantOK: true},
-		{name: "bats shebang", fileBytes: []byte("#!/usr/bin/bats\n"), wantVariant: syntax.LangBash, wantOK: true},
+		{name: "zsh shebang", fileBytes: []byte("#!/bin/zsh\n"), wantVariant: langZsh, wantOK: true},
+		{name: "bats shebang", fileBytes: []byte("#!/usr/bin/bats\n"), wantVariant: syntax.LangBats, wantOK: true},
 		{name: "mksh shebang", fileBytes: []byte("#!/bin/mksh\n"), wantVariant: syntax.LangMirBSDKorn, wantOK: true},
 		{name: "mksh uppercase shebang", fileBytes: []byte("#!/BIN/MKSH\n"), wantVariant: syntax.LangMirBSDKorn, wantOK: true},
 		{name: "env mksh shebang", fileBytes: []byte("#!/usr/bin/env mksh\n"), wantVariant: syntax.LangMirBSDKorn, wantOK: true},
@@ -120,6 +122,30 @@ func TestDetectVariant(t *testing.T) {
 			fileBytes:   []byte("#!/bin/fish\necho ok\n"),
 			wantVariant: syntax.LangBash,
 		},
+		{
+			name:        "shellcheck directive wins over shebang",
+			filePath:    "script.sh",
+			fileBytes:   []byte("#!/bin/sh\n# shellcheck shell=bash\necho ok\n"),
+			wantVariant: syntax.LangBash,
+		},
+		{
+			name:        "shebang wins over modeline",
+			filePath:    "script.sh",
+			fileBytes:   []byte("#!/bin/mksh\n# vim: ft=bash\necho ok\n"),
+			wantVariant: syntax.LangMirBSDKorn,
+		},
+		{
+			name:        "modeline wins over file path",
+			filePath:    "lib.sh",
+			fileBytes:   []byte("# -*- mode: sh; sh-shell: bash -*-\necho ok\n"),
+			wantVariant: syntax.LangBash,
+		},
+		{
+			name:        "unsupported directive falls through to shebang",
+			filePath:    "script.txt",
+			fileBytes:   []byte("#!/bin/sh\n# shellcheck shell=ksh\necho ok\n"),
+			wantVariant: syntax.LangPOSIX,
+		},
 		{
 			name:        "default bash for empty path and bytes",
 			filePath:    "",
@@ -133,7 +159,235 @@ func TestDetectVariant(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			t.Parallel()
 
-			gotVariant := detectVariant(tc.filePath, tc.fileBytes)
+			gotVariant, _ := detectVariant(configuration{}, tc.filePath, tc.fileBytes)
+			if gotVariant != tc.wantVariant {
+				t.Fatalf("variant mismatch: want %v, got %v", tc.wantVariant, gotVariant)
+			}
+		})
+	}
+}
+
+func TestResolveVariant(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		config      configuration
+		filePath    string
+		fileBytes   []byte
+		wantVariant syntax.LangVariant
+	}{
+		{
+			name:        "auto detects from shebang",
+			config:      configuration{Variant: variantAuto},
+			filePath:    "script.sh",
+			fileBytes:   []byte("#!/bin/bash\n"),
+			wantVariant: syntax.LangBash,
+		},
+		{
+			name:        "explicit variant wins over detection",
+			config:      configuration{Variant: "bash"},
+			filePath:    "script.sh",
+			fileBytes:   []byte("#!/bin/sh\n"),
+			wantVariant: syntax.LangBash,
+		},
+		{
+			name: "matching override wins over variant",
+			config: configuration{
+				Variant:   "bash",
+				Overrides: []variantOverride{{Files: "scripts/legacy/**", Variant: "posix"}},
+			},
+			filePath:    "/work/repo/scripts/legacy/old/run.sh",
+			wantVariant: syntax.LangPOSIX,
+		},
+		{
+			name: "last matching override wins",
+			config: configuration{
+				Overrides: []variantOverride{
+					{Files: "*.sh", Variant: "posix"},
+					{Files: "*.sh", Variant: "mksh"},
+				},
+			},
+			filePath:    "/work/repo/run.sh",
+			wantVariant: syntax.LangMirBSDKorn,
+		},
+		{
+			name: "auto override falls back to detection",
+			config: configuration{
+				Variant:   "posix",
+				Overrides: []variantOverride{{Files: "*.bash", Variant: variantAuto}},
+			},
+			filePath:    "run.bash",
+			wantVariant: syntax.LangBash,
+		},
+		{
+			name: "non-matching override is ignored",
+			config: configuration{
+				Variant:   "mksh",
+				Overrides: []variantOverride{{Files: "scripts/legacy/**", Variant: "posix"}},
+			},
+			filePath:    "/work/repo/scripts/run.sh",
+			wantVariant: syntax.LangMirBSDKorn,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			gotVariant, _ := resolveVariant(tc.config, tc.filePath, tc.fileBytes)
+			if gotVariant != tc.wantVariant {
+				t.Fatalf("variant mismatch: want %v, got %v", tc.wantVariant, gotVariant)
+			}
+		})
+	}
+}
+
+func TestMatchFileGlob(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		pattern  string
+		filePath string
+		want     bool
+	}{
+		{pattern: "*.sh", filePath: "/repo/scripts/run.sh", want: true},
+		{pattern: "*.sh", filePath: "/repo/scripts/run.bash", want: false},
+		{pattern: "scripts/*.sh", filePath: "/repo/scripts/run.sh", want: true},
+		{pattern: "scripts/*.sh", filePath: "/repo/scripts/nested/run.sh", want: false},
+		{pattern: "scripts/**", filePath: "/repo/scripts/nested/run.sh", want: true},
+		{pattern: "scripts/**/*.sh", filePath: "/repo/scripts/run.sh", want: true},
+		{pattern: "./scripts/*.sh", filePath: "scripts/run.sh", want: true},
+		{pattern: "/repo/*.sh", filePath: "/repo/run.sh", want: true},
+		{pattern: "/scripts/*.sh", filePath: "/repo/scripts/run.sh", want: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.pattern+" "+tc.filePath, func(t *testing.T) {
+			t.Parallel()
+
+			if got := matchFileGlob(tc.pattern, tc.filePath); got != tc.want {
+				t.Fatalf("want %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestResolveVariantConfig(t *testing.T) {
+	t.Parallel()
+
+	h := &handler{}
+	result := h.ResolveConfig(
+		dprint.ConfigKeyMap{
+			"variant": "bash",
+			"overrides": []any{
+				map[string]any{"files": "scripts/legacy/**", "variant": "posix"},
+				map[string]any{"files": "*.sh", "variant": "fish"},
+				map[string]any{"files": "[", "variant": "bash"},
+				map[string]any{"files": "*.bats", "variant": "bats", "indentWidth": int64(4)},
+				"*.sh",
+			},
+		},
+		dprint.GlobalConfiguration{},
+	)
+
+	if result.Config.Variant != "bash" {
+		t.Fatalf("unexpected variant: %q", result.Config.Variant)
+	}
+	wantOverrides := []variantOverride{{Files: "scripts/legacy/**", Variant: "posix"}}
+	if len(result.Config.Overrides) != len(wantOverrides) || result.Config.Overrides[0] != wantOverrides[0] {
+		t.Fatalf("unexpected overrides: %#v", result.Config.Overrides)
+	}
+	if len(result.Diagnostics) != 4 {
+		t.Fatalf("expected 4 diagnostics, got %#v", result.Diagnostics)
+	}
+	wantMessage := "Expected 'overrides[2].files' to be a non-empty glob pattern, but got \"[\"."
+	if !slices.ContainsFunc(result.Diagnostics, func(diagnostic dprint.ConfigurationDiagnostic) bool {
+		return diagnostic["message"] == wantMessage
+	}) {
+		t.Fatalf("expected a diagnostic with the override path, got %#v", result.Diagnostics)
+	}
+
+	result = h.ResolveConfig(dprint.ConfigKeyMap{"variant": "fish"}, dprint.GlobalConfiguration{})
+	if result.Config.Variant != variantAuto {
+		t.Fatalf("expected fallback to auto, got %q", result.Config.Variant)
+	}
+	want := "Expected 'variant' to be one of 'auto', 'posix', 'bash', 'mksh', 'bats', 'zsh', but got \"fish\"."
+	if len(result.Diagnostics) != 1 || result.Diagnostics[0]["message"] != want {
+		t.Fatalf("unexpected diagnostics: %#v", result.Diagnostics)
+	}
+}
+
+func TestVariantFromShellCheckDirective(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		fileBytes   []byte
+		wantVariant syntax.LangVariant
+		wantOK      bool
+	}{
+		{name: "no directive", fileBytes: []byte("# library\necho ok\n"), wantVariant: syntax.LangBash, wantOK: false},
+		{name: "bash directive", fileBytes: []byte("# shellcheck shell=bash\n"), wantVariant: syntax.LangBash, wantOK: true},
+		{name: "sh directive after shebang", fileBytes: []byte("#!/usr/bin/env bash\n#shellcheck shell=sh\n"), wantVariant: syntax.LangPOSIX, wantOK: true},
+		{name: "dash directive with other keys", fileBytes: []byte("# shellcheck disable=SC2034 shell=dash\n"), wantVariant: syntax.LangPOSIX, wantOK: true},
+		{name: "directive after other comments", fileBytes: []byte("# Helpers.\n\n# shellcheck shell=mksh\n"), wantVariant: syntax.LangMirBSDKorn, wantOK: true},
+		{name: "directive after code is ignored", fileBytes: []byte("echo ok\n# shellcheck shell=bash\n"), wantVariant: syntax.LangBash, wantOK: false},
+		{name: "unsupported shell", fileBytes: []byte("# shellcheck shell=ksh\n"), wantVariant: syntax.LangBash, wantOK: false},
+		{name: "other directive", fileBytes: []byte("# shellcheck source=lib.sh\n"), wantVariant: syntax.LangBash, wantOK: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			gotVariant, gotOK := variantFromShellCheckDirective(tc.fileBytes)
+			if gotOK != tc.wantOK {
+				t.Fatalf("ok mismatch: want %v, got %v", tc.wantOK, gotOK)
+			}
+			if gotVariant != tc.wantVariant {
+				t.Fatalf("variant mismatch: want %v, got %v", tc.wantVariant, gotVariant)
+			}
+		})
+	}
+}
+
+func TestVariantFromModeline(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		fileBytes   []byte
+		wantVariant syntax.LangVariant
+		wantOK      bool
+	}{
+		{name: "no modeline", fileBytes: []byte("# library\necho ok\n"), wantVariant: syntax.LangBash, wantOK: false},
+		{name: "vim ft", fileBytes: []byte("# vim: ft=sh\n"), wantVariant: syntax.LangPOSIX, wantOK: true},
+		{name: "vim set form", fileBytes: []byte("# vim: set filetype=bash ts=2 :\n"), wantVariant: syntax.LangBash, wantOK: true},
+		{name: "vi with colon separators", fileBytes: []byte("# vi:ts=4:ft=mksh\n"), wantVariant: syntax.LangMirBSDKorn, wantOK: true},
+		{name: "vim without filetype", fileBytes: []byte("# vim: ts=2 sw=2\n"), wantVariant: syntax.LangBash, wantOK: false},
+		{name: "trailing vim modeline", fileBytes: []byte("echo a\necho b\n# vim: ft=sh\n"), wantVariant: syntax.LangPOSIX, wantOK: true},
+		{
+			name:        "vim modeline beyond last lines is ignored",
+			fileBytes:   []byte("echo a\n# vim: ft=sh\necho b\necho c\necho d\necho e\necho f\n"),
+			wantVariant: syntax.LangBash,
+			wantOK:      false,
+		},
+		{name: "emacs sh-shell", fileBytes: []byte("# -*- mode: sh; sh-shell: bash -*-\n"), wantVariant: syntax.LangBash, wantOK: true},
+		{name: "emacs mode only", fileBytes: []byte("# -*- mode: sh -*-\n"), wantVariant: syntax.LangPOSIX, wantOK: true},
+		{name: "emacs short form", fileBytes: []byte("#!/bin/false\n# -*- sh -*-\n"), wantVariant: syntax.LangPOSIX, wantOK: true},
+		{name: "emacs shell-script mode", fileBytes: []byte("# -*- mode: shell-script -*-\n"), wantVariant: syntax.LangBash, wantOK: false},
+		{name: "emacs after code is ignored", fileBytes: []byte("echo ok\n\n\n\n\n\n# -*- sh -*-\n"), wantVariant: syntax.LangBash, wantOK: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			gotVariant, gotOK := variantFromModeline(tc.fileBytes)
+			if gotOK != tc.wantOK {
+				t.Fatalf("ok mismatch: want %v, got %v", tc.wantOK, gotOK)
+			}
 			if gotVariant != tc.wantVariant {
 				t.Fatalf("variant mismatch: want %v, got %v", tc.wantVariant, gotVariant)
 			}
@@ -0,0 +1,324 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hrko/dprint-plugin-shfmt/dprint"
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// verifyHint tells users what a verify failure means for their file.
+const verifyHint = "the file was left unchanged; this is a bug in the formatter, please report it"
+
+// verifyFormatted parses formatted with parser and checks that it yields the
+// same syntax tree as prog. Positions and comments are ignored, since
+// reformatting is expected to move them. A difference is reported as a
+// diagnostic spanning the innermost node of prog containing it.
+func verifyFormatted(parser *syntax.Parser, prog *syntax.File, formatted []byte) error {
+	formattedProg, err := parser.Parse(bytes.NewReader(formatted), prog.Name)
+	if err != nil {
+		// Positions in the formatted output mean nothing to the user.
+		return &dprint.Diagnostic{
+			FilePath: prog.Name,
+			Severity: dprint.SeverityError,
+			Code:     codeVerifyFailed,
+			Message:  fmt.Sprintf("formatted output does not parse: %v", err),
+			Hint:     verifyHint,
+		}
+	}
+
+	err = compareSyntax(syntaxNodes(prog), syntaxNodes(formattedProg))
+	var diagnostic *dprint.Diagnostic
+	if errors.As(err, &diagnostic) {
+		diagnostic.FilePath = prog.Name
+	}
+	return err
+}
+
+// syntaxNode is a node of a syntax tree with the fields compared by
+// verifyFormatted.
+type syntaxNode struct {
+	node   syntax.Node
+	fields []syntaxField
+}
+
+// syntaxField is a field of a node: a scalar value, the type of a child node,
+// or the length of a list of children.
+type syntaxField struct {
+	name  string
+	value string
+	count int
+	list  bool
+}
+
+// syntaxNodes returns the nodes of prog in the order syntax.Walk visits them,
+// leaving out comments. The fields of each node include the types of its
+// children and the lengths of its lists, so two trees whose nodes all have the
+// same fields are visited in step. Within <<- heredocs, the leading tabs the
+// shell strips from each line are left out of the literals.
+func syntaxNodes(prog *syntax.File) []syntaxNode {
+	var nodes []syntaxNode
+	dashLits := map[*syntax.Lit]bool{}
+	var visit func(node syntax.Node) bool
+	visit = func(node syntax.Node) bool {
+		switch node := node.(type) {
+		case nil, *syntax.Comment:
+			return true
+		case *syntax.Redirect:
+			if node.Op == syntax.DashHdoc && node.Hdoc != nil {
+				syntax.Walk(node.Hdoc, func(node syntax.Node) bool {
+					if lit, ok := node.(*syntax.Lit); ok {
+						dashLits[lit] = true
+					}
+					return true
+				})
+			}
+		}
+		nodes = append(nodes, syntaxNode{node: node, fields: syntaxFields(node, dashLits)})
+
+		// syntax.Walk does not visit the bounds of ${a:offset:length}.
+		if node, ok := node.(*syntax.ParamExp); ok && node.Slice != nil {
+			if node.Slice.Offset != nil {
+				syntax.Walk(node.Slice.Offset, visit)
+			}
+			if node.Slice.Length != nil {
+				syntax.Walk(node.Slice.Length, visit)
+			}
+		}
+		return true
+	}
+	syntax.Walk(prog, visit)
+	return nodes
+}
+
+// syntaxFields returns the fields of node that carry meaning. Positions,
+// comments and the fields that only affect how a node is written, such as
+// ${var} printed as $var or backquotes printed as $(...), are left out.
+func syntaxFields(node syntax.Node, dashLits map[*syntax.Lit]bool) []syntaxField {
+	var fields []syntaxField
+	value := func(name string, value any) {
+		text, ok := value.(string)
+		if ok {
+			text = strconv.Quote(text)
+		} else {
+			text = fmt.Sprint(value)
+		}
+		fields = append(fields, syntaxField{name: name, value: text})
+	}
+	list := func(name string, count int) {
+		fields = append(fields, syntaxField{name: name, count: count, list: true})
+	}
+	child := func(name string, present bool, node syntax.Node) {
+		text := "nothing"
+		if present {
+			text = fmt.Sprintf("%T", node)
+		}
+		fields = append(fields, syntaxField{name: name, value: text})
+	}
+
+	switch node := node.(type) {
+	case *syntax.File:
+		list("Stmts", len(node.Stmts))
+	case *syntax.Stmt:
+		child("Cmd", node.Cmd != nil, node.Cmd)
+		value("Negated", node.Negated)
+		value("Background", node.Background)
+		value("Coprocess", node.Coprocess)
+		list("Redirs", len(node.Redirs))
+	case *syntax.Assign:
+		value("Append", node.Append)
+		value("Naked", node.Naked)
+		child("Name", node.Name != nil, node.Name)
+		child("Index", node.Index != nil, node.Index)
+		child("Value", node.Value != nil, node.Value)
+		child("Array", node.Array != nil, node.Array)
+	case *syntax.Redirect:
+		value("Op", node.Op)
+		child("N", node.N != nil, node.N)
+		child("Hdoc", node.Hdoc != nil, node.Hdoc)
+	case *syntax.CallExpr:
+		list("Assigns", len(node.Assigns))
+		list("Args", len(node.Args))
+	case *syntax.Subshell:
+		list("Stmts", len(node.Stmts))
+	case *syntax.Block:
+		list("Stmts", len(node.Stmts))
+	case *syntax.IfClause:
+		list("Cond", len(node.Cond))
+		list("Then", len(node.Then))
+		child("Else", node.Else != nil, node.Else)
+	case *syntax.WhileClause:
+		value("Until", node.Until)
+		list("Cond", len(node.Cond))
+		list("Do", len(node.Do))
+	case *syntax.ForClause:
+		value("Select", node.Select)
+		value("Braces", node.Braces)
+		child("Loop", true, node.Loop)
+		list("Do", len(node.Do))
+	case *syntax.WordIter:
+		list("Items", len(node.Items))
+	case *syntax.CStyleLoop:
+		child("Init", node.Init != nil, node.Init)
+		child("Cond", node.Cond != nil, node.Cond)
+		child("Post", node.Post != nil, node.Post)
+	case *syntax.BinaryCmd:
+		value("Op", node.Op)
+	case *syntax.FuncDecl:
+		value("RsrvWord", node.RsrvWord)
+		value("Parens", node.Parens)
+	case *syntax.Word:
+		list("Parts", len(node.Parts))
+		for i, part := range node.Parts {
+			child(fmt.Sprintf("Parts[%d]", i), true, part)
+		}
+	case *syntax.Lit:
+		if dashLits[node] {
+			value("Value", stripHeredocTabs(node.Value))
+		} else {
+			value("Value", node.Value)
+		}
+	case *syntax.SglQuoted:
+		value("Dollar", node.Dollar)
+		value("Value", node.Value)
+	case *syntax.DblQuoted:
+		value("Dollar", node.Dollar)
+		list("Parts", len(node.Parts))
+		for i, part := range node.Parts {
+			child(fmt.Sprintf("Parts[%d]", i), true, part)
+		}
+	case *syntax.CmdSubst:
+		value("TempFile", node.TempFile)
+		value("ReplyVar", node.ReplyVar)
+		list("Stmts", len(node.Stmts))
+	case *syntax.ParamExp:
+		value("Excl", node.Excl)
+		value("Length", node.Length)
+		value("Width", node.Width)
+		value("Names", node.Names)
+		child("Param", node.Param != nil, node.Param)
+		child("Index", node.Index != nil, node.Index)
+		value("Slice", node.Slice != nil)
+		if node.Slice != nil {
+			child("Slice.Offset", node.Slice.Offset != nil, node.Slice.Offset)
+			child("Slice.Length", node.Slice.Length != nil, node.Slice.Length)
+		}
+		value("Repl", node.Repl != nil)
+		if node.Repl != nil {
+			value("Repl.All", node.Repl.All)
+			child("Repl.Orig", node.Repl.Orig != nil, node.Repl.Orig)
+			child("Repl.With", node.Repl.With != nil, node.Repl.With)
+		}
+		value("Exp", node.Exp != nil)
+		if node.Exp != nil {
+			value("Exp.Op", node.Exp.Op)
+			child("Exp.Word", node.Exp.Word != nil, node.Exp.Word)
+		}
+	case *syntax.ArithmExp:
+		value("Bracket", node.Bracket)
+		value("Unsigned", node.Unsigned)
+		child("X", true, node.X)
+	case *syntax.ArithmCmd:
+		value("Unsigned", node.Unsigned)
+		child("X", true, node.X)
+	case *syntax.BinaryArithm:
+		value("Op", node.Op)
+		child("X", true, node.X)
+		child("Y", true, node.Y)
+	case *syntax.UnaryArithm:
+		value("Op", node.Op)
+		value("Post", node.Post)
+		child("X", true, node.X)
+	case *syntax.ParenArithm:
+		child("X", true, node.X)
+	case *syntax.CaseClause:
+		value("Braces", node.Braces)
+		list("Items", len(node.Items))
+	case *syntax.CaseItem:
+		value("Op", node.Op)
+		list("Patterns", len(node.Patterns))
+		list("Stmts", len(node.Stmts))
+	case *syntax.TestClause:
+		child("X", true, node.X)
+	case *syntax.BinaryTest:
+		value("Op", node.Op)
+		child("X", true, node.X)
+		child("Y", true, node.Y)
+	case *syntax.UnaryTest:
+		value("Op", node.Op)
+		child("X", true, node.X)
+	case *syntax.ParenTest:
+		child("X", true, node.X)
+	case *syntax.DeclClause:
+		child("Variant", node.Variant != nil, node.Variant)
+		list("Args", len(node.Args))
+	case *syntax.ArrayExpr:
+		list("Elems", len(node.Elems))
+	case *syntax.ArrayElem:
+		child("Index", node.Index != nil, node.Index)
+		child("Value", node.Value != nil, node.Value)
+	case *syntax.ExtGlob:
+		value("Op", node.Op)
+	case *syntax.ProcSubst:
+		value("Op", node.Op)
+		list("Stmts", len(node.Stmts))
+	case *syntax.TimeClause:
+		value("PosixFormat", node.PosixFormat)
+		child("Stmt", node.Stmt != nil, node.Stmt)
+	case *syntax.CoprocClause:
+		child("Name", node.Name != nil, node.Name)
+	case *syntax.LetClause:
+		list("Exprs", len(node.Exprs))
+		for i, expr := range node.Exprs {
+			child(fmt.Sprintf("Exprs[%d]", i), true, expr)
+		}
+	}
+	return fields
+}
+
+// compareSyntax compares the nodes of two trees in step and returns a
+// diagnostic at the innermost node of want containing the first difference.
+func compareSyntax(want []syntaxNode, got []syntaxNode) error {
+	for i, wantNode := range want {
+		if i >= len(got) {
+			return divergence(wantNode.node, "%T is missing", wantNode.node)
+		}
+		gotNode := got[i]
+		path := fmt.Sprintf("%T", wantNode.node)
+		if gotType := fmt.Sprintf("%T", gotNode.node); gotType != path || len(gotNode.fields) != len(wantNode.fields) {
+			return divergence(wantNode.node, "%s changed to %s", path, gotType)
+		}
+		for j, wantField := range wantNode.fields {
+			gotField := gotNode.fields[j]
+			switch {
+			case wantField.list && wantField.count != gotField.count:
+				return divergence(wantNode.node, "%s.%s has %d elements instead of %d", path, wantField.name, gotField.count, wantField.count)
+			case wantField.value != gotField.value:
+				return divergence(wantNode.node, "%s.%s changed from %s to %s", path, wantField.name, wantField.value, gotField.value)
+			}
+		}
+	}
+	if len(got) > len(want) {
+		return divergence(want[0].node, "formatted output has %d more nodes", len(got)-len(want))
+	}
+	return nil
+}
+
+func divergence(node syntax.Node, format string, args ...any) error {
+	diagnostic := nodeDiagnostic("", node, codeVerifyFailed, "formatted output is not equivalent to the input: "+format, args...)
+	diagnostic.Hint = verifyHint
+	return diagnostic
+}
+
+// stripHeredocTabs removes the tabs starting each line of a <<- heredoc body.
+func stripHeredocTabs(value string) string {
+	lines := strings.SplitAfter(value, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimLeft(line, "\t")
+	}
+	return strings.Join(lines, "")
+}
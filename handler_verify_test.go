@@ -0,0 +1,185 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/hrko/dprint-plugin-shfmt/dprint"
+	"mvdan.cc/sh/v3/syntax"
+)
+
+func TestVerifyFormattedAcceptsEquivalentOutput(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		input     string
+		formatted string
+	}{
+		{name: "layout and comments", input: "if true;then # note\necho   a\nfi\n", formatted: "if true; then\n  echo a\nfi\n"},
+		{name: "short parameter expansion", input: "echo ${a}\n", formatted: "echo $a\n"},
+		{name: "backquotes", input: "echo `date`\n", formatted: "echo $(date)\n"},
+		{name: "slice bounds", input: "echo ${a:1:n+1}\n", formatted: "echo ${a:1:n + 1}\n"},
+		{name: "dash heredoc indentation", input: "f() {\ncat <<-EOF\n\tbody\nEOF\n}\n", formatted: "f() {\n\tcat <<-EOF\n\t\tbody\n\tEOF\n}\n"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			parser := syntax.NewParser(syntax.KeepComments(true))
+			prog, err := parser.Parse(strings.NewReader(tc.input), "")
+			if err != nil {
+				t.Fatalf("failed to parse input: %v", err)
+			}
+			if err := verifyFormatted(parser, prog, []byte(tc.formatted)); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestVerifyFormattedReportsFirstDifference(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		input     string
+		formatted string
+		wantStart dprint.Position
+		wantEnd   dprint.Position
+		want      string
+	}{
+		{
+			name:      "changed literal",
+			input:     "echo a\necho b\n",
+			formatted: "echo a\necho c\n",
+			wantStart: dprint.Position{Line: 2, Column: 6},
+			wantEnd:   dprint.Position{Line: 2, Column: 7},
+			want:      "formatted output is not equivalent to the input: *syntax.Lit.Value changed from \"b\" to \"c\"",
+		},
+		{
+			name:      "dropped statement",
+			input:     "f() {\n  echo a\n  echo b\n}\n",
+			formatted: "f() {\n  echo a\n}\n",
+			wantStart: dprint.Position{Line: 1, Column: 5},
+			wantEnd:   dprint.Position{Line: 4, Column: 2},
+			want:      "formatted output is not equivalent to the input: *syntax.Block.Stmts has 1 elements instead of 2",
+		},
+		{
+			name:      "changed node type",
+			input:     "echo \"$a\"\n",
+			formatted: "echo '$a'\n",
+			wantStart: dprint.Position{Line: 1, Column: 6},
+			wantEnd:   dprint.Position{Line: 1, Column: 10},
+			want:      "formatted output is not equivalent to the input: *syntax.Word.Parts[0] changed from *syntax.DblQuoted to *syntax.SglQuoted",
+		},
+		{
+			name:      "changed operator",
+			input:     "a && b\n",
+			formatted: "a || b\n",
+			wantStart: dprint.Position{Line: 1, Column: 1},
+			wantEnd:   dprint.Position{Line: 1, Column: 7},
+			want:      "formatted output is not equivalent to the input: *syntax.BinaryCmd.Op changed from && to ||",
+		},
+		{
+			name:      "changed slice bound",
+			input:     "echo ${a:1:2}\n",
+			formatted: "echo ${a:3:2}\n",
+			wantStart: dprint.Position{Line: 1, Column: 10},
+			wantEnd:   dprint.Position{Line: 1, Column: 11},
+			want:      "formatted output is not equivalent to the input: *syntax.Lit.Value changed from \"1\" to \"3\"",
+		},
+		{
+			name:      "unparsable output",
+			input:     "echo a\n",
+			formatted: "echo (\n",
+			want:      "formatted output does not parse: 1:1: \"foo(\" must be followed by )",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			parser := syntax.NewParser(syntax.KeepComments(true))
+			prog, err := parser.Parse(strings.NewReader(tc.input), "")
+			if err != nil {
+				t.Fatalf("failed to parse input: %v", err)
+			}
+			err = verifyFormatted(parser, prog, []byte(tc.formatted))
+			var diagnostic *dprint.Diagnostic
+			if !errors.As(err, &diagnostic) {
+				t.Fatalf("expected a diagnostic, got %T: %v", err, err)
+			}
+			if diagnostic.Code != codeVerifyFailed || diagnostic.Message != tc.want {
+				t.Fatalf("unexpected diagnostic:\nwant %s [%s]\ngot  %s [%s]", tc.want, codeVerifyFailed, diagnostic.Message, diagnostic.Code)
+			}
+			if diagnostic.Start != tc.wantStart || diagnostic.End != tc.wantEnd {
+				t.Fatalf("unexpected span: want %v-%v, got %v-%v", tc.wantStart, tc.wantEnd, diagnostic.Start, diagnostic.End)
+			}
+		})
+	}
+}
+
+func TestFormatVerifiesEveryConstruct(t *testing.T) {
+	t.Parallel()
+
+	input := `#!/bin/bash
+declare -A map=([a]=1 [b]=2)
+local x+=y z
+f() { echo "${a:-b}" "${#a}" "${!a*}" "${a/b/c}" "${a//b}" "${a:1:2}" "${a[i+1]}"; }
+function g { (cd / && ls) || true; }
+if [[ -n $a && ! -f "$b" ]]; then echo $((a + b++ * -c)); elif ((x)); then :; else let y=1 z+=2; fi
+for ((i = 0; i < 3; i++)); do echo $i; done
+for x in a b; do continue; done
+select y in a b; do break; done
+while ! false; do cat <<-EOF >&2 2>/dev/null
+	$x $(date) ` + "`pwd`" + `
+	EOF
+done
+until true; do diff <(ls) >(cat); done
+case $a in a | b) echo 'x' ;; *) echo $'y\n' ;& c) ;; esac
+time -p sleep 1 &
+coproc worker { read -r line; }
+echo @(a|b) {a,b}
+`
+	h := &handler{}
+	result := h.Format(
+		dprint.SyncFormatRequest[configuration]{
+			FilePath:  "sample.bash",
+			FileBytes: []byte(input),
+			Config:    configuration{IndentWidth: 2, Verify: true, Simplify: true},
+		},
+		nil,
+	)
+	if result.Code == dprint.FormatResultError {
+		t.Fatalf("unexpected error: %v", result.Err)
+	}
+}
+
+func TestFormatVerifiesOutput(t *testing.T) {
+	t.Parallel()
+
+	h := &handler{}
+	result := h.Format(
+		dprint.SyncFormatRequest[configuration]{
+			FilePath:  "sample.zsh",
+			FileBytes: []byte("print -r -- ${(j:,:)a}   `date`\n"),
+			Config: configuration{
+				IndentWidth: 2,
+				Verify:      true,
+				Minify:      true,
+			},
+		},
+		nil,
+	)
+
+	if result.Code != dprint.FormatResultChange {
+		t.Fatalf("expected change result, got %d (err: %v)", result.Code, result.Err)
+	}
+	if want := "print -r -- ${(j:,:)a} $(date)\n"; string(result.Text) != want {
+		t.Fatalf("unexpected output:\nwant %q\ngot  %q", want, string(result.Text))
+	}
+}
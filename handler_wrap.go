@@ -0,0 +1,477 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// Ranks of line breaks, from the most to the least preferred. A break of a
+// later rank is only used when no break of an earlier rank fits.
+const (
+	breakNormal = iota
+	// breakAfterCommand separates a command name from its first argument.
+	breakAfterCommand
+	// breakAfterOption separates an option from its value.
+	breakAfterOption
+)
+
+// lineBreak is a place where a long line may be split. The space at offset is
+// replaced with suffix, a newline and the continuation indentation. A break
+// after an operator on a line that opens heredocs ends that line, so bodies,
+// the byte range of the heredoc bodies and closing delimiters following the
+// line, is moved to just after the break.
+type lineBreak struct {
+	line     uint
+	offset   uint
+	suffix   string
+	rank     int
+	operator bool
+	bodies   [2]uint
+}
+
+// wrapLongLines splits lines of formatted wider than config.LineWidth between
+// the words of simple commands, for loop word lists and array literals, before
+// redirections, and around the operators of pipelines and command lists.
+// Breaks are never placed inside quotes, heredocs, comments, expansions or the
+// conditions of if, while and until clauses. Each of placeholders, the zsh
+// placeholders in formatted, is measured as the code it stands for.
+//
+// The printer keeps the line breaks it is given, but decides how continuation
+// lines are indented, so the output is printed again after breaking lines and
+// the lines it indents further are checked once more. Every round adds at
+// least one break, so there are never more rounds than the first round had
+// candidate breaks.
+func wrapLongLines(
+	parser *syntax.Parser,
+	printer *syntax.Printer,
+	formatted []byte,
+	placeholders map[string]string,
+	config configuration,
+) ([]byte, error) {
+	if config.LineWidth == 0 || config.Minify {
+		return formatted, nil
+	}
+
+	maxRounds := -1
+	for round := 0; round != maxRounds; round++ {
+		prog, err := parser.Parse(bytes.NewReader(formatted), "")
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse formatted output to wrap long lines: %w", err)
+		}
+
+		candidates := lineBreaks(prog, formatted, config.BinaryNextLine)
+		if maxRounds < 0 {
+			maxRounds = len(candidates)
+		}
+		breaks := chooseLineBreaks(formatted, candidates, placeholders, config)
+		if len(breaks) == 0 {
+			return formatted, nil
+		}
+
+		wrapped := insertLineBreaks(formatted, breaks, config)
+		prog, err = parser.Parse(bytes.NewReader(wrapped), "")
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse wrapped output: %w", err)
+		}
+		var buffer bytes.Buffer
+		if err := printer.Print(&buffer, prog); err != nil {
+			return nil, err
+		}
+		if bytes.Equal(buffer.Bytes(), formatted) {
+			return formatted, nil
+		}
+		formatted = buffer.Bytes()
+	}
+	return formatted, nil
+}
+
+// lineBreaks returns every place where prog may be split across lines, in
+// source order.
+func lineBreaks(prog *syntax.File, text []byte, binaryNextLine bool) []lineBreak {
+	var breaks []lineBreak
+	var protected [][2]uint // byte offset ranges no break may fall in
+	var heredocs []*syntax.Redirect
+
+	// addBefore breaks the line at the space preceding pos.
+	addBefore := func(pos syntax.Pos, rank int) {
+		offset := pos.Offset()
+		if offset > 0 && text[offset-1] == ' ' {
+			breaks = append(breaks, lineBreak{line: pos.Line(), offset: offset - 1, suffix: " \\", rank: rank})
+		}
+	}
+
+	syntax.Walk(prog, func(node syntax.Node) bool {
+		switch node := node.(type) {
+		case *syntax.Stmt:
+			for _, redirect := range node.Redirs {
+				if redirect.Hdoc == nil {
+					addBefore(redirect.Pos(), breakNormal)
+				}
+			}
+		case *syntax.WordIter:
+			for _, word := range node.Items[min(1, len(node.Items)):] {
+				addBefore(word.Pos(), breakNormal)
+			}
+		case *syntax.ArrayExpr:
+			// Array literals may span lines without continuations.
+			for _, element := range node.Elems[min(1, len(node.Elems)):] {
+				offset := element.Pos().Offset()
+				if offset > 0 && text[offset-1] == ' ' {
+					breaks = append(breaks, lineBreak{line: element.Pos().Line(), offset: offset - 1})
+				}
+			}
+		case *syntax.CallExpr:
+			for i, assign := range node.Assigns {
+				if i > 0 {
+					addBefore(assign.Pos(), breakNormal)
+				}
+			}
+			for i, word := range node.Args {
+				switch {
+				case i == 1:
+					addBefore(word.Pos(), breakAfterCommand)
+				case i > 0 && isOption(node.Args[i-1]) && !isOption(word):
+					addBefore(word.Pos(), breakAfterOption)
+				case i > 0:
+					addBefore(word.Pos(), breakNormal)
+				case len(node.Assigns) > 0:
+					addBefore(word.Pos(), breakNormal)
+				}
+			}
+		case *syntax.DeclClause:
+			for _, assign := range node.Args {
+				addBefore(assign.Pos(), breakNormal)
+			}
+		case *syntax.BinaryCmd:
+			switch node.Op {
+			case syntax.AndStmt, syntax.OrStmt, syntax.Pipe, syntax.PipeAll:
+			default:
+				return true
+			}
+			offset, suffix := node.OpPos.Offset()+uint(len(node.Op.String())), ""
+			if binaryNextLine {
+				offset, suffix = node.OpPos.Offset()-1, " \\"
+			}
+			if offset < uint(len(text)) && text[offset] == ' ' {
+				breaks = append(breaks, lineBreak{line: node.OpPos.Line(), offset: offset, suffix: suffix, operator: true})
+			}
+		case *syntax.IfClause:
+			protected = append(protected, stmtsSpan(node.Cond))
+		case *syntax.WhileClause:
+			protected = append(protected, stmtsSpan(node.Cond))
+		case *syntax.DblQuoted, *syntax.ParamExp, *syntax.ArithmExp:
+			protected = append(protected, [2]uint{node.Pos().Offset(), node.End().Offset()})
+		case *syntax.CmdSubst:
+			if node.Backquotes {
+				protected = append(protected, [2]uint{node.Pos().Offset(), node.End().Offset()})
+			}
+		case *syntax.Redirect:
+			if node.Op == syntax.Hdoc || node.Op == syntax.DashHdoc {
+				heredocs = append(heredocs, node)
+			}
+			if node.Hdoc != nil {
+				protected = append(protected, [2]uint{node.Hdoc.Pos().Offset(), node.Hdoc.End().Offset()})
+			}
+		}
+		return true
+	})
+
+	breaks = slices.DeleteFunc(breaks, func(lineBreak lineBreak) bool {
+		for _, span := range protected {
+			if span[0] <= lineBreak.offset && lineBreak.offset < span[1] {
+				return true
+			}
+		}
+		return false
+	})
+
+	// Heredoc bodies start after the line that opens them, so a break after
+	// an operator there brings the bodies along. The printer joins lines
+	// broken before an operator there, and other breaks without a
+	// continuation would move words into the bodies, so those are dropped.
+	pending := pendingHeredocs(text, heredocs)
+	breaks = slices.DeleteFunc(breaks, func(lineBreak lineBreak) bool {
+		line := pendingAt(pending, lineBreak.offset)
+		if line == nil {
+			return false
+		}
+		if lineBreak.operator {
+			return lineBreak.suffix != "" || line.bodies[1] == 0
+		}
+		return lineBreak.suffix == ""
+	})
+	for i := range breaks {
+		if line := pendingAt(pending, breaks[i].offset); line != nil && breaks[i].operator {
+			breaks[i].bodies = line.bodies
+		}
+	}
+	sort.Slice(breaks, func(i, j int) bool {
+		return breaks[i].offset < breaks[j].offset
+	})
+	return breaks
+}
+
+// heredocLine is the part of a line from its first heredoc operator to the
+// line break its heredoc bodies follow, and the byte range of those bodies
+// with their closing delimiters. The end of bodies is zero when a delimiter
+// could not be found.
+type heredocLine struct {
+	span   [2]uint
+	bodies [2]uint
+}
+
+// pendingHeredocs returns the lines of text that open heredocs, in source
+// order.
+func pendingHeredocs(text []byte, heredocs []*syntax.Redirect) []heredocLine {
+	sort.Slice(heredocs, func(i, j int) bool {
+		return heredocs[i].Pos().Offset() < heredocs[j].Pos().Offset()
+	})
+	var lines []heredocLine
+	for _, redirect := range heredocs {
+		if n := len(lines); n > 0 && redirect.Pos().Offset() < lines[n-1].span[1] {
+			// The bodies of later heredocs on a line follow the earlier ones.
+			if lines[n-1].bodies[1] != 0 {
+				lines[n-1].bodies[1] = heredocBodyEnd(text, lines[n-1].bodies[1], redirect)
+			}
+			continue
+		}
+
+		var end uint
+		if redirect.Hdoc != nil {
+			end = lineStartOffset(lineOffsets(text), redirect.Hdoc.Pos().Line()) - 1
+		} else {
+			end = redirect.Word.End().Offset()
+			for end < uint(len(text)) && (text[end] != '\n' || text[end-1] == '\\') {
+				end++
+			}
+		}
+		if end >= uint(len(text)) {
+			continue
+		}
+		lines = append(lines, heredocLine{
+			span:   [2]uint{redirect.Pos().Offset(), end},
+			bodies: [2]uint{end + 1, heredocBodyEnd(text, end+1, redirect)},
+		})
+	}
+	return lines
+}
+
+// heredocBodyEnd returns the offset just past the closing delimiter line of
+// redirect, whose body starts at start, or zero when there is none.
+func heredocBodyEnd(text []byte, start uint, redirect *syntax.Redirect) uint {
+	delimiter, _ := heredocDelimiter(redirect.Word)
+	if delimiter == "" {
+		return 0
+	}
+	for offset := start; offset < uint(len(text)); {
+		line, _, _ := bytes.Cut(text[offset:], []byte("\n"))
+		next := offset + uint(len(line)) + 1
+		line = bytes.TrimSuffix(line, []byte("\r"))
+		if redirect.Op == syntax.DashHdoc {
+			line = bytes.TrimLeft(line, "\t")
+		}
+		if string(line) == delimiter {
+			return min(next, uint(len(text)))
+		}
+		offset = next
+	}
+	return 0
+}
+
+// pendingAt returns the line of pending whose heredoc bodies have not started
+// yet at offset, if any.
+func pendingAt(pending []heredocLine, offset uint) *heredocLine {
+	for i := range pending {
+		if pending[i].span[0] <= offset && offset < pending[i].span[1] {
+			return &pending[i]
+		}
+	}
+	return nil
+}
+
+// stmtsSpan returns the byte offsets from the start of the first of stmts to
+// the end of the last.
+func stmtsSpan(stmts []*syntax.Stmt) [2]uint {
+	if len(stmts) == 0 {
+		return [2]uint{}
+	}
+	return [2]uint{stmts[0].Pos().Offset(), stmts[len(stmts)-1].End().Offset()}
+}
+
+// chooseLineBreaks picks, for every line wider than the line width, as few
+// breaks as possible, filling each line greedily. A line is only broken where
+// the part before the break fits, except that a continuation line too wide on
+// its own still has the words after it moved off. A break must make the line
+// shorter; otherwise the rest of the line is left alone.
+func chooseLineBreaks(text []byte, breaks []lineBreak, placeholders map[string]string, config configuration) []lineBreak {
+	lines := lineOffsets(text)
+	widthOf := func(text string) int {
+		return lineWidthOf(text, config) + placeholderWidthChange(text, placeholders, config)
+	}
+
+	var chosen []lineBreak
+	for i := 0; i < len(breaks); {
+		line := breaks[i].line
+		j := i
+		for j < len(breaks) && breaks[j].line == line {
+			j++
+		}
+		candidates := breaks[i:j]
+		i = j
+
+		lineStart := lineStartOffset(lines, line)
+		lineEnd := min(lineStartOffset(lines, line+1), uint(len(text)))
+		content := strings.TrimSuffix(string(text[lineStart:lineEnd]), "\n")
+		indent := content[:len(content)-len(strings.TrimLeft(content, " \t"))]
+		continuationWidth := lineWidthOf(indent, config) + indentWidthOf(config)
+		segmentStart, segmentIndent := uint(0), 0
+		next := 0
+		for next < len(candidates) && candidates[next].offset-lineStart < uint(len(indent)) {
+			next++
+		}
+		for next < len(candidates) {
+			width := segmentIndent + widthOf(content[segmentStart:])
+			if width <= int(config.LineWidth) {
+				break
+			}
+
+			best, bestCommand, bestWeak := -1, -1, -1
+			for k := next; k < len(candidates); k++ {
+				end := candidates[k].offset - lineStart
+				if segmentIndent+widthOf(content[segmentStart:end])+len(candidates[k].suffix) > int(config.LineWidth) {
+					break
+				}
+				switch candidates[k].rank {
+				case breakAfterOption:
+					bestWeak = k
+				case breakAfterCommand:
+					bestCommand = k
+				default:
+					best = k
+				}
+			}
+			if best == -1 {
+				best = bestCommand
+			}
+			switch {
+			case best == -1 && bestWeak == -1 && segmentStart == 0:
+				// Nothing before the first break fits, so breaking would
+				// leave the line too wide anyway.
+			case best == -1 && bestWeak == -1:
+				best = next
+			case best == -1:
+				best = bestWeak
+			case bestWeak > best:
+				// Moving an option to the next line only helps when its
+				// value then fits on that line too.
+				end, suffix := uint(len(content)), 0
+				for k := bestWeak + 1; k < len(candidates); k++ {
+					if candidates[k].rank != breakAfterOption {
+						end, suffix = candidates[k].offset-lineStart, len(candidates[k].suffix)
+						break
+					}
+				}
+				start := candidates[best].offset - lineStart + 1
+				if continuationWidth+widthOf(content[start:end])+suffix > int(config.LineWidth) {
+					best = bestWeak
+				}
+			}
+
+			if best == -1 {
+				break
+			}
+			start := candidates[best].offset - lineStart + 1
+			if continuationWidth+widthOf(content[start:]) >= width {
+				break
+			}
+
+			chosen = append(chosen, candidates[best])
+			segmentStart, segmentIndent = start, continuationWidth
+			next = best + 1
+		}
+	}
+	return chosen
+}
+
+// isOption reports whether word is a literal command-line option such as -v
+// or --name.
+func isOption(word *syntax.Word) bool {
+	return strings.HasPrefix(word.Lit(), "-")
+}
+
+// insertLineBreaks applies breaks, indenting each continuation line one level
+// deeper than the line it continues. Heredoc bodies a break brings along are
+// written between the break and the continuation line.
+func insertLineBreaks(text []byte, breaks []lineBreak, config configuration) []byte {
+	lines := lineOffsets(text)
+	level := "\t"
+	if !config.UseTabs {
+		level = strings.Repeat(" ", int(config.IndentWidth))
+	}
+
+	var result bytes.Buffer
+	var moved [][2]uint
+	write := func(start uint, end uint) {
+		for _, bodies := range moved {
+			if start < bodies[0] && bodies[0] < end {
+				result.Write(text[start:bodies[0]])
+				start = max(start, bodies[1])
+			}
+		}
+		if start < end {
+			result.Write(text[start:end])
+		}
+	}
+	last := uint(0)
+	for _, lineBreak := range breaks {
+		lineStart := lineStartOffset(lines, lineBreak.line)
+		indentEnd := lineStart
+		for indentEnd < uint(len(text)) && (text[indentEnd] == ' ' || text[indentEnd] == '\t') {
+			indentEnd++
+		}
+
+		write(last, lineBreak.offset)
+		result.WriteString(lineBreak.suffix)
+		result.WriteByte('\n')
+		if lineBreak.bodies[1] != 0 && !slices.Contains(moved, lineBreak.bodies) {
+			result.Write(text[lineBreak.bodies[0]:lineBreak.bodies[1]])
+			moved = append(moved, lineBreak.bodies)
+		}
+		result.Write(text[lineStart:indentEnd])
+		result.WriteString(level)
+		last = lineBreak.offset + 1
+	}
+	write(last, uint(len(text)))
+	return result.Bytes()
+}
+
+// lineWidthOf returns the number of columns text takes, counting a tab as one
+// indentation level.
+func lineWidthOf(text string, config configuration) int {
+	return utf8.RuneCountInString(text) + strings.Count(text, "\t")*(indentWidthOf(config)-1)
+}
+
+// placeholderWidthChange returns how many more columns text takes once the
+// placeholders in it are replaced by the code they stand for.
+func placeholderWidthChange(text string, placeholders map[string]string, config configuration) int {
+	if !strings.Contains(text, zshPlaceholderPrefix) {
+		return 0
+	}
+	change := 0
+	for placeholder, original := range placeholders {
+		if count := strings.Count(text, placeholder); count > 0 {
+			change += count * (lineWidthOf(original, config) - lineWidthOf(placeholder, config))
+		}
+	}
+	return change
+}
+
+func indentWidthOf(config configuration) int {
+	return max(int(config.IndentWidth), 1)
+}
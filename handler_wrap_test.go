@@ -0,0 +1,211 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/hrko/dprint-plugin-shfmt/dprint"
+)
+
+func TestFormatWrapsLongLines(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		input  string
+		want   string
+		config func(*configuration)
+	}{
+		{
+			name:  "arguments",
+			input: "docker run --rm -it -v \"$PWD:/src\" -w /src --name builder golang:1.22 go build ./...\n",
+			want:  "docker run --rm -it -v \"$PWD:/src\" \\\n  -w /src --name builder golang:1.22 \\\n  go build ./...\n",
+		},
+		{
+			name:  "options keep their values",
+			input: "x=$(curl -fsSL https://example.com/a/b/c/d --header 'Accept: json')\n",
+			want:  "x=$(curl \\\n  -fsSL https://example.com/a/b/c/d \\\n  --header 'Accept: json')\n",
+		},
+		{
+			name:  "pipeline after operator",
+			input: "if true; then\n  curl -fsSL https://example.com/install.sh | sh -s -- --yes && echo done\nfi\n",
+			want:  "if true; then\n  curl -fsSL \\\n    https://example.com/install.sh |\n    sh -s -- --yes && echo done\nfi\n",
+		},
+		{
+			name:  "pipeline before operator",
+			input: "curl -fsSL https://example.com/setup | sh -s -- --yes\n",
+			want:  "curl -fsSL https://example.com/setup \\\n  | sh -s -- --yes\n",
+			config: func(config *configuration) {
+				config.BinaryNextLine = true
+			},
+		},
+		{
+			name:  "tabs",
+			input: "f() {\n\tprintf '%s\\n' first second third fourth fifth\n}\n",
+			want:  "f() {\n\tprintf '%s\\n' first second third \\\n\t\tfourth fifth\n}\n",
+			config: func(config *configuration) {
+				config.UseTabs = true
+				config.IndentWidth = 4
+			},
+		},
+		{
+			name:  "quotes are not broken",
+			input: "echo \"a very long string that should never be broken at all\" x\n",
+			want:  "echo \\\n  \"a very long string that should never be broken at all\" \\\n  x\n",
+		},
+		{
+			name:  "for loop word list",
+			input: "for x in alpha beta gamma delta epsilon zeta eta; do echo $x; done\n",
+			want:  "for x in alpha beta gamma delta \\\n  epsilon zeta eta; do echo $x; done\n",
+		},
+		{
+			name:  "array literal",
+			input: "files=(alpha.txt beta.txt gamma.txt delta.txt epsilon.txt)\n",
+			want:  "files=(alpha.txt beta.txt gamma.txt\n  delta.txt epsilon.txt)\n",
+			config: func(config *configuration) {
+				config.Variant = "bash"
+			},
+		},
+		{
+			name:  "redirections",
+			input: "generate --input source.json --verbose >output.json 2>errors.log\n",
+			want:  "generate --input source.json --verbose \\\n  >output.json 2>errors.log\n",
+		},
+		{
+			name:  "while condition is not broken",
+			input: "find . -type f -name x | while read -r path; do\n  rm \"$path\"\ndone\n",
+			want:  "find . -type f -name x |\n  while read -r path; do\n    rm \"$path\"\n  done\n",
+		},
+		{
+			name:  "zsh parameter expansion is measured as written",
+			input: "print -r -- ${(j:,:)aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa} bbbbbbbbb ccccccccc\n",
+			want:  "print -r -- \\\n  ${(j:,:)aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa} \\\n  bbbbbbbbb ccccccccc\n",
+			config: func(config *configuration) {
+				config.Variant = "zsh"
+			},
+		},
+		{
+			name:  "zsh glob qualifier is measured as written",
+			input: "print -l *(.N) alpha beta gamma delta eps\n",
+			want:  "print -l *(.N) alpha beta gamma delta \\\n  eps\n",
+			config: func(config *configuration) {
+				config.Variant = "zsh"
+			},
+		},
+		{
+			name:  "no break that leaves the line too wide",
+			input: "for x in \"$alpha_beta_gamma_delta_epsilon_zeta_eta\"; do echo $x; done\n",
+			want:  "for x in \"$alpha_beta_gamma_delta_epsilon_zeta_eta\"; do echo $x; done\n",
+		},
+		{
+			name:  "heredoc bodies and comments are not broken",
+			input: "# a comment that goes on well past the line width\ncat <<EOF\n$(some very long command with many arguments inside)\nEOF\n",
+			want:  "# a comment that goes on well past the line width\ncat <<EOF\n$(some very long command with many arguments inside)\nEOF\n",
+		},
+		{
+			name:  "pipeline opening a heredoc after operator",
+			input: "cat <<EOF | grep aaaaaaaaaaaaaaaaaaaa | grep bbbbbbbbbbbbbbbbbbbbbbbb | grep cccccccccccc\nbody\nEOF\n",
+			want:  "cat <<EOF |\nbody\nEOF\n  grep aaaaaaaaaaaaaaaaaaaa |\n  grep \\\n    bbbbbbbbbbbbbbbbbbbbbbbb |\n  grep cccccccccccc\n",
+			config: func(config *configuration) {
+				config.LineWidth = 30
+			},
+		},
+		{
+			name:  "list opening a heredoc after operator",
+			input: "cat <<EOF && echo aaaaaaaaaaaaaaaaaaaa && echo bbbbbbbbbbbbbbbbbbbbbbbb && echo ccccccccc\nbody\nEOF\n",
+			want:  "cat <<EOF &&\nbody\nEOF\n  echo aaaaaaaaaaaaaaaaaaaa &&\n  echo \\\n    bbbbbbbbbbbbbbbbbbbbbbbb &&\n  echo ccccccccc\n",
+			config: func(config *configuration) {
+				config.LineWidth = 30
+			},
+		},
+		{
+			name:  "pipeline opening a heredoc before operator",
+			input: "cat <<EOF | grep aaaaaaaaaaaaaaaaaaaa | grep bbbbbbbbbbbbbbbbbbbbbbbb | grep cccccccccccc\nbody\nEOF\n",
+			want:  "cat <<EOF | grep \\\n  aaaaaaaaaaaaaaaaaaaa | grep \\\n  bbbbbbbbbbbbbbbbbbbbbbbb | grep \\\n  cccccccccccc\nbody\nEOF\n",
+			config: func(config *configuration) {
+				config.LineWidth = 30
+				config.BinaryNextLine = true
+			},
+		},
+		{
+			name:  "list opening a heredoc before operator",
+			input: "cat <<EOF && echo aaaaaaaaaaaaaaaaaaaa && echo bbbbbbbbbbbbbbbbbbbbbbbb && echo ccccccccc\nbody\nEOF\n",
+			want:  "cat <<EOF && echo \\\n  aaaaaaaaaaaaaaaaaaaa && echo \\\n  bbbbbbbbbbbbbbbbbbbbbbbb && echo \\\n  ccccccccc\nbody\nEOF\n",
+			config: func(config *configuration) {
+				config.LineWidth = 30
+				config.BinaryNextLine = true
+			},
+		},
+		{
+			name:  "pipeline opening a heredoc keeps command names with their first argument",
+			input: "cat <<EOF | grep something-long-here | sort -u | uniq -c | head -n 10\nbody\nEOF\n",
+			want:  "cat <<EOF | grep something-long-here |\nbody\nEOF\n  sort -u | uniq -c | head -n 10\n",
+		},
+		{
+			name:  "pipeline opening an empty heredoc",
+			input: "cat <<EOF | grep something-long-here | sort -u | uniq -c | head -n 10\nEOF\necho after\n",
+			want:  "cat <<EOF | grep something-long-here |\nEOF\n  sort -u | uniq -c | head -n 10\necho after\n",
+		},
+		{
+			name:  "pipeline without heredoc keeps command names with their first argument",
+			input: "cat input.txt | grep something-long-here | sort -u | uniq -c | head -n 10\n",
+			want:  "cat input.txt |\n  grep something-long-here | sort -u |\n  uniq -c | head -n 10\n",
+		},
+		{
+			name:  "disabled",
+			input: "docker run --rm -it -v \"$PWD:/src\" -w /src --name builder golang:1.22 go build ./...\n",
+			want:  "docker run --rm -it -v \"$PWD:/src\" -w /src --name builder golang:1.22 go build ./...\n",
+			config: func(config *configuration) {
+				config.LineWidth = 0
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			config := configuration{IndentWidth: 2, LineWidth: 40}
+			if tc.config != nil {
+				tc.config(&config)
+			}
+
+			h := &handler{}
+			request := dprint.SyncFormatRequest[configuration]{
+				FilePath:  "sample.sh",
+				FileBytes: []byte(tc.input),
+				Config:    config,
+			}
+			result := h.Format(request, nil)
+			if result.Code == dprint.FormatResultError {
+				t.Fatalf("unexpected error: %v", result.Err)
+			}
+			got := tc.input
+			if result.Code == dprint.FormatResultChange {
+				got = string(result.Text)
+			}
+			if got != tc.want {
+				t.Fatalf("unexpected output:\nwant %q\ngot  %q", tc.want, got)
+			}
+
+			request.FileBytes = []byte(got)
+			if result := h.Format(request, nil); result.Code != dprint.FormatResultNoChange {
+				t.Fatalf("wrapped output is not stable: %q", result.Text)
+			}
+		})
+	}
+}
+
+func TestResolveLineWidthFromGlobal(t *testing.T) {
+	t.Parallel()
+
+	h := &handler{}
+	result := h.ResolveConfig(dprint.ConfigKeyMap{}, dprint.GlobalConfiguration{"lineWidth": int64(100)})
+	if result.Config.LineWidth != 100 {
+		t.Fatalf("expected line width from global config, got %d", result.Config.LineWidth)
+	}
+
+	result = h.ResolveConfig(dprint.ConfigKeyMap{"lineWidth": int64(0)}, dprint.GlobalConfiguration{"lineWidth": int64(100)})
+	if result.Config.LineWidth != 0 {
+		t.Fatalf("expected plugin line width to take precedence, got %d", result.Config.LineWidth)
+	}
+}
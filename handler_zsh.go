@@ -0,0 +1,661 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/hrko/dprint-plugin-shfmt/dprint"
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// zshPlaceholderPrefix starts every placeholder, so that a file already
+// containing it can be rejected instead of restored incorrectly.
+const zshPlaceholderPrefix = "__dprint_zsh_"
+
+// zshEdit records one construct replaced by a placeholder.
+type zshEdit struct {
+	original    string
+	placeholder string
+	srcStart    int
+	srcEnd      int
+	protStart   int
+	protEnd     int
+}
+
+// zshProtection is a zsh script rewritten so that the Bash parser accepts it.
+//
+// The rewrite covers parameter expansions with flags or zsh-only modifiers
+// such as ${(j:,:)arr}, ${+var} and ${${x}:r}, glob qualifiers and flags such
+// as *(.N) and (#i)*.txt, numeric ranges such as <1-10>, and anonymous
+// functions. Each construct is replaced by an opaque word that the printer
+// keeps as it is, and restore puts the original bytes back.
+type zshProtection struct {
+	filePath  string
+	src       []byte
+	protected []byte
+	edits     []zshEdit
+}
+
+// zshHeredoc is a heredoc whose body starts after the current line.
+type zshHeredoc struct {
+	delimiter string
+	quoted    bool
+	dash      bool
+}
+
+type zshProtector struct {
+	filePath string
+	src      []byte
+	out      bytes.Buffer
+	copied   int
+	edits    []zshEdit
+	heredocs []zshHeredoc
+}
+
+var zshNumericRangePattern = regexp.MustCompile(`^<[0-9]*-[0-9]*>`)
+
+func protectZsh(src []byte, filePath string) (*zshProtection, error) {
+	if offset := bytes.Index(src, []byte(zshPlaceholderPrefix)); offset >= 0 {
+		return nil, diagnosticAt(
+			filePath,
+			src,
+			offset,
+			offset+len(zshPlaceholderPrefix),
+			codeZshReservedWord,
+			"cannot protect zsh syntax: the file already contains the reserved word '%s'",
+			zshPlaceholderPrefix,
+		)
+	}
+
+	p := &zshProtector{filePath: filePath, src: src}
+	if _, err := p.scanCode(0, 0); err != nil {
+		return nil, err
+	}
+
+	p.out.Write(src[p.copied:])
+	return &zshProtection{filePath: filePath, src: src, protected: p.out.Bytes(), edits: p.edits}, nil
+}
+
+// restore puts the protected constructs back into formatted.
+func (z *zshProtection) restore(formatted []byte) ([]byte, error) {
+	restored := string(formatted)
+	for _, edit := range z.edits {
+		if count := strings.Count(restored, edit.placeholder); count != 1 {
+			return nil, diagnosticAt(
+				z.filePath,
+				z.src,
+				edit.srcStart,
+				edit.srcEnd,
+				codeZshRestoreFailed,
+				"failed to restore zsh syntax '%s': expected its placeholder once in the output, found it %d times",
+				edit.original,
+				count,
+			)
+		}
+		restored = strings.Replace(restored, edit.placeholder, edit.original, 1)
+	}
+	return []byte(restored), nil
+}
+
+// placeholders maps each placeholder to the zsh code it stands for, or
+// returns nil without zsh protection.
+func (z *zshProtection) placeholders() map[string]string {
+	if z == nil {
+		return nil
+	}
+	placeholders := make(map[string]string, len(z.edits))
+	for _, edit := range z.edits {
+		placeholders[edit.placeholder] = edit.original
+	}
+	return placeholders
+}
+
+// protectedOffset maps an offset in the original source to the rewritten one.
+// Offsets within a protected construct map to the start of its placeholder.
+func (z *zshProtection) protectedOffset(offset int) int {
+	shift := 0
+	for _, edit := range z.edits {
+		if offset <= edit.srcStart {
+			break
+		}
+		if offset < edit.srcEnd {
+			return edit.protStart
+		}
+		shift = edit.protEnd - edit.srcEnd
+	}
+	return offset + shift
+}
+
+// originalOffset maps an offset in the rewritten source to the original one.
+func (z *zshProtection) originalOffset(offset int) int {
+	shift := 0
+	for _, edit := range z.edits {
+		if offset <= edit.protStart {
+			break
+		}
+		if offset < edit.protEnd {
+			return edit.srcStart
+		}
+		shift = edit.srcEnd - edit.protEnd
+	}
+	return offset + shift
+}
+
+func (z *zshProtection) position(offset int) syntax.Pos {
+	line := 1 + bytes.Count(z.src[:offset], []byte("\n"))
+	column := offset - (bytes.LastIndexByte(z.src[:offset], '\n') + 1) + 1
+	return syntax.NewPos(uint(offset), uint(line), uint(column))
+}
+
+// remapError points a parser error or a diagnostic at the original source.
+// Without zsh protection, err is returned unchanged.
+func (z *zshProtection) remapError(err error) error {
+	if z == nil {
+		return err
+	}
+	var diagnostic *dprint.Diagnostic
+	if errors.As(err, &diagnostic) {
+		lines := lineOffsets(z.protected)
+		for _, position := range []*dprint.Position{&diagnostic.Start, &diagnostic.End} {
+			if position.IsValid() {
+				offset := lineStartOffset(lines, uint(position.Line)) + uint(position.Column) - 1
+				*position = offsetPosition(z.src, z.originalOffset(int(min(offset, uint(len(z.protected))))))
+			}
+		}
+		return err
+	}
+	var parseErr syntax.ParseError
+	if errors.As(err, &parseErr) {
+		parseErr.Pos = z.position(z.originalOffset(int(parseErr.Pos.Offset())))
+		return parseErr
+	}
+	var langErr syntax.LangError
+	if errors.As(err, &langErr) {
+		langErr.Pos = z.position(z.originalOffset(int(langErr.Pos.Offset())))
+		return langErr
+	}
+	return err
+}
+
+func (p *zshProtector) protect(start int, end int, original string, placeholder string) {
+	p.out.Write(p.src[p.copied:start])
+	protStart := p.out.Len()
+	p.out.WriteString(placeholder)
+	p.edits = append(p.edits, zshEdit{
+		original:    original,
+		placeholder: placeholder,
+		srcStart:    start,
+		srcEnd:      end,
+		protStart:   protStart,
+		protEnd:     p.out.Len(),
+	})
+	p.copied = end
+}
+
+func (p *zshProtector) placeholder() string {
+	return fmt.Sprintf("%s%d__", zshPlaceholderPrefix, len(p.edits))
+}
+
+// errorAt reports an unterminated construct starting at offset, spanning the
+// rest of its line.
+func (p *zshProtector) errorAt(offset int, format string, args ...any) error {
+	end := len(p.src)
+	if newline := bytes.IndexByte(p.src[offset:], '\n'); newline >= 0 {
+		end = offset + newline
+	}
+	return diagnosticAt(p.filePath, p.src, offset, end, codeZshUnterminated, format, args...)
+}
+
+// scanCode scans shell code from i until closer, returning the offset of the
+// closer or the end of the input. The top level passes no closer, so that an
+// unmatched parenthesis such as a case pattern is skipped.
+func (p *zshProtector) scanCode(i int, closer byte) (int, error) {
+	src := p.src
+	for i < len(src) {
+		c := src[i]
+		switch {
+		case closer != 0 && c == closer:
+			return i, nil
+		case c == '\\':
+			i += 2
+		case c == '\'':
+			i = skipSingleQuoted(src, i+1)
+		case c == '"':
+			end, err := p.scanDouble(i + 1)
+			if err != nil {
+				return 0, err
+			}
+			i = end
+		case c == '`':
+			i = skipBackquoted(src, i+1)
+		case c == '#' && isWordStart(src, i):
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case c == '\n':
+			end, err := p.scanHeredocBodies(i + 1)
+			if err != nil {
+				return 0, err
+			}
+			i = end
+		case c == '$':
+			end, err := p.scanDollar(i)
+			if err != nil {
+				return 0, err
+			}
+			i = end
+		case c == '<':
+			end, err := p.scanLess(i)
+			if err != nil {
+				return 0, err
+			}
+			i = end
+		case c == '(':
+			end, err := p.scanParen(i)
+			if err != nil {
+				return 0, err
+			}
+			i = end
+		case c == 'f' && isCommandPosition(src, i) && hasAnonymousFunctionKeyword(src, i):
+			p.protect(i, i+len("function"), "function", "function "+p.placeholder())
+			i += len("function")
+		default:
+			i++
+		}
+	}
+	return len(src), nil
+}
+
+// scanDouble scans a double-quoted string from just after its opening quote.
+func (p *zshProtector) scanDouble(i int) (int, error) {
+	src := p.src
+	for i < len(src) {
+		switch src[i] {
+		case '\\':
+			i += 2
+		case '"':
+			return i + 1, nil
+		case '`':
+			i = skipBackquoted(src, i+1)
+		case '$':
+			end, err := p.scanDollar(i)
+			if err != nil {
+				return 0, err
+			}
+			i = end
+		default:
+			i++
+		}
+	}
+	return len(src), nil
+}
+
+// scanDollar scans an expansion starting at the dollar sign at i.
+func (p *zshProtector) scanDollar(i int) (int, error) {
+	src := p.src
+	if i+1 >= len(src) {
+		return len(src), nil
+	}
+
+	switch src[i+1] {
+	case '{':
+		return p.scanParam(i)
+	case '(':
+		end, err := p.scanCode(i+2, ')')
+		if err != nil {
+			return 0, err
+		}
+		return min(end+1, len(src)), nil
+	case '\'':
+		return skipANSIQuoted(src, i+2), nil
+	default:
+		return i + 1, nil
+	}
+}
+
+// scanParam scans a ${...} expansion starting at the dollar sign at i. The
+// whole expansion is protected when it uses zsh-only syntax.
+func (p *zshProtector) scanParam(i int) (int, error) {
+	src := p.src
+	if isZshParam(src, i+2) {
+		end, ok := matchBraces(src, i+1)
+		if !ok {
+			return 0, p.errorAt(i, "unterminated zsh parameter expansion")
+		}
+		p.protect(i, end, string(src[i:end]), p.placeholder())
+		return end, nil
+	}
+
+	j := i + 2
+	for j < len(src) {
+		switch src[j] {
+		case '\\':
+			j += 2
+		case '}':
+			return j + 1, nil
+		case '\'':
+			j = skipSingleQuoted(src, j+1)
+		case '"':
+			end, err := p.scanDouble(j + 1)
+			if err != nil {
+				return 0, err
+			}
+			j = end
+		case '$':
+			end, err := p.scanDollar(j)
+			if err != nil {
+				return 0, err
+			}
+			j = end
+		default:
+			j++
+		}
+	}
+	return len(src), nil
+}
+
+// scanLess handles heredoc operators and zsh numeric ranges at i.
+func (p *zshProtector) scanLess(i int) (int, error) {
+	src := p.src
+	if match := zshNumericRangePattern.Find(src[i:]); match != nil {
+		p.protect(i, i+len(match), string(match), p.placeholder())
+		return i + len(match), nil
+	}
+
+	if !bytes.HasPrefix(src[i:], []byte("<<")) {
+		return i + 1, nil
+	}
+	if bytes.HasPrefix(src[i:], []byte("<<<")) {
+		return i + 3, nil
+	}
+
+	j := i + 2
+	heredoc := zshHeredoc{}
+	if j < len(src) && src[j] == '-' {
+		heredoc.dash = true
+		j++
+	}
+	for j < len(src) && (src[j] == ' ' || src[j] == '\t') {
+		j++
+	}
+
+	var delimiter strings.Builder
+	for j < len(src) && !isWordBoundary(src[j]) {
+		switch src[j] {
+		case '\\':
+			heredoc.quoted = true
+			if j+1 < len(src) {
+				delimiter.WriteByte(src[j+1])
+			}
+			j += 2
+		case '\'', '"':
+			heredoc.quoted = true
+			end := bytes.IndexByte(src[j+1:], src[j])
+			if end == -1 {
+				return 0, p.errorAt(j, "unterminated heredoc delimiter")
+			}
+			delimiter.Write(src[j+1 : j+1+end])
+			j += end + 2
+		default:
+			delimiter.WriteByte(src[j])
+			j++
+		}
+	}
+
+	heredoc.delimiter = delimiter.String()
+	p.heredocs = append(p.heredocs, heredoc)
+	return j, nil
+}
+
+// scanParen handles glob qualifiers, glob flags and anonymous functions at
+// the opening parenthesis at i, or scans the parenthesized code.
+func (p *zshProtector) scanParen(i int) (int, error) {
+	src := p.src
+
+	if bytes.HasPrefix(src[i:], []byte("()")) && isCommandPosition(src, i) && opensBraceGroup(src, i+2) {
+		p.protect(i, i+2, "()", p.placeholder()+"()")
+		return i + 2, nil
+	}
+
+	isGlobFlag := i+2 < len(src) && src[i+1] == '#' && !isSpace(src[i+2])
+	if isGlobFlag || isGlobQualifier(src, i) {
+		end, ok := matchParenOnLine(src, i)
+		if !ok {
+			return 0, p.errorAt(i, "unterminated zsh glob qualifier")
+		}
+		p.protect(i, end, string(src[i:end]), p.placeholder())
+		return end, nil
+	}
+
+	end, err := p.scanCode(i+1, ')')
+	if err != nil {
+		return 0, err
+	}
+	return min(end+1, len(src)), nil
+}
+
+// scanHeredocBodies skips the bodies of the heredocs started on the line
+// ending just before i. Expansions in unquoted bodies are still protected.
+func (p *zshProtector) scanHeredocBodies(i int) (int, error) {
+	src := p.src
+	heredocs := p.heredocs
+	p.heredocs = nil
+
+	for _, heredoc := range heredocs {
+		for i < len(src) {
+			lineEnd := bytes.IndexByte(src[i:], '\n')
+			if lineEnd == -1 {
+				lineEnd = len(src)
+			} else {
+				lineEnd += i
+			}
+
+			line := strings.TrimSuffix(string(src[i:lineEnd]), "\r")
+			if heredoc.dash {
+				line = strings.TrimLeft(line, "\t")
+			}
+			if line == heredoc.delimiter {
+				i = min(lineEnd+1, len(src))
+				break
+			}
+
+			if heredoc.quoted {
+				i = min(lineEnd+1, len(src))
+				continue
+			}
+			for i <= lineEnd && i < len(src) {
+				switch {
+				case src[i] == '\\':
+					i += 2
+				case src[i] == '$':
+					end, err := p.scanDollar(i)
+					if err != nil {
+						return 0, err
+					}
+					i = end
+				default:
+					i++
+				}
+			}
+		}
+	}
+
+	return i, nil
+}
+
+// isZshParam reports whether the parameter expansion whose body starts at i
+// uses syntax Bash does not have.
+func isZshParam(src []byte, i int) bool {
+	if i >= len(src) {
+		return false
+	}
+	switch src[i] {
+	case '(', '+', '=', '~', '^':
+		return true
+	case '$':
+		return i+1 < len(src) && (src[i+1] == '{' || src[i+1] == '(')
+	default:
+		return false
+	}
+}
+
+// isGlobQualifier reports whether the parenthesis at i ends a word, as in
+// *.txt(om[1]) or ~/bin(N/). Zsh reads such parentheses as glob qualifiers
+// whether or not the word contains a wildcard.
+func isGlobQualifier(src []byte, i int) bool {
+	if i+1 < len(src) && src[i+1] == ')' {
+		return false
+	}
+
+	start := i
+	for start > 0 && !isWordBoundary(src[start-1]) {
+		start--
+	}
+	prefix := src[start:i]
+	if len(prefix) == 0 {
+		return false
+	}
+	// Leave Bash extended globs, process substitutions and array
+	// assignments alone.
+	return !strings.ContainsRune("$<>=@+!", rune(prefix[len(prefix)-1]))
+}
+
+// matchBraces returns the offset just past the brace closing the one at i.
+func matchBraces(src []byte, i int) (int, bool) {
+	depth := 0
+	inDouble := false
+	for j := i; j < len(src); j++ {
+		switch src[j] {
+		case '\\':
+			j++
+		case '\'':
+			if !inDouble {
+				end := bytes.IndexByte(src[j+1:], '\'')
+				if end == -1 {
+					return 0, false
+				}
+				j += end + 1
+			}
+		case '"':
+			inDouble = !inDouble
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return j + 1, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// matchParenOnLine returns the offset just past the parenthesis closing the
+// one at i, which must be on the same line.
+func matchParenOnLine(src []byte, i int) (int, bool) {
+	depth := 0
+	for j := i; j < len(src) && src[j] != '\n'; j++ {
+		switch src[j] {
+		case '\\':
+			j++
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return j + 1, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// isCommandPosition reports whether a command may start at i.
+func isCommandPosition(src []byte, i int) bool {
+	j := i
+	for j > 0 && (src[j-1] == ' ' || src[j-1] == '\t') {
+		j--
+	}
+	if j == 0 || strings.ContainsRune("\n;&|({", rune(src[j-1])) {
+		return true
+	}
+
+	start := j
+	for start > 0 && !isWordBoundary(src[start-1]) {
+		start--
+	}
+	switch string(src[start:j]) {
+	case "then", "do", "else", "elif", "while", "until", "if", "!":
+		return true
+	default:
+		return false
+	}
+}
+
+func hasAnonymousFunctionKeyword(src []byte, i int) bool {
+	rest, ok := bytes.CutPrefix(src[i:], []byte("function"))
+	if !ok || len(rest) == 0 || !isSpace(rest[0]) {
+		return false
+	}
+	return opensBraceGroup(src, i+len("function"))
+}
+
+// opensBraceGroup reports whether only blanks separate i from an opening
+// brace.
+func opensBraceGroup(src []byte, i int) bool {
+	for i < len(src) && (src[i] == ' ' || src[i] == '\t') {
+		i++
+	}
+	return i < len(src) && src[i] == '{'
+}
+
+func isWordStart(src []byte, i int) bool {
+	return i == 0 || isWordBoundary(src[i-1])
+}
+
+func isWordBoundary(c byte) bool {
+	return isSpace(c) || strings.ContainsRune(";&|<>()`", rune(c))
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func skipSingleQuoted(src []byte, i int) int {
+	end := bytes.IndexByte(src[i:], '\'')
+	if end == -1 {
+		return len(src)
+	}
+	return i + end + 1
+}
+
+func skipANSIQuoted(src []byte, i int) int {
+	for i < len(src) {
+		switch src[i] {
+		case '\\':
+			i += 2
+		case '\'':
+			return i + 1
+		default:
+			i++
+		}
+	}
+	return len(src)
+}
+
+func skipBackquoted(src []byte, i int) int {
+	for i < len(src) {
+		switch src[i] {
+		case '\\':
+			i += 2
+		case '`':
+			return i + 1
+		default:
+			i++
+		}
+	}
+	return len(src)
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hrko/dprint-plugin-shfmt/dprint"
+)
+
+func TestFormatZsh(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "parameter flags",
+			input: "print -r -- \"${(j:,:)files}\"   ${(U)name}\n",
+			want:  "print -r -- \"${(j:,:)files}\" ${(U)name}\n",
+		},
+		{
+			name:  "zsh-only parameter forms",
+			input: "if (( ${+commands[git]} ));then\necho ${=words} ${~pattern} ${^arr} ${${(s:/:)PWD}[-1]}\nfi\n",
+			want:  "if ((${+commands[git]})); then\n  echo ${=words} ${~pattern} ${^arr} ${${(s:/:)PWD}[-1]}\nfi\n",
+		},
+		{
+			name:  "glob qualifiers and flags",
+			input: "files=( *.txt(.N) )\nfor f in **/*.md(.om[1,3]);do\nls (#i)readme*  <1-10>.log\ndone\n",
+			want:  "files=(*.txt(.N))\nfor f in **/*.md(.om[1,3]); do\n  ls (#i)readme* <1-10>.log\ndone\n",
+		},
+		{
+			name:  "anonymous functions",
+			input: "() {\nlocal x=$1\n}\nfunction {\necho anon\n}\n",
+			want:  "() {\n  local x=$1\n}\nfunction {\n  echo anon\n}\n",
+		},
+		{
+			name:  "unquoted heredoc body is protected",
+			input: "cat <<EOF\njoined ${(j: :)files}\nEOF\n",
+			want:  "cat <<EOF\njoined ${(j: :)files}\nEOF\n",
+		},
+		{
+			name:  "quoted heredoc bodies and comments are skipped",
+			input: "cat <<'EOF'\nraw ${(j: :\nEOF\n# ${(j:,:)x} and *(N\necho   ok\n",
+			want:  "cat <<'EOF'\nraw ${(j: :\nEOF\n# ${(j:,:)x} and *(N\necho ok\n",
+		},
+		{
+			name:  "setopt block",
+			input: "setopt extendedglob\nsetopt   nullglob\nunsetopt beep\n",
+			want:  "setopt extendedglob\nsetopt nullglob\nunsetopt beep\n",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			result := formatZshForTest(tc.input)
+			if result.Code == dprint.FormatResultError {
+				t.Fatalf("unexpected error: %v", result.Err)
+			}
+			got := tc.input
+			if result.Code == dprint.FormatResultChange {
+				got = string(result.Text)
+			}
+			if got != tc.want {
+				t.Fatalf("unexpected output:\nwant %q\ngot  %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestFormatZshReportsUnsupportedSyntax(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "parse error points at the original source",
+			input: "echo ${(j:,:)a}; for x (a b) { echo $x }\n",
+			want: "sample.zsh:1:18: \"for foo\" must be followed by \"in\", \"do\", ;, or a newline [syntax-error]\n" +
+				"  hint: parsed as zsh (detected from the file extension); zsh files are parsed as bash with zsh-only syntax protected, so some zsh constructs are not supported",
+		},
+		{
+			name:  "unterminated parameter expansion",
+			input: "echo ${(j:,:)a\n",
+			want:  "sample.zsh:1:6: unterminated zsh parameter expansion [zsh-unterminated]",
+		},
+		{
+			name:  "unterminated glob qualifier",
+			input: "ls *(.N\n",
+			want:  "sample.zsh:1:5: unterminated zsh glob qualifier [zsh-unterminated]",
+		},
+		{
+			name:  "reserved placeholder word",
+			input: "echo __dprint_zsh_0__\n",
+			want:  "sample.zsh:1:6: cannot protect zsh syntax: the file already contains the reserved word '__dprint_zsh_' [zsh-reserved-word]",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			result := formatZshForTest(tc.input)
+			if result.Code != dprint.FormatResultError {
+				t.Fatalf("expected error result, got %d", result.Code)
+			}
+			if result.Err.Error() != tc.want {
+				t.Fatalf("unexpected error:\nwant %s\ngot  %s", tc.want, result.Err)
+			}
+		})
+	}
+}
+
+func TestFormatZshRange(t *testing.T) {
+	t.Parallel()
+
+	input := "echo   ${(j:,:)a}\necho   ${(U)b}\n"
+	start := strings.Index(input, "echo   ${(U)")
+
+	h := &handler{}
+	result := h.Format(
+		dprint.SyncFormatRequest[configuration]{
+			FilePath:  "sample.zsh",
+			FileBytes: []byte(input),
+			Config:    configuration{IndentWidth: 2},
+			Range:     &dprint.FormatRange{Start: uint32(start), End: uint32(len(input) - 1)},
+		},
+		nil,
+	)
+
+	if result.Code != dprint.FormatResultChange {
+		t.Fatalf("expected change result, got %d (err: %v)", result.Code, result.Err)
+	}
+	if want := "echo   ${(j:,:)a}\necho ${(U)b}\n"; string(result.Text) != want {
+		t.Fatalf("unexpected output:\nwant %q\ngot  %q", want, string(result.Text))
+	}
+}
+
+func formatZshForTest(input string) dprint.FormatResult {
+	h := &handler{}
+	return h.Format(
+		dprint.SyncFormatRequest[configuration]{
+			FilePath:  "sample.zsh",
+			FileBytes: []byte(input),
+			Config:    configuration{IndentWidth: 2},
+		},
+		nil,
+	)
+}
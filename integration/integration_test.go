@@ -51,9 +51,12 @@ func TestDprintPluginIntegration(t *testing.T) {
 		{name: "format-success"},
 		{name: "no-change"},
 		{name: "parse-error", exitCode: 1, stderrContains: []string{"must end with \"fi\""}},
+		{name: "multiple-parse-errors", exitCode: 1, stderrContains: []string{"1:1: if statement must end with \"fi\"", "4:1: for statement must end with \"done\"", "7:4: | must be followed by a statement"}},
 		{name: "variant-sh-fails-for-bash-array", virtualPath: "sample.sh", exitCode: 1, stderrContains: []string{"arrays are a bash/mksh feature"}},
 		{name: "variant-bash-succeeds-for-bash-array", virtualPath: "sample.bash"},
 		{name: "shebang-precedence"},
+		{name: "bats-suite", virtualPath: "sample.bats"},
+		{name: "zsh-dotfile", virtualPath: "sample.zsh"},
 		{name: "plugin-overrides-global"},
 		{name: "comment-and-shebang-preservation"},
 		{name: "use-tabs-option"},
@@ -62,6 +65,12 @@ func TestDprintPluginIntegration(t *testing.T) {
 		{name: "space-redirects-option"},
 		{name: "func-next-line-option"},
 		{name: "minify-option"},
+		{name: "simplify-option"},
+		{name: "ignore-comments"},
+		{name: "crlf-preserved"},
+		{name: "bom-shebang-preserved"},
+		{name: "bom-shebang-removed"},
+		{name: "line-width-global"},
 		{name: "config-type-error-diagnostic", exitCode: 1, stderrContains: []string{"Expected 'funcNextLine' to be a boolean", "Had 1 configuration errors."}},
 		{name: "unknown-property-diagnostic", exitCode: 1, stderrContains: []string{"Unknown property 'unknownField'.", "Had 1 configuration errors."}},
 		{name: "repeated-invocations-same-cache", repeat: 3},
@@ -0,0 +1,36 @@
+#!/usr/bin/env bats
+
+load   'test_helper/bats-support/load'
+load 'test_helper/bats-assert/load'
+
+setup_file(){
+export FIXTURE_DIR="$(mktemp -d)"
+}
+
+teardown_file() {
+    rm -rf "$FIXTURE_DIR"
+}
+
+setup() {
+  cd "$FIXTURE_DIR"||exit 1
+}
+
+@test "prints usage without arguments" {
+run   ./cli.sh
+[ "$status" -eq 1 ]
+assert_output --partial   "usage:"
+}
+
+@test 'accepts a name'   {
+    run ./cli.sh --name world
+    if [[ $status -ne 0 ]];then
+        echo "$output" >&3
+    fi
+    assert_success
+  assert_output "hello world"
+}
+
+@test "reads from stdin" {
+  run bash -c 'echo hi | ./cli.sh -'
+  assert_line --index 0 hi
+}
@@ -0,0 +1,3 @@
+﻿#!/usr/bin/env zsh
+files=( *.sh(.N) )
+print -l   ${(j:,:)files}
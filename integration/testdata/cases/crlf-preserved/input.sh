@@ -0,0 +1,6 @@
+if true;then
+echo   "hi"
+fi
+cat <<EOF
+body
+EOF
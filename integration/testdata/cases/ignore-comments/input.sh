@@ -0,0 +1,14 @@
+#!/bin/bash
+usage(){
+echo   "usage: $0 start|stop"
+# dprint-ignore keep the columns aligned
+printf '%-8s %s\n'   start   'start the daemon'
+}
+
+case $1 in
+# dprint-ignore-start
+  start)   run   ;;
+  stop)    halt  ;;
+# dprint-ignore-end
+*)  usage;;
+esac
@@ -0,0 +1,5 @@
+#!/bin/sh
+build() {
+  docker run --rm -it -v "$PWD:/src" -w /src --name builder golang:1.22 go build -o /src/bin/app ./cmd/app
+  curl -fsSL https://example.com/releases/latest/install.sh | sh -s -- --prefix "$HOME/.local" && echo installed
+}
@@ -0,0 +1,7 @@
+if [ "$1" = "ok" ]; then
+  echo ok
+
+for f in *.sh; do
+  echo "$f"
+
+ls |
@@ -0,0 +1,6 @@
+#!/usr/bin/env bash
+total=$(( $count + ${offset} ))
+if [[ "$mode" == "fast" && -n "$total" ]]; then
+  ( ( echo "$total" ) )
+fi
+echo $(( ( total * 2 ) ))
@@ -0,0 +1,21 @@
+#!/bin/zsh
+setopt extendedglob nullglob
+typeset -a files
+files=( *.txt(.N) )
+for f in **/*.md(.om[1,3]);do
+print -r -- "${(j:,:)files}"
+done
+if (( ${+commands[git]} ));then
+  echo "git: ${${(s:/:)PWD}[-1]}"
+fi
+ls (#i)readme*  <1-10>.log
+() {
+local x=${(U)1}
+echo $x
+}
+function {
+echo anon
+}
+autoload -Uz compinit && compinit
+zstyle ':completion:*' matcher-list 'm:{a-z}={A-Za-z}'
+path=( ~/bin(N/) $path )
@@ -1,8 +1,7 @@
-// Package main implements the dprint-plugin-shfmt Wasm entrypoint.
+// Package main implements the dprint-plugin-shfmt Wasm and process plugin
+// entrypoints.
 package main
 
-import "github.com/hrko/dprint-plugin-shfmt/dprint"
-
 //go:generate go run github.com/hrko/dprint-plugin-shfmt/dprint/cmd/gen-main-boilerplate -runtime runtime -out main_generated.go
 
 var (
@@ -11,5 +10,3 @@ var (
 )
 
 type handler struct{}
-
-var runtime = dprint.NewRuntime(&handler{})
@@ -1,5 +1,7 @@
 // Code generated by go generate; DO NOT EDIT.
 
+//go:build wasm
+
 package main
 
 //export dprint_plugin_version_4
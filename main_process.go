@@ -0,0 +1,20 @@
+//go:build !wasm
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/hrko/dprint-plugin-shfmt/dprint"
+)
+
+// main runs the plugin as a dprint process plugin. dprint passes the id of
+// its own process with --parent-pid; the plugin stops when its stdin closes,
+// which also happens when dprint exits.
+func main() {
+	if err := dprint.NewProcessServer(&handler{}).Serve(os.Stdin, os.Stdout); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "dprint-plugin-shfmt: %v\n", err)
+		os.Exit(1)
+	}
+}
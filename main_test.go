@@ -1,11 +1,16 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
 	"strings"
 	"testing"
 
 	"github.com/hrko/dprint-plugin-shfmt/dprint"
+	"mvdan.cc/sh/v3/syntax"
 )
 
 func TestResolveConfigDefaults(t *testing.T) {
@@ -129,6 +134,147 @@ func TestResolveConfigIgnoresNilValues(t *testing.T) {
 	}
 }
 
+func TestResolveConfigChecksConstraints(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		config  dprint.ConfigKeyMap
+		check   func(configuration) bool
+		message string
+	}{
+		{
+			name:    "indent width above maximum",
+			config:  dprint.ConfigKeyMap{"indentWidth": float64(17)},
+			check:   func(config configuration) bool { return config.IndentWidth == 2 },
+			message: "Expected 'indentWidth' to be at most 16, but got 17.",
+		},
+		{
+			name:    "max parse errors below minimum",
+			config:  dprint.ConfigKeyMap{"maxParseErrors": float64(0)},
+			check:   func(config configuration) bool { return config.MaxParseErrors == 10 },
+			message: "Expected 'maxParseErrors' to be at least 1, but got 0.",
+		},
+		{
+			name:    "multi-line directive",
+			config:  dprint.ConfigKeyMap{"ignoreDirective": "keep\nthis"},
+			check:   func(config configuration) bool { return config.IgnoreDirective == "dprint-ignore" },
+			message: "Expected 'ignoreDirective' to match the pattern '^[^\\r\\n]*\\S[^\\r\\n]*$', but got \"keep\\nthis\".",
+		},
+		{
+			name:    "zero indent width with spaces",
+			config:  dprint.ConfigKeyMap{"indentWidth": float64(0)},
+			check:   func(config configuration) bool { return config.IndentWidth == 2 },
+			message: "Expected 'indentWidth' to be at least 1 when 'useTabs' is false, but got 0.",
+		},
+		{
+			name:    "function braces on the next line when minifying",
+			config:  dprint.ConfigKeyMap{"minify": true, "funcNextLine": true},
+			check:   func(config configuration) bool { return config.Minify && !config.FuncNextLine },
+			message: "Expected 'funcNextLine' to be false when 'minify' is true, but got true.",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			h := &handler{}
+			result := h.ResolveConfig(tc.config, dprint.GlobalConfiguration{})
+
+			if !tc.check(result.Config) {
+				t.Fatalf("unexpected config: %+v", result.Config)
+			}
+			if len(result.Diagnostics) != 1 || result.Diagnostics[0]["message"] != tc.message {
+				t.Fatalf("unexpected diagnostics: %#v", result.Diagnostics)
+			}
+		})
+	}
+}
+
+func TestResolveConfigSuggestsKnownProperties(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		key        string
+		suggestion string
+	}{
+		{key: "switchCaseIdent", suggestion: "switchCaseIndent"},
+		{key: "indent_width", suggestion: "indentWidth"},
+		{key: "ci", suggestion: "switchCaseIndent"},
+		{key: "bn", suggestion: "binaryNextLine"},
+		{key: "fn", suggestion: "funcNextLine"},
+		{key: "language_dialect", suggestion: "variant"},
+		{key: "shell_variant", suggestion: "variant"},
+		{key: "heredocLanguage", suggestion: "heredocLanguages"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.key, func(t *testing.T) {
+			t.Parallel()
+
+			h := &handler{}
+			result := h.ResolveConfig(dprint.ConfigKeyMap{tc.key: true}, dprint.GlobalConfiguration{})
+
+			if len(result.Diagnostics) != 1 {
+				t.Fatalf("unexpected diagnostics: %#v", result.Diagnostics)
+			}
+			want := "Unknown property '" + tc.key + "'. Did you mean '" + tc.suggestion + "'?"
+			if result.Diagnostics[0]["message"] != want || result.Diagnostics[0]["suggestion"] != tc.suggestion {
+				t.Fatalf("unexpected diagnostic: %#v", result.Diagnostics[0])
+			}
+		})
+	}
+}
+
+func TestResolveConfigAcceptsEditorConfigAliases(t *testing.T) {
+	t.Parallel()
+
+	h := &handler{}
+	result := h.ResolveConfig(
+		dprint.ConfigKeyMap{
+			"indent_size":        float64(4),
+			"indent_style":       "space",
+			"binary_next_line":   true,
+			"switch_case_indent": true,
+			"space_redirects":    true,
+			"function_next_line": true,
+		},
+		dprint.GlobalConfiguration{"useTabs": true},
+	)
+
+	config := result.Config
+	if config.IndentWidth != 4 || config.UseTabs {
+		t.Fatalf("unexpected indentation: %d, %t", config.IndentWidth, config.UseTabs)
+	}
+	if !config.BinaryNextLine || !config.SwitchCaseIndent || !config.SpaceRedirects || !config.FuncNextLine {
+		t.Fatalf("expected aliased options to be enabled, got %+v", config)
+	}
+	if len(result.Diagnostics) != 6 {
+		t.Fatalf("expected a diagnostic for each alias, got %#v", result.Diagnostics)
+	}
+	if result.Diagnostics[0]["message"] != "Property 'indent_size' is deprecated. Use 'indentWidth' instead." {
+		t.Fatalf("unexpected diagnostic: %#v", result.Diagnostics[0])
+	}
+}
+
+func TestResolveConfigAllowsZeroIndentWidthWithTabs(t *testing.T) {
+	t.Parallel()
+
+	h := &handler{}
+	result := h.ResolveConfig(
+		dprint.ConfigKeyMap{"indentWidth": float64(0), "useTabs": true},
+		dprint.GlobalConfiguration{},
+	)
+
+	if result.Config.IndentWidth != 0 {
+		t.Fatalf("expected indent width 0, got %d", result.Config.IndentWidth)
+	}
+	if len(result.Diagnostics) != 0 {
+		t.Fatalf("expected no diagnostics, got %#v", result.Diagnostics)
+	}
+}
+
 func TestFormatWithShfmt(t *testing.T) {
 	h := &handler{}
 
@@ -156,6 +302,38 @@ func TestFormatWithShfmt(t *testing.T) {
 	}
 }
 
+func TestFormatSimplifiesWhenEnabled(t *testing.T) {
+	h := &handler{}
+	input := "echo $(( $a + ${b} ))\n[[ \"$c\" == x ]]\n"
+
+	for _, tc := range []struct {
+		simplify bool
+		want     string
+	}{
+		{simplify: false, want: "echo $(($a + ${b}))\n[[ \"$c\" == x ]]\n"},
+		{simplify: true, want: "echo $((a + b))\n[[ $c == x ]]\n"},
+	} {
+		result := h.Format(
+			dprint.SyncFormatRequest[configuration]{
+				FilePath:  "script.bash",
+				FileBytes: []byte(input),
+				Config: configuration{
+					IndentWidth: 2,
+					Simplify:    tc.simplify,
+				},
+			},
+			nil,
+		)
+
+		if result.Code != dprint.FormatResultChange {
+			t.Fatalf("simplify=%v: expected change result, got %d (err: %v)", tc.simplify, result.Code, result.Err)
+		}
+		if string(result.Text) != tc.want {
+			t.Fatalf("simplify=%v: unexpected output:\nwant %q\ngot  %q", tc.simplify, tc.want, string(result.Text))
+		}
+	}
+}
+
 func TestFormatDetectsBashShebang(t *testing.T) {
 	h := &handler{}
 
@@ -178,6 +356,29 @@ func TestFormatDetectsBashShebang(t *testing.T) {
 	}
 }
 
+func TestFormatParsesBatsTests(t *testing.T) {
+	h := &handler{}
+
+	result := h.Format(
+		dprint.SyncFormatRequest[configuration]{
+			FilePath:  "suite.bats",
+			FileBytes: []byte("@test   \"works\"   {\nrun   true\n}\n"),
+			Config: configuration{
+				IndentWidth: 2,
+				UseTabs:     false,
+			},
+		},
+		nil,
+	)
+
+	if result.Code != dprint.FormatResultChange {
+		t.Fatalf("expected change result, got %d (err: %v)", result.Code, result.Err)
+	}
+	if got, want := string(result.Text), "@test \"works\" {\n  run true\n}\n"; got != want {
+		t.Fatalf("unexpected output:\nwant %q\ngot  %q", want, got)
+	}
+}
+
 func TestFormatReturnsErrorOnParseFailure(t *testing.T) {
 	h := &handler{}
 
@@ -201,6 +402,112 @@ func TestFormatReturnsErrorOnParseFailure(t *testing.T) {
 	}
 }
 
+func TestFormatStopsWhenCancelled(t *testing.T) {
+	h := &handler{}
+
+	result := h.Format(
+		dprint.SyncFormatRequest[configuration]{
+			FilePath:  "sample.sh",
+			FileBytes: []byte("if true;then\n echo ok\nfi\n"),
+			Config:    configuration{IndentWidth: 2},
+			Token:     &flagCancellationToken{cancelled: true},
+		},
+		nil,
+	)
+
+	if result.Code != dprint.FormatResultCancelled {
+		t.Fatalf("expected cancelled result, got %d (err: %v)", result.Code, result.Err)
+	}
+}
+
+func TestParseStopsBetweenStatementsWhenCancelled(t *testing.T) {
+	// The whole script is read at once, so only the check between
+	// statements can stop parsing.
+	input := []byte("echo a\necho b\necho c\n")
+	token := &flagCancellationToken{}
+
+	parser := syntax.NewParser(syntax.KeepComments(true))
+	_, err := parseStmts(parser, cancelOnUse{token: token, reader: bytes.NewReader(input)}, token)
+
+	if !errors.Is(err, errCancelled) {
+		t.Fatalf("expected parsing to be cancelled, got %v", err)
+	}
+}
+
+func TestPrintStopsBetweenStatementsWhenCancelled(t *testing.T) {
+	// The token is cancelled while the first run of statements is written.
+	input := []byte("echo a\n\necho b\n\necho c\n")
+	prog, err := syntax.NewParser().Parse(bytes.NewReader(input), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := &flagCancellationToken{}
+
+	var buffer bytes.Buffer
+	err = printCancellable(syntax.NewPrinter(), cancelOnUse{token: token, writer: &buffer}, prog, false, token)
+
+	if !errors.Is(err, errCancelled) {
+		t.Fatalf("expected printing to be cancelled, got %v", err)
+	}
+	if strings.Contains(buffer.String(), "echo b") {
+		t.Fatalf("expected printing to stop after the first statement, got %q", buffer.String())
+	}
+}
+
+func TestPrintCancellableMatchesPrint(t *testing.T) {
+	input := "# lead\necho a # one\nlonger b # two\n\n\ncat <<EOF || x\nbody\nEOF\n\necho c\n\n# trailing\n"
+	parser := syntax.NewParser(syntax.KeepComments(true))
+	want, err := parser.Parse(strings.NewReader(input), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := parseCancellable(parser, []byte(input), "", dprint.NullCancellationToken{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, minify := range []bool{false, true} {
+		printer := syntax.NewPrinter(syntax.Minify(minify), syntax.BinaryNextLine(true))
+		var wantBuffer, gotBuffer bytes.Buffer
+		if err := printer.Print(&wantBuffer, want); err != nil {
+			t.Fatal(err)
+		}
+		if err := printCancellable(printer, &gotBuffer, got, minify, dprint.NullCancellationToken{}); err != nil {
+			t.Fatal(err)
+		}
+		if gotBuffer.String() != wantBuffer.String() {
+			t.Fatalf("unexpected output with minify %v:\nwant %q\ngot  %q", minify, wantBuffer.String(), gotBuffer.String())
+		}
+	}
+}
+
+// flagCancellationToken is cancelled once cancelled is set.
+type flagCancellationToken struct {
+	cancelled bool
+}
+
+func (t *flagCancellationToken) IsCancelled() bool {
+	return t.cancelled
+}
+
+// cancelOnUse cancels token the first time it is read from or written to,
+// like a host cancelling a request while it is being formatted.
+type cancelOnUse struct {
+	token  *flagCancellationToken
+	reader io.Reader
+	writer io.Writer
+}
+
+func (c cancelOnUse) Read(p []byte) (int, error) {
+	c.token.cancelled = true
+	return c.reader.Read(p)
+}
+
+func (c cancelOnUse) Write(p []byte) (int, error) {
+	c.token.cancelled = true
+	return c.writer.Write(p)
+}
+
 func TestLicenseTextEmbedsFullLicenseReport(t *testing.T) {
 	h := &handler{}
 	licenseText := h.LicenseText()
@@ -239,3 +546,31 @@ func TestPluginInfoUsesPluginsDprintDevURLs(t *testing.T) {
 		t.Fatalf("unexpected config schema URL: %q", info.ConfigSchemaURL)
 	}
 }
+
+func TestCheckConfigUpdatesRewritesAliases(t *testing.T) {
+	t.Parallel()
+
+	h := &handler{}
+	changes, err := h.CheckConfigUpdates(dprint.CheckConfigUpdatesMessage{
+		Config: dprint.ConfigKeyMap{
+			"indent_style":       "space",
+			"switch_case_indent": true,
+			"spaceRedirects":     true,
+			"space_redirects":    false,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []dprint.ConfigChange{
+		{Path: []any{"useTabs"}, Kind: dprint.ConfigChangeKindAdd, Value: false},
+		{Path: []any{"indent_style"}, Kind: dprint.ConfigChangeKindRemove},
+		{Path: []any{"switchCaseIndent"}, Kind: dprint.ConfigChangeKindAdd, Value: true},
+		{Path: []any{"switch_case_indent"}, Kind: dprint.ConfigChangeKindRemove},
+		{Path: []any{"space_redirects"}, Kind: dprint.ConfigChangeKindRemove},
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Fatalf("unexpected changes: %#v", changes)
+	}
+}
@@ -0,0 +1,7 @@
+//go:build wasm
+
+package main
+
+import "github.com/hrko/dprint-plugin-shfmt/dprint"
+
+var runtime = dprint.NewRuntime(&handler{})